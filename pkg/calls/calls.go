@@ -1,15 +1,22 @@
 package calls
 
 import (
+	"bufio"
+	"context"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"github.com/phillipgreen/mobilecombackup/pkg/coalescer"
+	"github.com/phillipgreen/mobilecombackup/pkg/contacts"
+	"github.com/phillipgreen/mobilecombackup/pkg/filter"
+	"github.com/phillipgreen/mobilecombackup/pkg/throttle"
 	"io"
+	"log"
 	"os"
 	"path"
-	"path/filepath"
 	"sort"
 	"strings"
+	"time"
 )
 
 type Key struct {
@@ -19,13 +26,69 @@ type Key struct {
 	Type     string
 }
 
-func (call *Call) key() Key {
+// Key identifies a call for deduplication.
+func (call *Call) Key() Key {
 	return Key{call.Number, call.Duration, call.Date, call.Type}
 }
 
+// NormalizedKey identifies a call for deduplication, comparing numbers in
+// E.164 form with national numbers interpreted for defaultRegion.
+func (call *Call) NormalizedKey(defaultRegion string) Key {
+	return KeyStrategy{}.Key(call, defaultRegion)
+}
+
+// Options tunes how calls are coalesced.
+type Options struct {
+	// DefaultRegion is the region used to normalize national numbers when
+	// deduplicating; numbers are compared as written when it is empty.
+	DefaultRegion string
+	// OnParseError decides what happens to files with unparsable calls;
+	// defaults to coalescer.SkipRecord.
+	OnParseError coalescer.ParseErrorPolicy
+	// Dedup decides which calls are duplicates. It applies to the calls
+	// already in the repository too, so a looser strategy merges them on the
+	// next flush.
+	Dedup KeyStrategy
+	// Filter, when set, selects the calls imported from backup files. Calls
+	// already in the repository are always kept.
+	Filter filter.Predicate
+	// Recover parses damaged backup files leniently, see recoverCalls. The
+	// calls which still cannot be read are skipped whatever OnParseError is.
+	Recover bool
+	// Transform, when set, is called with each call selected from a backup
+	// file before it is deduplicated. It may change the call, and returning
+	// false leaves the call out as if it were filtered.
+	Transform func(call *Call) bool
+	// OnCommit, when set, is called with the calls added by a flush once they
+	// are written.
+	OnCommit func(added []Call)
+	// Index has Flush write the IndexFile. It is kept up to date once it
+	// exists, whatever Index is.
+	Index bool
+	// Throttle, when set, limits how fast backup files are read, by all of
+	// the files being parsed together.
+	Throttle *throttle.Limiter
+}
+
 type backup struct {
 	outputDir string
+	options   Options
 	calls     map[Key]Call
+	// rejected holds the skipped calls until they are flushed
+	rejected []Rejection
+	// provenance holds where new calls came from until they are flushed
+	provenance []Provenance
+	// added holds the new calls for OnCommit until they are flushed
+	added []Call
+	// loose groups the keys of calls by the dedup looseKey when the dedup
+	// strategy is tolerant
+	loose map[Key][]Key
+	// deleted holds the dedup keys of the calls with a tombstone, which are
+	// not imported again
+	deleted map[Key]bool
+	// known holds the numbers with a contact name, in contacts.yaml or in a
+	// call already coalesced
+	known map[string]bool
 }
 
 type multierror struct {
@@ -43,17 +106,39 @@ func (m *multierror) Error() string {
 	return sb.String()
 }
 
-func (b *backup) ingest(file *os.File) error {
+// ingest merges the calls read from the file fileName, applying policy when
+// some of them cannot be parsed.
+func (b *backup) ingest(file io.Reader, fileName string, policy coalescer.ParseErrorPolicy) (coalescer.Result, error) {
+	staged, rejected := parseCalls(file, fileName)
+	return b.merge(fileName, nil, staged, rejected, policy)
+}
+
+// parseCalls decodes the calls of file, keeping those which cannot be
+// decoded as rejections.
+func parseCalls(file io.Reader, fileName string) ([]Call, []Rejection) {
+	return decodeCalls(file, fileName, true)
+}
+
+// decodeCalls is parseCalls, with strict deciding whether unknown entities
+// and stray ampersands are rejected.
+func decodeCalls(file io.Reader, fileName string, strict bool) ([]Call, []Rejection) {
 	// load file
-	decoder := xml.NewDecoder(file)
-	errs := make([]error, 0, 20)
+	var recorder = &recordingReader{r: file}
+	decoder := xml.NewDecoder(recorder)
+	decoder.Strict = strict
+	var rejected []Rejection
+	// calls are staged so that a rejected file leaves nothing behind
+	var staged []Call
 	for {
+		var start = decoder.InputOffset()
+		recorder.discard(start)
 		t, err := decoder.Token()
-		if err == io.EOF || t == nil {
+		if err == io.EOF {
 			break
 		}
+		// a damaged start tag fails here rather than in DecodeElement
 		if err != nil {
-			errs = append(errs, err)
+			rejected = append(rejected, recorder.rejection(fileName, start, decoder.InputOffset(), err))
 			break
 		}
 
@@ -63,22 +148,107 @@ func (b *backup) ingest(file *os.File) error {
 				var call Call
 				err := decoder.DecodeElement(&call, &se)
 				if err != nil {
-					errs = append(errs, err)
-					break
+					rejected = append(rejected, recorder.rejection(fileName, start, decoder.InputOffset(), err))
 				}
-				var k = call.key()
-				if _, ok := b.calls[k]; !ok {
-					b.calls[k] = call
+				if _, ok := err.(*xml.SyntaxError); ok {
+					// the rest of the file cannot be read
+					return staged, rejected
+				}
+				if err != nil {
+					break
 				}
+				staged = append(staged, call)
 			}
 		default:
 		}
 	}
-	if len(errs) > 0 {
-		return &multierror{msg: fmt.Sprintf("Error parsing %s", file.Name()), errors: errs}
+
+	return staged, rejected
+}
+
+// merge adds the new staged calls. When source is set, they come from the
+// backup file it describes: their provenance is recorded and the Transform
+// and OnCommit hooks apply.
+func (b *backup) merge(fileName string, source *Provenance, staged []Call, rejected []Rejection, policy coalescer.ParseErrorPolicy) (coalescer.Result, error) {
+	var result = coalescer.Result{NewByYear: map[int]int{}, NewContacts: map[string]string{}}
+	if len(rejected) > 0 {
+		var errs = make([]error, 0, len(rejected))
+		for _, r := range rejected {
+			errs = append(errs, r.err)
+		}
+		var parseErr = &multierror{msg: fmt.Sprintf("Error parsing %s", fileName), errors: errs}
+		switch policy {
+		case coalescer.FailImport:
+			return result, &coalescer.AbortError{Err: parseErr}
+		case coalescer.RejectFile:
+			return result, parseErr
+		default:
+			for _, r := range rejected {
+				log.Printf("Rejected call in [%s:%d]: %v", fileName, r.Line, r.err)
+			}
+			b.rejected = append(b.rejected, rejected...)
+		}
 	}
 
-	return nil
+	var importedAt = time.Now().UTC()
+	for _, call := range staged {
+		if source != nil && b.options.Transform != nil && !b.options.Transform(&call) {
+			result.Filtered++
+			continue
+		}
+		var k = b.options.Dedup.Key(&call, b.options.DefaultRegion)
+		if source != nil && b.deleted[k] {
+			log.Printf("Skipped deleted call in [%s]: call with %s at %d", fileName, call.Number, call.Date)
+			result.Filtered++
+			continue
+		}
+		if _, ok := b.calls[k]; ok {
+			result.Duplicates++
+			continue
+		}
+		if match, ok := b.tolerantMatch(k); ok {
+			var stored = b.calls[match]
+			log.Printf("Tolerant duplicate in [%s]: call with %s at %d lasting %s matches stored call at %d lasting %s",
+				fileName, call.Number, call.Date, call.Duration, stored.Date, stored.Duration)
+			result.Duplicates++
+			continue
+		}
+		b.calls[k] = call
+		if b.loose != nil {
+			var lk = b.options.Dedup.looseKey(k)
+			b.loose[lk] = append(b.loose[lk], k)
+		}
+		if source != nil {
+			var p = *source
+			p.Record, p.ImportedAt = call.Hash(), importedAt
+			b.provenance = append(b.provenance, p)
+		}
+		if source != nil && b.options.OnCommit != nil {
+			b.added = append(b.added, call)
+		}
+		result.New++
+		result.NewByYear[call.Time().UTC().Year()]++
+		if call.ContactName != "" && call.ContactName != UnknownContact && !b.known[call.Number] {
+			b.known[call.Number] = true
+			if source != nil {
+				result.NewContacts[call.Number] = call.ContactName
+			}
+		}
+	}
+	result.Rejected = len(rejected)
+	result.Total = len(b.calls)
+	return result, nil
+}
+
+// tolerantMatch returns the key of a stored call which k is a duplicate of
+// under the tolerances of the dedup strategy.
+func (b *backup) tolerantMatch(k Key) (Key, bool) {
+	for _, candidate := range b.loose[b.options.Dedup.looseKey(k)] {
+		if b.options.Dedup.withinTolerance(k, candidate) {
+			return candidate, true
+		}
+	}
+	return Key{}, false
 }
 
 func (b *backup) Supports(filePath string) (bool, error) {
@@ -86,24 +256,72 @@ func (b *backup) Supports(filePath string) (bool, error) {
 }
 
 func (b *backup) Coalesce(filePath string) (coalescer.Result, error) {
-	var result coalescer.Result
-	var initialTotalCalls int = len(b.calls)
+	merge, err := b.Prepare(filePath)
+	if err != nil {
+		return coalescer.Result{}, err
+	}
+	return merge()
+}
 
-	xmlFile, err := os.Open(filePath)
+func (b *backup) Prepare(filePath string) (func() (coalescer.Result, error), error) {
+	xmlFile, err := openLimited(filePath, b.options.Throttle)
 	// if we os.Open returns an error then handle it
 	if err != nil {
-		return result, err
+		return nil, err
 	}
 	defer xmlFile.Close()
 
-	err = b.ingest(xmlFile)
+	var buffered = bufio.NewReader(xmlFile)
+	var format = DetectSource(buffered)
+	log.Printf("Detected [%s] as %s", filePath, format)
+	var file = format.quirks().apply(buffered)
+
+	var staged []Call
+	var rejected []Rejection
+	var policy = b.options.OnParseError
+	var salvaged int
+	if b.options.Recover {
+		var replaced int
+		staged, rejected, replaced, err = recoverCalls(file, filePath)
+		if err != nil {
+			return nil, err
+		}
+		policy = coalescer.SkipRecord
+		if replaced > 0 || len(rejected) > 0 {
+			salvaged = len(staged)
+			log.Printf("Recovered [%s]: salvaged %d calls, lost %d, replaced %d characters", filePath, salvaged, len(rejected), replaced)
+		}
+	} else {
+		staged, rejected = parseCalls(file, filePath)
+	}
+	sourceHash, err := fileSHA256(filePath, b.options.Throttle)
 	if err != nil {
-		return result, err
+		return nil, err
+	}
+	var filtered int
+	if b.options.Filter != nil {
+		var selected = staged[:0]
+		for i := range staged {
+			if b.options.Filter(&staged[i]) {
+				selected = append(selected, staged[i])
+			}
+		}
+		filtered = len(staged) - len(selected)
+		staged = selected
 	}
 
-	result.Total = len(b.calls)
-	result.New = len(b.calls) - initialTotalCalls
-	return result, nil
+	return func() (coalescer.Result, error) {
+		var source = &Provenance{Source: filePath, SourceSHA256: sourceHash, Format: format.String()}
+		result, err := b.merge(filePath, source, staged, rejected, policy)
+		result.Filtered += filtered
+		result.Salvaged = salvaged
+		return result, err
+	}, nil
+}
+
+func (b *backup) Count(filePath string) (int, error) {
+	count, _, err := countCalls(context.Background(), filePath, b.options.Throttle)
+	return count, err
 }
 
 type ByDate []Call
@@ -112,58 +330,143 @@ func (a ByDate) Len() int           { return len(a) }
 func (a ByDate) Less(i, j int) bool { return a[i].Date < a[j].Date }
 func (a ByDate) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
 
-func (b *backup) Flush() error {
-	xmlFile, err := os.Create(b.BackingFile())
-	// if we os.Open returns an error then handle it
-	if err != nil {
-		return err
+// SortCanonical orders calls by date and then by the remaining dedup key
+// fields, and orders each call's extra attributes by name, so that the same
+// calls are always written identically.
+func SortCanonical(calls []Call) {
+	for i := range calls {
+		var extra = calls[i].Extra
+		sort.SliceStable(extra, func(a, b int) bool {
+			if extra[a].Name.Space != extra[b].Name.Space {
+				return extra[a].Name.Space < extra[b].Name.Space
+			}
+			return extra[a].Name.Local < extra[b].Name.Local
+		})
 	}
-	defer xmlFile.Close()
+	sort.SliceStable(calls, func(i, j int) bool {
+		a, b := calls[i], calls[j]
+		if a.Date != b.Date {
+			return a.Date < b.Date
+		}
+		if a.Number != b.Number {
+			return a.Number < b.Number
+		}
+		if a.Duration != b.Duration {
+			return a.Duration < b.Duration
+		}
+		return a.Type < b.Type
+	})
+}
 
+func (b *backup) Flush() error {
 	// convert map to list
 	var calls []Call = make([]Call, 0, len(b.calls))
 	for _, value := range b.calls {
 		calls = append(calls, value)
 	}
 	// sort list
-	sort.Sort(ByDate(calls))
-	// build xml container
-	var wrappedData = Calls{Calls: calls, Count: len(calls)}
-	out, err := xml.MarshalIndent(wrappedData, "", "\t")
-	if err != nil {
-		return err
+	SortCanonical(calls)
+
+	// all files are replaced together, so a failure leaves none of them
+	// changed
+	var tx transaction
+	err := stageCalls(&tx, b.BackingFile(), calls)
+	if err == nil && b.indexed() {
+		err = stageIndex(&tx, IndexFile(b.outputDir), tempFile(b.BackingFile()))
 	}
-	_, err = xmlFile.WriteString(xml.Header)
-	if err != nil {
-		return err
+	if err == nil && len(b.provenance) > 0 {
+		err = appendProvenance(&tx, ProvenanceFile(b.outputDir), b.provenance)
 	}
-	_, err = xmlFile.WriteString("<?xml-stylesheet type=\"text/xsl\" href=\"calls.xsl\"?>\n")
-	if err != nil {
-		return err
+	if err == nil && len(b.rejected) > 0 {
+		err = appendRejections(&tx, RejectionsFile(b.outputDir, time.Now()), b.rejected)
+	}
+	if err == nil {
+		err = tx.commit()
+	} else {
+		tx.abort()
 	}
-	_, err = xmlFile.Write(out)
 	if err != nil {
 		return err
 	}
-
+	b.provenance = nil
+	b.rejected = nil
+	if b.options.OnCommit != nil && len(b.added) > 0 {
+		b.options.OnCommit(b.added)
+		b.added = nil
+	}
 	return nil
 }
 
+// indexed reports whether Flush writes the IndexFile.
+func (b *backup) indexed() bool {
+	if b.options.Index {
+		return true
+	}
+	_, err := os.Stat(IndexFile(b.outputDir))
+	return err == nil
+}
+
 func (b *backup) BackingFile() string {
-	return filepath.Join(b.outputDir, "calls.xml")
+	return RepositoryFile(b.outputDir)
+}
+
+// ErrDamagedRepository is matched by the errors of InitWithOptions when the
+// files of the repository cannot be read or parsed.
+var ErrDamagedRepository = errors.New("repository is damaged")
+
+// damagedError keeps the error reading a repository file while matching
+// ErrDamagedRepository.
+type damagedError struct {
+	err error
 }
 
-func Init(rootDir string) coalescer.Coalescer {
-	var backup = backup{rootDir, map[Key]Call{}}
+func (e *damagedError) Error() string { return e.err.Error() }
+
+func (e *damagedError) Unwrap() error { return e.err }
+
+func (e *damagedError) Is(target error) bool { return target == ErrDamagedRepository }
+
+func Init(rootDir string) (coalescer.Coalescer, error) {
+	return InitWithOptions(rootDir, Options{})
+}
+
+// InitWithOptions loads the calls, tombstones and contacts of the repository
+// at rootDir, failing with an error matching ErrDamagedRepository when any of
+// them cannot be read.
+func InitWithOptions(rootDir string, options Options) (coalescer.Coalescer, error) {
+	var backup = backup{outputDir: rootDir, options: options, calls: map[Key]Call{}, known: map[string]bool{}}
+	if options.Dedup.tolerant() {
+		backup.loose = map[Key][]Key{}
+	}
+	book, err := contacts.Load(contacts.File(rootDir))
+	if err != nil {
+		return nil, &damagedError{err}
+	}
+	for _, c := range book.Contacts {
+		for _, n := range c.Numbers {
+			backup.known[n] = true
+		}
+	}
 	var cf = backup.BackingFile()
-	_, err := os.Stat(cf)
+	// the repository itself is never partially loaded
+	xmlFile, err := Open(cf)
+	if err != nil {
+		return nil, &damagedError{err}
+	}
+	defer xmlFile.Close()
+	_, err = backup.ingest(xmlFile, cf, coalescer.FailImport)
 	if err != nil {
-		panic(err.Error())
+		return nil, &damagedError{err}
 	}
-	_, err = backup.Coalesce(cf)
+	backup.deleted = map[Key]bool{}
+	err = StreamTombstones(TombstoneFile(rootDir), func(t Tombstone) error {
+		var call = t.call()
+		backup.deleted[options.Dedup.Key(&call, options.DefaultRegion)] = true
+		return nil
+	})
 	if err != nil {
-		panic(err.Error())
+		return nil, &damagedError{err}
 	}
 
-	return &backup
+	return &backup, nil
 }
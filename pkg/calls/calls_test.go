@@ -0,0 +1,244 @@
+package calls
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/phillipgreen/mobilecombackup/pkg/coalescer"
+	"github.com/phillipgreen/mobilecombackup/pkg/filter"
+)
+
+const emptyCalls = `<?xml version='1.0' encoding='UTF-8' standalone='yes' ?>
+<calls count="0">
+</calls>
+`
+
+func writeFile(t *testing.T, path, content string) {
+	err := os.WriteFile(path, []byte(content), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// mustInit initializes the coalescer of the repository at dir, failing the
+// test when it cannot be loaded.
+func mustInit(t *testing.T, dir string, options Options) coalescer.Coalescer {
+	c, err := InitWithOptions(dir, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestInitDamagedRepository(t *testing.T) {
+	var tests = []struct {
+		name       string
+		calls      string
+		tombstones string
+	}{
+		{"malformed calls", "<calls count=\"1\">\n  <call number=\"1\" dura", ""},
+		{"malformed tombstones", emptyCalls, "{\"record\": \n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFile(t, RepositoryFile(dir), tt.calls)
+			if tt.tombstones != "" {
+				err := os.MkdirAll(filepath.Dir(TombstoneFile(dir)), 0755)
+				if err != nil {
+					t.Fatal(err)
+				}
+				writeFile(t, TombstoneFile(dir), tt.tombstones)
+			}
+			c, err := InitWithOptions(dir, Options{})
+			if !errors.Is(err, ErrDamagedRepository) || c != nil {
+				t.Errorf("got %v, %v, want %v", c, err, ErrDamagedRepository)
+			}
+		})
+	}
+}
+
+func TestCoalesceNormalizesNumbers(t *testing.T) {
+	var tests = []struct {
+		region string
+		new    int
+	}{
+		{"", 2},
+		{"US", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.region, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFile(t, filepath.Join(dir, "calls.xml"), emptyCalls)
+			source := filepath.Join(dir, "calls-source.xml")
+			writeFile(t, source, `<calls count="2">
+  <call number="5555550013" duration="33" date="1415054053956" type="2" />
+  <call number="+1 (555) 555-0013" duration="33" date="1415054053956" type="2" />
+</calls>`)
+
+			c := mustInit(t, dir, Options{DefaultRegion: tt.region})
+			result, err := c.Coalesce(source)
+			if err != nil {
+				t.Fatalf("err got %v, want nil", err)
+			}
+			if result.New != tt.new {
+				t.Errorf("new got %d, want %d", result.New, tt.new)
+			}
+		})
+	}
+}
+
+func TestFlushPreservesNewerAttributes(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "calls.xml"), emptyCalls)
+	source := filepath.Join(dir, "calls-source.xml")
+	writeFile(t, source, `<calls count="1">
+  <call number="5555550013" duration="12" date="1415054053956" type="4" presentation="1" subscription_id="89014103211118510720" />
+</calls>`)
+
+	c := mustInit(t, dir, Options{})
+	_, err := c.Coalesce(source)
+	if err != nil {
+		t.Fatalf("err got %v, want nil", err)
+	}
+	err = c.Flush()
+	if err != nil {
+		t.Fatalf("err got %v, want nil", err)
+	}
+
+	stored, err := ReadCalls(filepath.Join(dir, "calls.xml"))
+	if err != nil {
+		t.Fatalf("err got %v, want nil", err)
+	}
+	if len(stored) != 1 {
+		t.Fatalf("calls got %d, want 1", len(stored))
+	}
+	if stored[0].Type != Voicemail {
+		t.Errorf("type got %q, want %q", stored[0].Type, Voicemail)
+	}
+	var extra = map[string]string{}
+	for _, a := range stored[0].Extra {
+		extra[a.Name.Local] = a.Value
+	}
+	if extra["presentation"] != "1" || extra["subscription_id"] != "89014103211118510720" {
+		t.Errorf("extra got %v", stored[0].Extra)
+	}
+}
+
+func TestCoalesceParseErrorPolicy(t *testing.T) {
+	var tests = []struct {
+		policy   coalescer.ParseErrorPolicy
+		new      int
+		rejected int
+		abort    bool
+		fail     bool
+	}{
+		{coalescer.SkipRecord, 1, 1, false, false},
+		{coalescer.RejectFile, 0, 0, false, true},
+		{coalescer.FailImport, 0, 0, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			dir := t.TempDir()
+			writeFile(t, filepath.Join(dir, "calls.xml"), emptyCalls)
+			source := filepath.Join(dir, "calls-source.xml")
+			writeFile(t, source, `<calls count="2">
+  <call number="5555550013" duration="33" date="1415054053956" type="2" />
+  <call number="5555550014" duration="33" date="yesterday" type="2" />
+</calls>`)
+
+			c := mustInit(t, dir, Options{OnParseError: tt.policy})
+			result, err := c.Coalesce(source)
+			if (err != nil) != tt.fail {
+				t.Fatalf("err got %v, want failure %v", err, tt.fail)
+			}
+			var abortErr *coalescer.AbortError
+			if errors.As(err, &abortErr) != tt.abort {
+				t.Errorf("err got %v, want abort %v", err, tt.abort)
+			}
+			if result.New != tt.new || result.Rejected != tt.rejected {
+				t.Errorf("new/rejected got %d/%d, want %d/%d", result.New, result.Rejected, tt.new, tt.rejected)
+			}
+
+			// nothing from a failed file is left behind
+			err = c.Flush()
+			if err != nil {
+				t.Fatal(err)
+			}
+			stored, err := ReadCalls(filepath.Join(dir, "calls.xml"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(stored) != tt.new {
+				t.Errorf("stored got %d, want %d", len(stored), tt.new)
+			}
+		})
+	}
+}
+
+func TestCoalesceFilter(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "calls.xml"), `<calls count="1">
+  <call number="5555550099" duration="0" date="1388534400000" type="3" />
+</calls>`)
+	source := filepath.Join(dir, "calls-source.xml")
+	writeFile(t, source, `<calls count="3">
+  <call number="5555550013" duration="33" date="1388534400000" type="2" />
+  <call number="5555550014" duration="12" date="1420070400000" type="1" />
+  <call number="7535" duration="0" date="1420070500000" type="3" />
+</calls>`)
+
+	// 2014-06-01 excludes the 2014-01-01 call, and short codes are left out
+	predicate, err := filter.New([]string{"date>=2014-06-01"}, []string{"number=????"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := mustInit(t, dir, Options{Filter: predicate})
+	result, err := c.Coalesce(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.New != 1 || result.Filtered != 2 {
+		t.Errorf("new/filtered got %d/%d, want 1/2", result.New, result.Filtered)
+	}
+	// the repository is never filtered
+	if result.Total != 2 {
+		t.Errorf("total got %d, want 2", result.Total)
+	}
+}
+
+func TestCoalesceRecover(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "calls.xml"), emptyCalls)
+	source := filepath.Join(dir, "calls-source.xml")
+	// an illegal character, an unknown entity and a truncated last call
+	writeFile(t, source, "<calls count=\"3\">\n"+
+		"  <call number=\"5555550013\" duration=\"33\" date=\"1388534400000\" type=\"2\" contact_name=\"A\x01B\" />\n"+
+		"  <call number=\"5555550014\" duration=\"12\" date=\"1420070400000\" type=\"1\" contact_name=\"Tom &amp Jerry&nbsp;\" />\n"+
+		"  <call number=\"5555550015\" dura")
+
+	c := mustInit(t, dir, Options{OnParseError: coalescer.RejectFile})
+	if _, err := c.Coalesce(source); err == nil {
+		t.Fatalf("err got nil, want the parse error")
+	}
+
+	c = mustInit(t, dir, Options{OnParseError: coalescer.RejectFile, Recover: true})
+	result, err := c.Coalesce(source)
+	if err != nil {
+		t.Fatalf("err got %v, want nil", err)
+	}
+	if result.New != 2 || result.Salvaged != 2 || result.Rejected != 1 {
+		t.Errorf("new/salvaged/rejected got %d/%d/%d, want 2/2/1", result.New, result.Salvaged, result.Rejected)
+	}
+}
+
+func TestSanitizeXML(t *testing.T) {
+	got, replaced := sanitizeXML([]byte("a\x01b\xffc\té"))
+	if string(got) != "a�b�c\té" || replaced != 2 {
+		t.Errorf("got %q with %d replaced", got, replaced)
+	}
+}
@@ -0,0 +1,72 @@
+package calls
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrChecksumMismatch is returned by VerifyChecksum when a file no longer has
+// the checksum recorded for it.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// ChecksumFile returns the path of the file recording the SHA-256 of the calls
+// file at filePath, in the format of sha256sum.
+func ChecksumFile(filePath string) string {
+	return filePath + ".sha256"
+}
+
+// stageChecksum stages the checksum file of filePath recording hash.
+func stageChecksum(tx *transaction, filePath, hash string) error {
+	return tx.stage(ChecksumFile(filePath), func(tmp string) error {
+		return os.WriteFile(tmp, []byte(hash+"  "+filepath.Base(filePath)+"\n"), 0644)
+	})
+}
+
+// WriteChecksum records the SHA-256 of the file at filePath in its checksum
+// file.
+func WriteChecksum(filePath string) error {
+	hash, err := FileSHA256(filePath)
+	if err != nil {
+		return err
+	}
+	var tx transaction
+	err = stageChecksum(&tx, filePath, hash)
+	if err != nil {
+		return err
+	}
+	return tx.commit()
+}
+
+// ReadChecksum returns the SHA-256 recorded for the file at filePath.
+func ReadChecksum(filePath string) (string, error) {
+	content, err := os.ReadFile(ChecksumFile(filePath))
+	if err != nil {
+		return "", err
+	}
+	var fields = strings.Fields(string(content))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("%s is empty", ChecksumFile(filePath))
+	}
+	return fields[0], nil
+}
+
+// VerifyChecksum checks the file at filePath against its checksum file. The
+// error wraps os.ErrNotExist when there is no checksum file and
+// ErrChecksumMismatch when the file changed.
+func VerifyChecksum(filePath string) error {
+	want, err := ReadChecksum(filePath)
+	if err != nil {
+		return err
+	}
+	got, err := FileSHA256(filePath)
+	if err != nil {
+		return err
+	}
+	if got != want {
+		return fmt.Errorf("%w: %s has %s, recorded %s", ErrChecksumMismatch, filepath.Base(filePath), got, want)
+	}
+	return nil
+}
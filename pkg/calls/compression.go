@@ -0,0 +1,90 @@
+package calls
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/phillipgreen/mobilecombackup/pkg/throttle"
+)
+
+// CompressedSuffix marks calls files stored gzip compressed.
+const CompressedSuffix = ".gz"
+
+// IsCompressed reports whether the calls file at filePath is stored compressed.
+func IsCompressed(filePath string) bool {
+	return strings.HasSuffix(filePath, CompressedSuffix)
+}
+
+type gzipFile struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (g *gzipFile) Close() error {
+	err := g.Reader.Close()
+	if cerr := g.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Open opens the calls file at filePath for reading, decompressing it when it
+// is stored compressed.
+func Open(filePath string) (io.ReadCloser, error) {
+	return openLimited(filePath, nil)
+}
+
+type limitedFile struct {
+	io.Reader
+	io.Closer
+}
+
+// openLimited is Open reading the file as stored at the rate of l.
+func openLimited(filePath string, l *throttle.Limiter) (io.ReadCloser, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	var r = throttle.Reader(file, l)
+	if !IsCompressed(filePath) {
+		if l == nil {
+			return file, nil
+		}
+		return limitedFile{r, file}, nil
+	}
+
+	reader, err := gzip.NewReader(r)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &gzipFile{reader, file}, nil
+}
+
+type gzipWriteFile struct {
+	*gzip.Writer
+	file *os.File
+}
+
+func (g *gzipWriteFile) Close() error {
+	err := g.Writer.Close()
+	if cerr := g.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Create creates the calls file at filePath for writing, compressing it when
+// filePath has the CompressedSuffix.
+func Create(filePath string) (io.WriteCloser, error) {
+	file, err := os.Create(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if !IsCompressed(filePath) {
+		return file, nil
+	}
+	return &gzipWriteFile{gzip.NewWriter(file), file}, nil
+}
@@ -0,0 +1,162 @@
+package calls
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/phillipgreen/mobilecombackup/pkg/phone"
+)
+
+// Fields which may make up a deduplication key.
+const (
+	NumberField   = "number"
+	DurationField = "duration"
+	DateField     = "date"
+	TypeField     = "type"
+)
+
+// KeyFields lists every field of the deduplication key, in key order.
+var KeyFields = []string{NumberField, DurationField, DateField, TypeField}
+
+// KeyStrategy decides which calls are duplicates of each other. The zero
+// value compares every key field exactly.
+type KeyStrategy struct {
+	// Fields are the key fields compared; nil compares all of KeyFields.
+	Fields []string
+	// DateRounding, when set, rounds dates down to a multiple of it before
+	// comparing, so that backups recording different precision match.
+	DateRounding time.Duration
+	// DateTolerance and DurationTolerance, when set, also make calls
+	// duplicates when their dates, or their durations in seconds, differ by
+	// at most that much while the other compared fields are equal.
+	DateTolerance     time.Duration
+	DurationTolerance int
+}
+
+// ParseKeyFields parses a comma separated list of key fields.
+func ParseKeyFields(s string) ([]string, error) {
+	var fields []string
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+		if !isKeyField(f) {
+			return nil, fmt.Errorf("%w %q, expected some of %s", ErrUnknownField, f, strings.Join(KeyFields, ", "))
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+func isKeyField(f string) bool {
+	for _, k := range KeyFields {
+		if f == k {
+			return true
+		}
+	}
+	return false
+}
+
+func (s KeyStrategy) includes(field string) bool {
+	if s.Fields == nil {
+		return true
+	}
+	for _, f := range s.Fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// Key identifies call for deduplication under the strategy, comparing numbers
+// in E.164 form with national numbers interpreted for defaultRegion. Fields
+// which are not compared are left empty.
+func (s KeyStrategy) Key(call *Call, defaultRegion string) Key {
+	var k Key
+	if s.includes(NumberField) {
+		k.Number = phone.Normalize(call.Number, defaultRegion)
+	}
+	if s.includes(DurationField) {
+		k.Duration = call.Duration
+	}
+	if s.includes(DateField) {
+		k.Date = call.Date
+		if rounding := int(s.DateRounding / time.Millisecond); rounding > 1 {
+			k.Date -= k.Date % rounding
+		}
+	}
+	if s.includes(TypeField) {
+		k.Type = call.Type
+	}
+	return k
+}
+
+// tolerant reports whether keys which differ may still be duplicates.
+func (s KeyStrategy) tolerant() bool {
+	return s.DateTolerance > 0 || s.DurationTolerance > 0
+}
+
+// looseKey returns k without the fields compared with a tolerance, so that
+// keys which may be duplicates share it.
+func (s KeyStrategy) looseKey(k Key) Key {
+	if s.DateTolerance > 0 {
+		k.Date = 0
+	}
+	if s.DurationTolerance > 0 {
+		k.Duration = ""
+	}
+	return k
+}
+
+// withinTolerance reports whether keys a and b, which share a looseKey, are
+// duplicates.
+func (s KeyStrategy) withinTolerance(a, b Key) bool {
+	var dateApart = a.Date - b.Date
+	if dateApart < 0 {
+		dateApart = -dateApart
+	}
+	if time.Duration(dateApart)*time.Millisecond > s.DateTolerance {
+		return false
+	}
+	if a.Duration == b.Duration {
+		return true
+	}
+	da, errA := strconv.Atoi(a.Duration)
+	db, errB := strconv.Atoi(b.Duration)
+	if errA != nil || errB != nil {
+		return false
+	}
+	return da-db <= s.DurationTolerance && db-da <= s.DurationTolerance
+}
+
+// NearDuplicate is a pair of distinct calls with the same number which took
+// place close together; they may be one call recorded differently.
+type NearDuplicate struct {
+	First        Call    `json:"first"`
+	Second       Call    `json:"second"`
+	SecondsApart float64 `json:"seconds_apart"`
+}
+
+// FindNearDuplicates returns the pairs of consecutive calls with the same
+// normalized number which are at most window apart, ordered by date.
+func FindNearDuplicates(calls []Call, defaultRegion string, window time.Duration) []NearDuplicate {
+	var sorted = append([]Call(nil), calls...)
+	SortCanonical(sorted)
+
+	var last = map[string]Call{}
+	var found = []NearDuplicate{}
+	for _, c := range sorted {
+		var number = phone.Normalize(c.Number, defaultRegion)
+		if prev, ok := last[number]; ok {
+			var apart = time.Duration(c.Date-prev.Date) * time.Millisecond
+			if apart <= window {
+				found = append(found, NearDuplicate{prev, c, apart.Seconds()})
+			}
+		}
+		last[number] = c
+	}
+	sort.SliceStable(found, func(i, j int) bool { return found[i].First.Date < found[j].First.Date })
+	return found
+}
@@ -0,0 +1,75 @@
+package calls
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCoalesceKeyStrategy(t *testing.T) {
+	var tests = []struct {
+		desc     string
+		strategy KeyStrategy
+		new      int
+	}{
+		{"all fields", KeyStrategy{}, 3},
+		{"date rounded to seconds", KeyStrategy{DateRounding: time.Second}, 2},
+		{"without duration", KeyStrategy{Fields: []string{NumberField, DateField, TypeField}}, 2},
+		{"number only", KeyStrategy{Fields: []string{NumberField}}, 1},
+		{"date within a second", KeyStrategy{DateTolerance: time.Second}, 2},
+		{"date within half a second", KeyStrategy{DateTolerance: 500 * time.Millisecond}, 3},
+		{"duration within a second", KeyStrategy{DurationTolerance: 1}, 2},
+		{"date and duration tolerance", KeyStrategy{DateTolerance: time.Second, DurationTolerance: 1}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFile(t, filepath.Join(dir, "calls.xml"), emptyCalls)
+			source := filepath.Join(dir, "calls-source.xml")
+			writeFile(t, source, `<calls count="3">
+  <call number="5555550013" duration="33" date="1415054053000" type="2" />
+  <call number="5555550013" duration="33" date="1415054053956" type="2" />
+  <call number="5555550013" duration="34" date="1415054053000" type="2" />
+</calls>`)
+
+			c := mustInit(t, dir, Options{Dedup: tt.strategy})
+			result, err := c.Coalesce(source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.New != tt.new {
+				t.Errorf("new got %d, want %d", result.New, tt.new)
+			}
+		})
+	}
+}
+
+func TestParseKeyFields(t *testing.T) {
+	fields, err := ParseKeyFields("number, date")
+	if err != nil || len(fields) != 2 {
+		t.Errorf("got %v, %v; want [number date]", fields, err)
+	}
+	_, err = ParseKeyFields("number,contact_name")
+	if err == nil {
+		t.Errorf("contact_name got nil err")
+	}
+}
+
+func TestFindNearDuplicates(t *testing.T) {
+	var in = []Call{
+		{Number: "5555550013", Date: 1415054053000, Type: Incoming},
+		{Number: "(555) 555-0013", Date: 1415054083000, Type: Missed},
+		{Number: "5555550014", Date: 1415054060000, Type: Incoming},
+		{Number: "5555550013", Date: 1415054300000, Type: Incoming},
+	}
+
+	found := FindNearDuplicates(in, "US", time.Minute)
+
+	if len(found) != 1 {
+		t.Fatalf("found got %v, want 1 pair", found)
+	}
+	if found[0].First.Type != Incoming || found[0].Second.Type != Missed || found[0].SecondsApart != 30 {
+		t.Errorf("pair got %+v", found[0])
+	}
+}
@@ -0,0 +1,174 @@
+package calls
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// ErrUnknownField is returned for call fields which cannot be edited, or
+// compared by a KeyStrategy.
+var ErrUnknownField = errors.New("unknown field")
+
+// ErrInvalidValue is returned by SetField for values the field cannot hold.
+var ErrInvalidValue = errors.New("invalid value")
+
+// ErrDuplicateCall is returned by EditCalls for edits which would make a
+// call the same as another.
+var ErrDuplicateCall = errors.New("duplicate call")
+
+// EditableFields are the call attributes SetField can change.
+var EditableFields = []string{"number", "duration", "date", "type", "readable_date", "contact_name"}
+
+// Change sets one attribute of a call.
+type Change struct {
+	Field string
+	Value string
+}
+
+// Edit records a change made to a stored call, as kept in the AuditFile.
+type Edit struct {
+	// Record is the Hash of the call before the change; NewRecord is its
+	// Hash afterwards when the change altered it.
+	Record    string    `json:"record"`
+	NewRecord string    `json:"new_record,omitempty"`
+	Field     string    `json:"field"`
+	Old       string    `json:"old"`
+	New       string    `json:"new"`
+	Reason    string    `json:"reason,omitempty"`
+	EditedAt  time.Time `json:"edited_at"`
+}
+
+// AuditFile returns the path of the log of edits of the repository at
+// rootDir.
+func AuditFile(rootDir string) string {
+	return filepath.Join(rootDir, "audit", "edits.jsonl")
+}
+
+// SetField sets the attribute name of call to value, returning the previous
+// value.
+func (call *Call) SetField(name, value string) (string, error) {
+	var old string
+	switch name {
+	case "number":
+		old, call.Number = call.Number, value
+	case "duration":
+		if n, err := strconv.Atoi(value); err != nil || n < 0 {
+			return "", fmt.Errorf("%w: duration %q is not a number of seconds", ErrInvalidValue, value)
+		}
+		old, call.Duration = call.Duration, value
+	case "date":
+		date, err := strconv.Atoi(value)
+		if err != nil || date <= 0 {
+			return "", fmt.Errorf("%w: date %q is not in epoch milliseconds", ErrInvalidValue, value)
+		}
+		old, call.Date = strconv.Itoa(call.Date), date
+	case "type":
+		old, call.Type = call.Type, value
+	case "readable_date":
+		old, call.ReadableDate = call.ReadableDate, value
+	case "contact_name":
+		old, call.ContactName = call.ContactName, value
+	default:
+		return "", fmt.Errorf("%w %q, expected one of %v", ErrUnknownField, name, EditableFields)
+	}
+	return old, nil
+}
+
+// EditCalls applies changes to the calls of the repository at rootDir which
+// selected returns true for. The calls file, reordered when dates changed, the
+// audit entries of the edits and a tombstone for each call whose Key changed
+// are committed together unless dryRun is set; the tombstones keep imports of
+// the backup files holding the unedited calls from adding them back. Edits
+// which would make a call a duplicate of another fail.
+func EditCalls(rootDir string, selected func(*Call) bool, changes []Change, reason string, dryRun bool) ([]Edit, error) {
+	var callsFile = RepositoryFile(rootDir)
+	all, err := ReadCalls(callsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys = map[Key]int{}
+	for i := range all {
+		keys[all[i].Key()] = i
+	}
+	var editedAt = time.Now().UTC()
+	var edits []Edit
+	var tombstones []interface{}
+	for i := range all {
+		var call = &all[i]
+		if !selected(call) {
+			continue
+		}
+		var record, key = call.Hash(), call.Key()
+		var unedited = *call
+		var first = len(edits)
+		for _, c := range changes {
+			old, err := call.SetField(c.Field, c.Value)
+			if err != nil {
+				return nil, err
+			}
+			if old != c.Value {
+				edits = append(edits, Edit{Record: record, Field: c.Field, Old: old, New: c.Value, Reason: reason, EditedAt: editedAt})
+			}
+		}
+		if newKey := call.Key(); newKey != key {
+			if other, ok := keys[newKey]; ok && other != i {
+				return nil, fmt.Errorf("editing call %s would make a %w of %s", record, ErrDuplicateCall, all[other].Hash())
+			}
+			delete(keys, key)
+			keys[newKey] = i
+			for j := first; j < len(edits); j++ {
+				edits[j].NewRecord = call.Hash()
+			}
+			tombstones = append(tombstones, Tombstone{Record: record, Number: unedited.Number, Duration: unedited.Duration,
+				Date: unedited.Date, Type: unedited.Type, Reason: reason, DeletedAt: editedAt})
+		}
+	}
+	if len(edits) == 0 || dryRun {
+		return edits, nil
+	}
+
+	SortCanonical(all)
+	var entries = make([]interface{}, len(edits))
+	for i := range edits {
+		entries[i] = edits[i]
+	}
+	var tx transaction
+	err = stageCalls(&tx, callsFile, all)
+	// hashes changed, so an index must be rebuilt with the calls
+	if err == nil {
+		err = restageIndex(&tx, callsFile)
+	}
+	if err == nil {
+		err = appendJSONLines(&tx, AuditFile(rootDir), entries)
+	}
+	if err == nil && len(tombstones) > 0 {
+		err = appendJSONLines(&tx, TombstoneFile(rootDir), tombstones)
+	}
+	if err == nil {
+		err = tx.commit()
+	} else {
+		tx.abort()
+	}
+	return edits, err
+}
+
+// StreamEdits invokes callback for each entry of the audit log at filePath.
+// A missing log has no entries.
+func StreamEdits(filePath string, callback func(Edit) error) error {
+	f, err := os.Open(filePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var e Edit
+	return streamJSONLines(f, filePath, &e, func() error { return callback(e) })
+}
@@ -0,0 +1,117 @@
+package calls
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestEditCalls(t *testing.T) {
+	var byNumber = func(number string) func(*Call) bool {
+		return func(c *Call) bool { return c.Number == number }
+	}
+	tests := []struct {
+		name     string
+		selected func(*Call) bool
+		changes  []Change
+		dryRun   bool
+		numbers  string
+		edits    int
+		rehashed bool
+		wantErr  string
+	}{
+		{"contact name", byNumber("1"), []Change{{"contact_name", "Alice"}}, false, "1,2,3", 1, false, ""},
+		{"date reorders", byNumber("1"), []Change{{"date", "3500"}}, false, "2,3,1", 1, true, ""},
+		{"unchanged value", byNumber("2"), []Change{{"duration", "5"}}, false, "1,2,3", 0, false, ""},
+		{"dry run", byNumber("1"), []Change{{"number", "9"}}, true, "1,2,3", 1, true, ""},
+		{"duplicate", byNumber("1"), []Change{{"number", "2"}, {"duration", "5"}, {"date", "2000"}, {"type", Outgoing}}, false, "1,2,3", 0, false, "duplicate"},
+		{"bad date", byNumber("1"), []Change{{"date", "yesterday"}}, false, "1,2,3", 0, false, "epoch"},
+		{"unknown field", byNumber("1"), []Change{{"body", "hi"}}, false, "1,2,3", 0, false, "unknown field"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			err := WriteCalls(RepositoryFile(dir), []Call{
+				{Number: "1", Duration: "0", Date: 1000, Type: Incoming},
+				{Number: "2", Duration: "5", Date: 2000, Type: Outgoing},
+				{Number: "3", Duration: "0", Date: 3000, Type: Missed},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			edits, err := EditCalls(dir, tt.selected, tt.changes, "testing", tt.dryRun)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("err got %v, want one containing %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("err got %v, want nil", err)
+			}
+			if tt.wantErr == "" && len(edits) != tt.edits {
+				t.Errorf("edits got %v, want %d", edits, tt.edits)
+			}
+			if len(edits) > 0 && (edits[0].NewRecord != "") != tt.rehashed {
+				t.Errorf("new record got %q, want one: %v", edits[0].NewRecord, tt.rehashed)
+			}
+
+			stored, err := ReadCalls(RepositoryFile(dir))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var numbers []string
+			for _, c := range stored {
+				numbers = append(numbers, c.Number)
+			}
+			if strings.Join(numbers, ",") != tt.numbers {
+				t.Errorf("calls got %v, want %s", numbers, tt.numbers)
+			}
+			err = VerifyChecksum(RepositoryFile(dir))
+			if err != nil {
+				t.Errorf("checksum err got %v, want nil", err)
+			}
+
+			var logged []Edit
+			err = StreamEdits(AuditFile(dir), func(e Edit) error {
+				logged = append(logged, e)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var wantLogged = tt.edits
+			if tt.dryRun || tt.wantErr != "" {
+				wantLogged = 0
+			}
+			if len(logged) != wantLogged {
+				t.Errorf("audit log got %v, want %d entries", logged, wantLogged)
+			}
+			if len(logged) > 0 && (logged[0].Reason != "testing" || logged[0].Record != edits[0].Record) {
+				t.Errorf("audit entry got %+v, want %+v", logged[0], edits[0])
+			}
+		})
+	}
+}
+
+func TestEditCallsAppendsAuditLog(t *testing.T) {
+	dir := t.TempDir()
+	err := WriteCalls(RepositoryFile(dir), []Call{{Number: "1", Duration: "0", Date: 1000, Type: Incoming}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var all = func(*Call) bool { return true }
+	for _, name := range []string{"Alice", "Bob"} {
+		_, err = EditCalls(dir, all, []Change{{"contact_name", name}}, "", false)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	content, err := os.ReadFile(AuditFile(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(content), "\n"); lines != 2 {
+		t.Errorf("audit log got %d lines, want 2:\n%s", lines, content)
+	}
+}
@@ -0,0 +1,254 @@
+package calls
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// The index file starts with indexMagic and the raw SHA-256 of the calls file
+// it was built from, followed by one entry per call, ordered by hash: the raw
+// Hash of the call and its big endian offset in the decompressed calls file.
+const (
+	indexMagic      = "MCBIDX1\n"
+	indexHeaderSize = len(indexMagic) + 32
+	indexEntrySize  = 32 + 8
+)
+
+// ErrStaleIndex is returned by OpenIndex when the calls file changed since the
+// index was built.
+var ErrStaleIndex = errors.New("index is out of date")
+
+// ErrCorruptIndex is returned by OpenIndex when the file is not an index.
+var ErrCorruptIndex = errors.New("not a calls index")
+
+// ErrInvalidHash is returned for record hashes which are not hex SHA-256.
+var ErrInvalidHash = errors.New("invalid record hash")
+
+// IndexFile returns the path of the call index of the repository at rootDir.
+func IndexFile(rootDir string) string {
+	return filepath.Join(rootDir, "index", "calls.idx")
+}
+
+type indexEntry struct {
+	hash   [32]byte
+	offset uint64
+}
+
+// indexEntries returns the offset of every call of the calls file at
+// filePath, ordered by hash.
+func indexEntries(filePath string) ([]indexEntry, error) {
+	xmlFile, err := Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer xmlFile.Close()
+
+	var entries []indexEntry
+	decoder := xml.NewDecoder(xmlFile)
+	for {
+		var start = decoder.InputOffset()
+		t, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		se, ok := t.(xml.StartElement)
+		if !ok || se.Name.Local != "call" {
+			continue
+		}
+		var call Call
+		err = decoder.DecodeElement(&call, &se)
+		if err != nil {
+			return nil, err
+		}
+		var e = indexEntry{offset: uint64(start)}
+		hex.Decode(e.hash[:], []byte(call.Hash()))
+		entries = append(entries, e)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return bytes.Compare(entries[i].hash[:], entries[j].hash[:]) < 0
+	})
+	return entries, nil
+}
+
+// writeIndex writes the index of the calls file at callsFile, whose SHA-256 is
+// callsHash, to w.
+func writeIndex(w io.Writer, callsFile, callsHash string) error {
+	entries, err := indexEntries(callsFile)
+	if err != nil {
+		return err
+	}
+	var header = make([]byte, indexHeaderSize)
+	copy(header, indexMagic)
+	_, err = hex.Decode(header[len(indexMagic):], []byte(callsHash))
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(header)
+	if err != nil {
+		return err
+	}
+	var entry = make([]byte, indexEntrySize)
+	for _, e := range entries {
+		copy(entry, e.hash[:])
+		binary.BigEndian.PutUint64(entry[32:], e.offset)
+		_, err = w.Write(entry)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stageIndex stages the index at indexFile of the calls file written to
+// callsTmp.
+func stageIndex(tx *transaction, indexFile, callsTmp string) error {
+	callsHash, err := FileSHA256(callsTmp)
+	if err != nil {
+		return err
+	}
+	return tx.stage(indexFile, func(tmp string) error {
+		f, err := os.Create(tmp)
+		if err != nil {
+			return err
+		}
+		err = writeIndex(f, callsTmp, callsHash)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		return err
+	})
+}
+
+// BuildIndex replaces the index at indexFile with one of the calls file at
+// callsFile, recording the checksum of the calls file OpenIndex relies on.
+func BuildIndex(callsFile, indexFile string) error {
+	hash, err := FileSHA256(callsFile)
+	if err != nil {
+		return err
+	}
+	var tx transaction
+	err = stageIndex(&tx, indexFile, callsFile)
+	if err == nil {
+		err = stageChecksum(&tx, callsFile, hash)
+	}
+	if err != nil {
+		tx.abort()
+		return err
+	}
+	return tx.commit()
+}
+
+// Index finds calls by Hash without reading the calls file.
+type Index struct {
+	f       *os.File
+	entries int64
+}
+
+// OpenIndex opens the index at indexFile of the calls file at callsFile. It
+// fails with ErrStaleIndex when the calls file no longer has the checksum the
+// index was built from.
+func OpenIndex(indexFile, callsFile string) (*Index, error) {
+	f, err := os.Open(indexFile)
+	if err != nil {
+		return nil, err
+	}
+	var header = make([]byte, indexHeaderSize)
+	_, err = io.ReadFull(f, header)
+	if err == nil && string(header[:len(indexMagic)]) != indexMagic {
+		err = fmt.Errorf("%s is %w", indexFile, ErrCorruptIndex)
+	}
+	var want string
+	if err == nil {
+		// the checksum file avoids hashing the calls file on every open
+		want, err = ReadChecksum(callsFile)
+	}
+	if err == nil && want != hex.EncodeToString(header[len(indexMagic):]) {
+		err = ErrStaleIndex
+	}
+	var info os.FileInfo
+	if err == nil {
+		info, err = f.Stat()
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &Index{f, (info.Size() - int64(indexHeaderSize)) / indexEntrySize}, nil
+}
+
+// Offset returns the offset, in the decompressed calls file, of the call with
+// the given Hash, and whether there is one.
+func (idx *Index) Offset(hash string) (int64, bool, error) {
+	want, err := hex.DecodeString(hash)
+	if err != nil || len(want) != 32 {
+		return 0, false, fmt.Errorf("%w %q", ErrInvalidHash, hash)
+	}
+
+	var entry = make([]byte, indexEntrySize)
+	var readErr error
+	var i = sort.Search(int(idx.entries), func(i int) bool {
+		if readErr != nil {
+			return true
+		}
+		_, readErr = idx.f.ReadAt(entry[:32], int64(indexHeaderSize)+int64(i)*indexEntrySize)
+		return bytes.Compare(entry[:32], want) >= 0
+	})
+	if readErr != nil {
+		return 0, false, readErr
+	}
+	if int64(i) >= idx.entries {
+		return 0, false, nil
+	}
+	_, err = idx.f.ReadAt(entry, int64(indexHeaderSize)+int64(i)*indexEntrySize)
+	if err != nil {
+		return 0, false, err
+	}
+	if !bytes.Equal(entry[:32], want) {
+		return 0, false, nil
+	}
+	return int64(binary.BigEndian.Uint64(entry[32:])), true, nil
+}
+
+// Contains reports whether the calls file has a call with the given Hash.
+func (idx *Index) Contains(hash string) (bool, error) {
+	_, ok, err := idx.Offset(hash)
+	return ok, err
+}
+
+// Close closes the index.
+func (idx *Index) Close() error {
+	return idx.f.Close()
+}
+
+// ReadCallAt decodes the call at offset, as returned by Index.Offset, of the
+// calls file at filePath.
+func ReadCallAt(filePath string, offset int64) (Call, error) {
+	xmlFile, err := Open(filePath)
+	if err != nil {
+		return Call{}, err
+	}
+	defer xmlFile.Close()
+
+	if seeker, ok := xmlFile.(io.Seeker); ok {
+		_, err = seeker.Seek(offset, io.SeekStart)
+	} else {
+		_, err = io.CopyN(io.Discard, xmlFile, offset)
+	}
+	if err != nil {
+		return Call{}, err
+	}
+	var call Call
+	err = xml.NewDecoder(xmlFile).Decode(&call)
+	return call, err
+}
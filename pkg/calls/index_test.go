@@ -0,0 +1,104 @@
+package calls
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIndex(t *testing.T) {
+	var all = []Call{
+		{Number: "1", Duration: "0", Date: 1388534400000, Type: Incoming},
+		{Number: "2", Duration: "5", Date: 1388534401000, Type: Outgoing},
+		{Number: "3", Duration: "0", Date: 1388534402000, Type: Missed},
+	}
+	for _, name := range []string{"calls.xml", "calls.xml.gz"} {
+		dir := t.TempDir()
+		file := filepath.Join(dir, name)
+		index := IndexFile(dir)
+		err := WriteCalls(file, all)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = BuildIndex(file, index)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		idx, err := OpenIndex(index, file)
+		if err != nil {
+			t.Fatalf("%s: open err got %v, want nil", name, err)
+		}
+		for _, want := range all {
+			offset, ok, err := idx.Offset(want.Hash())
+			if err != nil || !ok {
+				t.Fatalf("%s: call %s got ok %v, err %v", name, want.Number, ok, err)
+			}
+			got, err := ReadCallAt(file, offset)
+			if err != nil || !reflect.DeepEqual(got.Key(), want.Key()) {
+				t.Errorf("%s: call at %d got %+v, err %v, want %+v", name, offset, got, err, want)
+			}
+		}
+		if ok, err := idx.Contains(strings.Repeat("0", 64)); ok || err != nil {
+			t.Errorf("%s: unknown hash got %v, err %v", name, ok, err)
+		}
+		idx.Close()
+
+		previous, err := os.ReadFile(index)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = WriteCalls(file, all[:2])
+		if err != nil {
+			t.Fatal(err)
+		}
+		idx, err = OpenIndex(index, file)
+		if err != nil {
+			t.Fatalf("%s: after a rewrite got %v, want the index kept up to date", name, err)
+		}
+		if ok, err := idx.Contains(all[2].Hash()); ok || err != nil {
+			t.Errorf("%s: removed call got %v, err %v", name, ok, err)
+		}
+		idx.Close()
+
+		// an index of other calls is not used
+		err = os.WriteFile(index, previous, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := OpenIndex(index, file); !errors.Is(err, ErrStaleIndex) {
+			t.Errorf("%s: with the previous index got %v, want %v", name, err, ErrStaleIndex)
+		}
+	}
+}
+
+func TestFlushMaintainsIndex(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "calls.xml"), emptyCalls)
+	source := filepath.Join(dir, "calls-source.xml")
+	writeFile(t, source, `<calls count="1">
+  <call number="5555550013" duration="33" date="1388534400000" type="2" />
+</calls>`)
+
+	c := mustInit(t, dir, Options{Index: true})
+	_, err := c.Coalesce(source)
+	if err == nil {
+		err = c.Flush()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idx, err := OpenIndex(IndexFile(dir), RepositoryFile(dir))
+	if err != nil {
+		t.Fatalf("open err got %v, want nil", err)
+	}
+	defer idx.Close()
+	call := Call{Number: "5555550013", Duration: "33", Date: 1388534400000, Type: "2"}
+	if ok, err := idx.Contains(call.Hash()); !ok || err != nil {
+		t.Errorf("contains got %v, err %v, want true", ok, err)
+	}
+}
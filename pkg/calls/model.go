@@ -2,6 +2,7 @@ package calls
 
 import (
 	"encoding/xml"
+	"strconv"
 )
 
 type Calls struct {
@@ -11,11 +12,69 @@ type Calls struct {
 }
 
 type Call struct {
-	XMLName      xml.Name `xml:"call"`
-	Number       string   `xml:"number,attr"`
-	Duration     string   `xml:"duration,attr"`
-	Date         int      `xml:"date,attr"`
-	Type         string   `xml:"type,attr"`
-	ReadableDate string   `xml:"readable_date,attr"`
-	ContactName  string   `xml:"contact_name,attr"`
+	XMLName      xml.Name `xml:"call" json:"-"`
+	Number       string   `xml:"number,attr" json:"number"`
+	Duration     string   `xml:"duration,attr" json:"duration"`
+	Date         int      `xml:"date,attr" json:"date"`
+	Type         string   `xml:"type,attr" json:"type"`
+	ReadableDate string   `xml:"readable_date,attr,omitempty" json:"readable_date"`
+	ContactName  string   `xml:"contact_name,attr,omitempty" json:"contact_name"`
+	// Extra holds attributes written by newer backup versions (such as
+	// presentation or subscription_id) so they survive coalescing.
+	Extra []xml.Attr `xml:",any,attr" json:"-"`
+}
+
+// Call types as recorded in the type attribute.
+const (
+	Incoming  = "1"
+	Outgoing  = "2"
+	Missed    = "3"
+	Voicemail = "4"
+	Rejected  = "5"
+	Blocked   = "6"
+)
+
+// KnownType reports whether t is one of the call types above.
+func KnownType(t string) bool {
+	switch t {
+	case Incoming, Outgoing, Missed, Voicemail, Rejected, Blocked:
+		return true
+	}
+	return false
+}
+
+var typeNames = map[string]string{
+	Incoming:  "incoming",
+	Outgoing:  "outgoing",
+	Missed:    "missed",
+	Voicemail: "voicemail",
+	Rejected:  "rejected",
+	Blocked:   "blocked",
+}
+
+// TypeName returns the name of the call type t, such as "missed", or
+// "type 7" for types which are not known.
+func TypeName(t string) string {
+	if name, ok := typeNames[t]; ok {
+		return name
+	}
+	return "type " + t
+}
+
+// FilterField returns the named field for filtering: number (or address),
+// duration, date (epoch milliseconds), type or contact.
+func (call *Call) FilterField(name string) (string, bool) {
+	switch name {
+	case "number", "address":
+		return call.Number, true
+	case "duration":
+		return call.Duration, true
+	case "date":
+		return strconv.Itoa(call.Date), true
+	case "type":
+		return call.Type, true
+	case "contact":
+		return call.ContactName, true
+	}
+	return "", false
 }
@@ -0,0 +1,147 @@
+package calls
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"time"
+
+	"github.com/phillipgreen/mobilecombackup/pkg/throttle"
+)
+
+// Provenance records which backup file a call was first imported from.
+type Provenance struct {
+	// Record is the Hash of the call.
+	Record       string    `json:"record"`
+	Source       string    `json:"source"`
+	SourceSHA256 string    `json:"source_sha256"`
+	ImportedAt   time.Time `json:"imported_at"`
+	// Format is the SourceFormat the source was detected as.
+	Format string `json:"format,omitempty"`
+}
+
+// Hash identifies the call as stored in the repository.
+func (call *Call) Hash() string {
+	var k = call.Key()
+	var sum = sha256.Sum256([]byte(fmt.Sprintf("%s\x00%s\x00%d\x00%s", k.Number, k.Duration, k.Date, k.Type)))
+	return hex.EncodeToString(sum[:])
+}
+
+// ProvenanceFile returns the path of the provenance store of the repository
+// at rootDir.
+func ProvenanceFile(rootDir string) string {
+	return filepath.Join(rootDir, "provenance", "calls.jsonl")
+}
+
+// FileSHA256 returns the hex SHA-256 of the file at filePath as stored, without
+// decompressing it.
+func FileSHA256(filePath string) (string, error) {
+	return fileSHA256(filePath, nil)
+}
+
+// fileSHA256 is FileSHA256 reading the file at the rate of l.
+func fileSHA256(filePath string, l *throttle.Limiter) (string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var h = sha256.New()
+	_, err = io.Copy(h, throttle.Reader(f, l))
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// StreamProvenance invokes callback for each entry of the provenance store at
+// filePath. A missing store has no entries.
+func StreamProvenance(filePath string, callback func(Provenance) error) error {
+	f, err := os.Open(filePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return streamProvenance(f, filePath, callback)
+}
+
+// streamProvenance is StreamProvenance for the store read from r, named
+// fileName in errors.
+func streamProvenance(r io.Reader, fileName string, callback func(Provenance) error) error {
+	var p Provenance
+	return streamJSONLines(r, fileName, &p, func() error { return callback(p) })
+}
+
+// streamJSONLines decodes each line of r, a file of one JSON value per line
+// named fileName in errors, into the zeroed value v points to and then
+// invokes callback.
+func streamJSONLines(r io.Reader, fileName string, v interface{}, callback func() error) error {
+	var zero = reflect.Zero(reflect.TypeOf(v).Elem())
+	var scanner = bufio.NewScanner(r)
+	var line int
+	for scanner.Scan() {
+		line++
+		reflect.ValueOf(v).Elem().Set(zero)
+		err := json.Unmarshal(scanner.Bytes(), v)
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", fileName, line, err)
+		}
+		err = callback()
+		if err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// appendProvenance stages the provenance store at filePath with entries
+// added to it.
+func appendProvenance(tx *transaction, filePath string, entries []Provenance) error {
+	var values = make([]interface{}, len(entries))
+	for i := range entries {
+		values[i] = entries[i]
+	}
+	return appendJSONLines(tx, filePath, values)
+}
+
+// appendJSONLines stages the file at filePath with a line of JSON added to it
+// for each of values.
+func appendJSONLines(tx *transaction, filePath string, values []interface{}) error {
+	existing, err := os.ReadFile(filePath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return tx.stage(filePath, func(tmp string) error {
+		f, err := os.Create(tmp)
+		if err != nil {
+			return err
+		}
+
+		var w = bufio.NewWriter(f)
+		_, err = w.Write(existing)
+		var encoder = json.NewEncoder(w)
+		for _, v := range values {
+			if err != nil {
+				break
+			}
+			err = encoder.Encode(v)
+		}
+		if err == nil {
+			err = w.Flush()
+		}
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		return err
+	})
+}
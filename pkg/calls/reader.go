@@ -0,0 +1,231 @@
+package calls
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/phillipgreen/mobilecombackup/pkg/throttle"
+)
+
+// RepositoryFile returns the path of the calls file within the repository at
+// rootDir. This is calls.xml unless only a compressed calls.xml.gz exists.
+func RepositoryFile(rootDir string) string {
+	var plain = filepath.Join(rootDir, "calls.xml")
+	if _, err := os.Stat(plain); err != nil {
+		if _, err := os.Stat(plain + CompressedSuffix); err == nil {
+			return plain + CompressedSuffix
+		}
+	}
+	return plain
+}
+
+// Time returns the moment the call took place.
+func (call *Call) Time() time.Time {
+	return time.Unix(0, int64(call.Date)*int64(time.Millisecond))
+}
+
+// ReadableDateLayout is the layout of the readable_date attribute.
+const ReadableDateLayout = "Jan 2, 2006 3:04:05 PM"
+
+// FormatReadableDate returns the moment the call took place in loc, formatted
+// as the readable_date attribute.
+func (call *Call) FormatReadableDate(loc *time.Location) string {
+	return call.Time().In(loc).Format(ReadableDateLayout)
+}
+
+// StreamCalls decodes the calls file at filePath, invoking callback for each
+// call in file order. Iteration stops at the first error from callback.
+func StreamCalls(filePath string, callback func(Call) error) error {
+	xmlFile, err := Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer xmlFile.Close()
+
+	return StreamCallsFromReader(xmlFile, callback)
+}
+
+// StreamCallsFromReader is StreamCalls for calls XML read from r, which is not
+// decompressed.
+func StreamCallsFromReader(r io.Reader, callback func(Call) error) error {
+	decoder := xml.NewDecoder(r)
+	for {
+		t, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		se, ok := t.(xml.StartElement)
+		if !ok || se.Name.Local != "call" {
+			continue
+		}
+		var call Call
+		err = decoder.DecodeElement(&call, &se)
+		if err != nil {
+			return err
+		}
+		err = callback(call)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// withContext wraps callback so that streaming stops once ctx is done.
+func withContext(ctx context.Context, callback func(Call) error) func(Call) error {
+	return func(c Call) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return callback(c)
+	}
+}
+
+// StreamCallsContext is StreamCalls which stops with ctx.Err() once ctx is
+// done.
+func StreamCallsContext(ctx context.Context, filePath string, callback func(Call) error) error {
+	return StreamCalls(filePath, withContext(ctx, callback))
+}
+
+// StreamCallsBetween invokes callback for each call in the calls file at
+// filePath which took place at or after start and before end. A zero start or
+// end leaves that side of the range open. The whole file is read, as it may
+// not be ordered by date.
+func StreamCallsBetween(filePath string, start, end time.Time, callback func(Call) error) error {
+	return StreamCalls(filePath, inRange(start, end, callback))
+}
+
+// inRange wraps callback so that it skips calls outside of start and end.
+func inRange(start, end time.Time, callback func(Call) error) func(Call) error {
+	return func(c Call) error {
+		var t = c.Time()
+		if !end.IsZero() && !t.Before(end) {
+			return nil
+		}
+		if !start.IsZero() && t.Before(start) {
+			return nil
+		}
+		return callback(c)
+	}
+}
+
+// StreamCallsBetweenContext is StreamCallsBetween which stops with ctx.Err()
+// once ctx is done.
+func StreamCallsBetweenContext(ctx context.Context, filePath string, start, end time.Time, callback func(Call) error) error {
+	return StreamCallsBetween(filePath, start, end, withContext(ctx, callback))
+}
+
+// ReadCalls loads all calls from the calls file at filePath.
+func ReadCalls(filePath string) ([]Call, error) {
+	return ReadCallsContext(context.Background(), filePath)
+}
+
+// ReadCallsContext is ReadCalls which stops with ctx.Err() once ctx is done.
+func ReadCallsContext(ctx context.Context, filePath string) ([]Call, error) {
+	var calls []Call
+	err := StreamCallsContext(ctx, filePath, func(c Call) error {
+		calls = append(calls, c)
+		return nil
+	})
+	return calls, err
+}
+
+// GetAllYears returns the UTC years of the calls in the calls file at
+// filePath, in ascending order.
+func GetAllYears(filePath string) ([]int, error) {
+	var seen = map[int]bool{}
+	var years []int
+	err := StreamCalls(filePath, func(c Call) error {
+		var year = c.Time().UTC().Year()
+		if !seen[year] {
+			seen[year] = true
+			years = append(years, year)
+		}
+		return nil
+	})
+	sort.Ints(years)
+	return years, err
+}
+
+// ErrCountMismatch is returned by VerifyCount when the count attribute of a
+// calls file does not match the calls it contains.
+var ErrCountMismatch = errors.New("count attribute mismatch")
+
+// CountCalls returns the number of calls in the calls file at filePath
+// without decoding them.
+func CountCalls(filePath string) (int, error) {
+	return CountCallsContext(context.Background(), filePath)
+}
+
+// CountCallsContext is CountCalls which stops with ctx.Err() once ctx is done.
+func CountCallsContext(ctx context.Context, filePath string) (int, error) {
+	count, _, err := countCalls(ctx, filePath, nil)
+	return count, err
+}
+
+// VerifyCount checks the count attribute of the calls file at filePath
+// against the calls it contains. A file without the attribute passes.
+func VerifyCount(filePath string) error {
+	count, attr, err := countCalls(context.Background(), filePath, nil)
+	if err != nil || attr == "" {
+		return err
+	}
+	declared, err := strconv.Atoi(attr)
+	if err != nil {
+		return fmt.Errorf("%w: %s has count attribute %q", ErrCountMismatch, filePath, attr)
+	}
+	if declared != count {
+		return fmt.Errorf("%w: %s declares %d calls but contains %d", ErrCountMismatch, filePath, declared, count)
+	}
+	return nil
+}
+
+// countCalls returns the number of calls in the calls file at filePath and its
+// count attribute, which is empty when it is missing. The file is read at the
+// rate of l.
+func countCalls(ctx context.Context, filePath string, l *throttle.Limiter) (count int, declared string, err error) {
+	xmlFile, err := openLimited(filePath, l)
+	if err != nil {
+		return 0, "", err
+	}
+	defer xmlFile.Close()
+
+	decoder := xml.NewDecoder(xmlFile)
+	for {
+		t, err := decoder.Token()
+		if err == io.EOF {
+			return count, declared, nil
+		}
+		if err != nil {
+			return count, declared, err
+		}
+		se, ok := t.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch se.Name.Local {
+		case "calls":
+			for _, a := range se.Attr {
+				if a.Name.Local == "count" {
+					declared = a.Value
+				}
+			}
+		case "call":
+			if err := ctx.Err(); err != nil {
+				return count, declared, err
+			}
+			count++
+		}
+	}
+}
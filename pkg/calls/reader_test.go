@@ -0,0 +1,77 @@
+package calls
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStreamCallsBetween(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "calls.xml")
+	// calls past the range do not hide the unordered ones after them
+	writeFile(t, file, `<calls count="5">
+  <call number="1" duration="0" date="1388534399000" type="1" />
+  <call number="2" duration="0" date="1388534400000" type="1" />
+  <call number="4" duration="0" date="1420070400000" type="1" />
+  <call number="3" duration="0" date="1420070399000" type="1" />
+  <call number="0" duration="0" date="1388534398000" type="1" />
+</calls>`)
+
+	var y2014 = time.Date(2014, time.January, 1, 0, 0, 0, 0, time.UTC)
+	var y2015 = time.Date(2015, time.January, 1, 0, 0, 0, 0, time.UTC)
+	var tests = []struct {
+		desc       string
+		start, end time.Time
+		want       []string
+	}{
+		{"year", y2014, y2015, []string{"2", "3"}},
+		{"open start", time.Time{}, y2014, []string{"1", "0"}},
+		{"empty", y2015, y2015, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			var got []string
+			err := StreamCallsBetween(file, tt.start, tt.end, func(c Call) error {
+				got = append(got, c.Number)
+				return nil
+			})
+			if err != nil {
+				t.Errorf("err got %v, want nil", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetAllYearsAndVerifyCount(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "calls.xml")
+	writeFile(t, file, `<calls count="3">
+  <call number="1" duration="0" date="1420070400000" type="1" />
+  <call number="2" duration="0" date="1388534399000" type="1" />
+</calls>`)
+
+	years, err := GetAllYears(file)
+	if err != nil || !reflect.DeepEqual(years, []int{2013, 2015}) {
+		t.Errorf("years got %v, err %v, want [2013 2015]", years, err)
+	}
+	if err := VerifyCount(file); !errors.Is(err, ErrCountMismatch) {
+		t.Errorf("count got %v, want %v", err, ErrCountMismatch)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ReadCallsContext(ctx, file); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled read got %v, want %v", err, context.Canceled)
+	}
+	if _, err := CountCallsContext(ctx, file); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled count got %v, want %v", err, context.Canceled)
+	}
+}
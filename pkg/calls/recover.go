@@ -0,0 +1,47 @@
+package calls
+
+import (
+	"bytes"
+	"io"
+	"unicode/utf8"
+)
+
+// isXMLChar reports whether r may appear in an XML document.
+func isXMLChar(r rune) bool {
+	return r == '\t' || r == '\n' || r == '\r' ||
+		r >= 0x20 && r <= 0xD7FF ||
+		r >= 0xE000 && r <= 0xFFFD ||
+		r >= 0x10000 && r <= utf8.MaxRune
+}
+
+// sanitizeXML replaces invalid UTF-8 and characters which XML does not allow
+// with utf8.RuneError, returning the result and how many were replaced.
+func sanitizeXML(data []byte) ([]byte, int) {
+	var out = make([]byte, 0, len(data))
+	var replaced int
+	for len(data) > 0 {
+		r, size := utf8.DecodeRune(data)
+		if r == utf8.RuneError && size == 1 || !isXMLChar(r) {
+			out = append(out, string(utf8.RuneError)...)
+			replaced++
+		} else {
+			out = append(out, data[:size]...)
+		}
+		data = data[size:]
+	}
+	return out, replaced
+}
+
+// recoverCalls is parseCalls for damaged files: illegal characters are
+// replaced, unknown entities and stray ampersands are kept as text, and the
+// calls before unreadable trailing content are kept. It also returns how many
+// characters were replaced.
+func recoverCalls(file io.Reader, fileName string) ([]Call, []Rejection, int, error) {
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	sanitized, replaced := sanitizeXML(data)
+	staged, rejected := decodeCalls(bytes.NewReader(sanitized), fileName, false)
+	return staged, rejected, replaced, nil
+}
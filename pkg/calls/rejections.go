@@ -0,0 +1,150 @@
+package calls
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// RejectionsDir is the directory of the repository holding rejected calls.
+const RejectionsDir = "rejected"
+
+// Rejection is a call element which could not be parsed, kept exactly as it
+// was read so that it can be fixed and imported again.
+type Rejection struct {
+	XMLName xml.Name `xml:"rejection" json:"-"`
+	// File is the path of the file the element was read from.
+	File   string `xml:"file,attr" json:"file"`
+	Line   int    `xml:"line,attr" json:"line"`
+	Reason string `xml:"reason,attr" json:"reason"`
+	// Element is the original XML of the call.
+	Element string `xml:",chardata" json:"-"`
+
+	err error
+}
+
+type rejections struct {
+	XMLName    xml.Name    `xml:"rejections"`
+	Rejections []Rejection `xml:"rejection"`
+}
+
+// rejectionIndex lists the rejections of a rejections file without their
+// elements.
+type rejectionIndex struct {
+	File       string      `json:"rejections_file"`
+	Rejections []Rejection `json:"rejections"`
+}
+
+// recordingReader keeps what was read through it since the last discard so
+// elements can be recovered by offset, along with the line the kept bytes
+// start on.
+type recordingReader struct {
+	r io.Reader
+	// buf[off:] holds the bytes read from offset base on
+	buf  []byte
+	off  int
+	base int64
+	// lines counts the newlines before base
+	lines int
+}
+
+func (rr *recordingReader) Read(p []byte) (int, error) {
+	n, err := rr.r.Read(p)
+	rr.buf = append(rr.buf, p[:n]...)
+	return n, err
+}
+
+// discard forgets the bytes before offset, which no rejection will need.
+func (rr *recordingReader) discard(offset int64) {
+	var n = int(offset - rr.base)
+	if n <= 0 {
+		return
+	}
+	if n > len(rr.buf)-rr.off {
+		n = len(rr.buf) - rr.off
+	}
+	rr.lines += bytes.Count(rr.buf[rr.off:rr.off+n], []byte("\n"))
+	rr.off += n
+	rr.base += int64(n)
+	// the kept bytes are moved down once they are at most half the buffer,
+	// so each byte is copied a bounded number of times
+	if rr.off > len(rr.buf)/2 {
+		rr.buf = rr.buf[:copy(rr.buf, rr.buf[rr.off:])]
+		rr.off = 0
+	}
+}
+
+// rejection describes the element between the start and end offsets, which
+// must not have been discarded.
+func (rr *recordingReader) rejection(fileName string, start, end int64, err error) Rejection {
+	var kept = rr.buf[rr.off:]
+	var from, to = start - rr.base, end - rr.base
+	if from < 0 {
+		from = 0
+	}
+	if to > int64(len(kept)) {
+		to = int64(len(kept))
+	}
+	if from > to {
+		from = to
+	}
+	return Rejection{
+		File:    fileName,
+		Line:    rr.lines + bytes.Count(kept[:from], []byte("\n")) + 1,
+		Reason:  err.Error(),
+		Element: string(kept[from:to]),
+		err:     err,
+	}
+}
+
+// RejectionsFile returns the path of the file holding the calls rejected on
+// the day of t in the repository at rootDir.
+func RejectionsFile(rootDir string, t time.Time) string {
+	return filepath.Join(rootDir, RejectionsDir, "rejections-"+t.Format("20060102")+".xml")
+}
+
+// ReadRejections loads the rejections file at filePath.
+func ReadRejections(filePath string) ([]Rejection, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	var all rejections
+	err = xml.Unmarshal(content, &all)
+	return all.Rejections, err
+}
+
+// appendRejections stages the rejections file at filePath with rs added to it,
+// along with its JSON index next to it.
+func appendRejections(tx *transaction, filePath string, rs []Rejection) error {
+	existing, err := ReadRejections(filePath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	var all = rejections{Rejections: append(existing, rs...)}
+
+	out, err := xml.MarshalIndent(all, "", "\t")
+	if err != nil {
+		return err
+	}
+	err = tx.stage(filePath, func(tmp string) error {
+		return os.WriteFile(tmp, append([]byte(xml.Header), out...), 0644)
+	})
+	if err != nil {
+		return err
+	}
+
+	index, err := json.MarshalIndent(rejectionIndex{filepath.Base(filePath), all.Rejections}, "", "  ")
+	if err != nil {
+		return err
+	}
+	var indexFile = filePath[:len(filePath)-len(filepath.Ext(filePath))] + ".json"
+	return tx.stage(indexFile, func(tmp string) error {
+		return os.WriteFile(tmp, append(index, '\n'), 0644)
+	})
+}
@@ -0,0 +1,106 @@
+package calls
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/phillipgreen/mobilecombackup/pkg/coalescer"
+)
+
+func TestFlushPersistsRejections(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "calls.xml"), emptyCalls)
+	source := filepath.Join(dir, "calls-source.xml")
+	var bad = `<call number="5555550014" duration="33" date="yesterday" type="2" contact_name="A &amp; B" />`
+	writeFile(t, source, `<calls count="2">
+  <call number="5555550013" duration="33" date="1415054053956" type="2" />
+  `+bad+`
+</calls>`)
+
+	c := mustInit(t, dir, Options{OnParseError: coalescer.SkipRecord})
+	_, err := c.Coalesce(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rejectionsFile = RejectionsFile(dir, time.Now())
+	rejected, err := ReadRejections(rejectionsFile)
+	if err != nil {
+		t.Fatalf("err got %v, want nil", err)
+	}
+	if len(rejected) != 1 {
+		t.Fatalf("rejections got %d, want 1", len(rejected))
+	}
+	var r = rejected[0]
+	if r.File != source || r.Line != 3 || r.Element != bad || !strings.Contains(r.Reason, "yesterday") {
+		t.Errorf("rejection got %+v", r)
+	}
+
+	index, err := os.ReadFile(strings.TrimSuffix(rejectionsFile, ".xml") + ".json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(index), `"line": 3`) {
+		t.Errorf("index got %s, want the rejection listed", index)
+	}
+
+	// a second flush does not repeat the rejection
+	err = c.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rejected, err = ReadRejections(rejectionsFile)
+	if err != nil || len(rejected) != 1 {
+		t.Errorf("after second flush got %d rejections, err %v", len(rejected), err)
+	}
+}
+
+func TestParseCallsRejectionLine(t *testing.T) {
+	var sb strings.Builder
+	sb.WriteString("<calls count=\"5001\">\n")
+	for i := 0; i < 5000; i++ {
+		fmt.Fprintf(&sb, "  <call number=\"5555550013\" duration=\"33\" date=\"%d\" type=\"2\" />\n", 1415054053956+i)
+	}
+	var bad = `<call number="5555550014" duration="33" date="yesterday" type="2" />`
+	sb.WriteString("  " + bad + "\n</calls>\n")
+
+	staged, rejected := parseCalls(strings.NewReader(sb.String()), "calls.xml")
+	if len(staged) != 5000 || len(rejected) != 1 {
+		t.Fatalf("staged/rejected got %d/%d, want 5000/1", len(staged), len(rejected))
+	}
+	if rejected[0].Line != 5002 || rejected[0].Element != bad {
+		t.Errorf("rejection got line %d, element %q", rejected[0].Line, rejected[0].Element)
+	}
+}
+
+func TestRecordingReaderDiscards(t *testing.T) {
+	var line = "<call />\n"
+	var recorder = &recordingReader{r: strings.NewReader(strings.Repeat(line, 10000))}
+	var p = make([]byte, 64)
+	var offset int64
+	for {
+		n, err := recorder.Read(p)
+		offset += int64(n)
+		if err != nil {
+			break
+		}
+		recorder.discard(offset - int64(len(line)))
+		if len(recorder.buf) > 4*len(p) {
+			t.Fatalf("kept %d bytes, want at most %d", len(recorder.buf), 4*len(p))
+		}
+	}
+	var last = offset - int64(len(line))
+	var r = recorder.rejection("calls.xml", last, offset, errors.New("bad"))
+	if r.Line != 10000 || r.Element != line {
+		t.Errorf("rejection got line %d, element %q", r.Line, r.Element)
+	}
+}
@@ -0,0 +1,167 @@
+package calls
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"time"
+)
+
+// ErrSnapshotBusy is returned by OpenSnapshot when the repository kept
+// changing while the snapshot was being opened.
+var ErrSnapshotBusy = errors.New("repository changed while opening a snapshot")
+
+// How often, and how long apart, OpenSnapshot tries again while a commit is
+// in progress.
+var (
+	snapshotAttempts = 50
+	snapshotWait     = 20 * time.Millisecond
+)
+
+// openSnapshotCalls opens the calls file of a snapshot once its path has been
+// chosen; tests replace it to commit in between.
+var openSnapshotCalls = os.Open
+
+// Snapshot is a consistent view of the calls and provenance of a repository.
+// Commits made while it is open replace the files rather than modify them, so
+// the snapshot keeps reading the files it opened. It may be read from several
+// goroutines at once.
+type Snapshot struct {
+	// Generation is the commit count of the repository the snapshot shows.
+	Generation uint64
+	callsFile  string
+	calls      *os.File
+	provenance *os.File
+}
+
+// OpenSnapshot opens a snapshot of the repository at rootDir, waiting for a
+// commit in progress to complete.
+func OpenSnapshot(rootDir string) (*Snapshot, error) {
+	return OpenSnapshotContext(context.Background(), rootDir)
+}
+
+// OpenSnapshotContext is OpenSnapshot which stops waiting with ctx.Err() once
+// ctx is done.
+func OpenSnapshotContext(ctx context.Context, rootDir string) (*Snapshot, error) {
+	var generationFile = GenerationFile(rootDir)
+	for attempt := 0; attempt < snapshotAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(snapshotWait):
+			}
+		}
+
+		before, err := ReadGeneration(generationFile)
+		if err != nil {
+			return nil, err
+		}
+		if before%2 == 1 {
+			continue
+		}
+		s, err := openSnapshotFiles(rootDir)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		// a commit converting the calls file between compressed and plain
+		// removes the one whose path was chosen before it could be opened;
+		// without a commit, a missing file stays missing
+		after, gerr := ReadGeneration(generationFile)
+		if gerr != nil || after != before {
+			if s != nil {
+				s.Close()
+			}
+			if gerr != nil {
+				return nil, gerr
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		s.Generation = before
+		return s, nil
+	}
+	return nil, ErrSnapshotBusy
+}
+
+func openSnapshotFiles(rootDir string) (*Snapshot, error) {
+	var s = Snapshot{callsFile: RepositoryFile(rootDir)}
+	var err error
+	s.calls, err = openSnapshotCalls(s.callsFile)
+	if err != nil {
+		return nil, err
+	}
+	s.provenance, err = os.Open(ProvenanceFile(rootDir))
+	if errors.Is(err, os.ErrNotExist) {
+		s.provenance, err = nil, nil
+	}
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+	return &s, nil
+}
+
+// section returns a reader of the whole of f, independent of other readers.
+func section(f *os.File) (io.Reader, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return io.NewSectionReader(f, 0, info.Size()), nil
+}
+
+// StreamCalls invokes callback for each call of the snapshot in file order,
+// stopping at the first error from callback or once ctx is done.
+func (s *Snapshot) StreamCalls(ctx context.Context, callback func(Call) error) error {
+	r, err := section(s.calls)
+	if err != nil {
+		return err
+	}
+	if IsCompressed(s.callsFile) {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	return StreamCallsFromReader(r, withContext(ctx, callback))
+}
+
+// StreamCallsBetween is StreamCalls for the calls which took place at or
+// after start and before end, as with the package level StreamCallsBetween.
+func (s *Snapshot) StreamCallsBetween(ctx context.Context, start, end time.Time, callback func(Call) error) error {
+	return s.StreamCalls(ctx, inRange(start, end, callback))
+}
+
+// StreamProvenance invokes callback for each provenance entry of the
+// snapshot.
+func (s *Snapshot) StreamProvenance(callback func(Provenance) error) error {
+	if s.provenance == nil {
+		return nil
+	}
+	r, err := section(s.provenance)
+	if err != nil {
+		return err
+	}
+	return streamProvenance(r, s.provenance.Name(), callback)
+}
+
+// Close releases the files of the snapshot.
+func (s *Snapshot) Close() error {
+	var err error
+	if s.calls != nil {
+		err = s.calls.Close()
+	}
+	if s.provenance != nil {
+		if cerr := s.provenance.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
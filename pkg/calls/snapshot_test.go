@@ -0,0 +1,87 @@
+package calls
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	var first = []Call{{Number: "1", Duration: "0", Date: 1388534400000, Type: Incoming}}
+	err := WriteCalls(RepositoryFile(dir), first)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := OpenSnapshot(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if s.Generation != 2 {
+		t.Errorf("generation got %d, want 2", s.Generation)
+	}
+
+	err = WriteCalls(RepositoryFile(dir), append(first, Call{Number: "2", Duration: "5", Date: 1388534401000, Type: Outgoing}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var count int
+	err = s.StreamCalls(context.Background(), func(Call) error {
+		count++
+		return nil
+	})
+	if err != nil || count != 1 {
+		t.Errorf("snapshot got %d calls, err %v, want the 1 call it was opened with", count, err)
+	}
+
+	// a commit in progress holds an odd generation
+	err = writeGeneration(GenerationFile(dir), 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshotAttempts, snapshotWait = 2, time.Millisecond
+	t.Cleanup(func() { snapshotAttempts, snapshotWait = 50, 20*time.Millisecond })
+	if _, err := OpenSnapshot(dir); !errors.Is(err, ErrSnapshotBusy) {
+		t.Errorf("during a commit got %v, want %v", err, ErrSnapshotBusy)
+	}
+
+	if _, err := OpenSnapshot(filepath.Join(dir, "missing")); err == nil {
+		t.Errorf("missing repository got nil, want an error")
+	}
+}
+
+func TestSnapshotDuringConversion(t *testing.T) {
+	dir := t.TempDir()
+	var plain = RepositoryFile(dir)
+	err := WriteCalls(plain, []Call{{Number: "1", Duration: "0", Date: 1388534400000, Type: Incoming}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// compress commits after the snapshot chose the plain file
+	var converted bool
+	openSnapshotCalls = func(name string) (*os.File, error) {
+		if !converted {
+			converted = true
+			if err := ConvertCalls(plain, plain+CompressedSuffix); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return os.Open(name)
+	}
+	t.Cleanup(func() { openSnapshotCalls = os.Open })
+
+	s, err := OpenSnapshot(dir)
+	if err != nil {
+		t.Fatalf("opening during a conversion got %v", err)
+	}
+	defer s.Close()
+	if s.Generation != 4 || !IsCompressed(s.callsFile) {
+		t.Errorf("snapshot got generation %d of %s, want 4 of the compressed file", s.Generation, s.callsFile)
+	}
+}
@@ -0,0 +1,154 @@
+package calls
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"regexp"
+	"strconv"
+	"unicode/utf8"
+)
+
+// sbrApp is the name SMS Backup & Restore writes in the files it creates.
+const sbrApp = "SMS Backup & Restore"
+
+// sniffSize is how much of a file DetectSource looks at; the prolog and the
+// root element fit well within it.
+const sniffSize = 4096
+
+// SourceFormat describes the app which wrote a backup file, as far as it
+// can be told from the file.
+type SourceFormat struct {
+	App     string `json:"app,omitempty"`
+	Version string `json:"version,omitempty"`
+}
+
+// String returns the app and version, or "unknown format".
+func (f SourceFormat) String() string {
+	switch {
+	case f.App == "":
+		return "unknown format"
+	case f.Version == "":
+		return f.App
+	}
+	return f.App + " " + f.Version
+}
+
+// createdBy matches the comment SMS Backup & Restore puts in the prolog, such
+// as "File Created By SMS Backup & Restore v7.21 on 04/11/2014 07:01:35".
+var createdBy = regexp.MustCompile(`File Created By (.+?) v([0-9][0-9.]*)`)
+
+// DetectSource reads the prolog and root element of the backup at the start
+// of r, without consuming it, to tell which app wrote it. Newer versions of
+// SMS Backup & Restore no longer write the comment but mark the root
+// element with backup_set and backup_date.
+func DetectSource(r *bufio.Reader) SourceFormat {
+	head, _ := r.Peek(sniffSize)
+	var f SourceFormat
+	decoder := xml.NewDecoder(bytes.NewReader(head))
+	decoder.Strict = false
+	for {
+		t, err := decoder.Token()
+		if err != nil {
+			return f
+		}
+		switch t := t.(type) {
+		case xml.Comment:
+			if m := createdBy.FindSubmatch(t); m != nil {
+				f.App, f.Version = string(m[1]), string(m[2])
+			}
+		case xml.StartElement:
+			for _, a := range t.Attr {
+				if f.App == "" && (a.Name.Local == "backup_set" || a.Name.Local == "backup_date") {
+					f.App = sbrApp
+				}
+			}
+			return f
+		}
+	}
+}
+
+// sourceQuirks are the deviations from well formed XML a source is known to
+// produce, which are corrected before the file is decoded.
+type sourceQuirks struct {
+	// surrogateReferences is set when characters outside the Basic
+	// Multilingual Plane, such as emoji, may be written as a pair of UTF-16
+	// surrogate character references, which XML does not allow.
+	surrogateReferences bool
+}
+
+func (f SourceFormat) quirks() sourceQuirks {
+	return sourceQuirks{surrogateReferences: f.App == sbrApp}
+}
+
+// apply returns r with the quirks corrected.
+func (q sourceQuirks) apply(r io.Reader) io.Reader {
+	if q.surrogateReferences {
+		r = &surrogateReader{r: bufio.NewReader(r)}
+	}
+	return r
+}
+
+var decimalReference = regexp.MustCompile(`&#([0-9]+);`)
+
+// joinSurrogateReferences replaces pairs of surrogate character references in
+// line with a reference to the character they encode, and lone surrogate
+// references with one to utf8.RuneError.
+func joinSurrogateReferences(line []byte) []byte {
+	var matches = decimalReference.FindAllSubmatchIndex(line, -1)
+	if matches == nil {
+		return line
+	}
+	var surrogate = func(m []int) int {
+		v, err := strconv.Atoi(string(line[m[2]:m[3]]))
+		if err != nil || v < 0xD800 || v > 0xDFFF {
+			return 0
+		}
+		return v
+	}
+
+	var out = make([]byte, 0, len(line))
+	var last int
+	for i := 0; i < len(matches); i++ {
+		var m = matches[i]
+		var high = surrogate(m)
+		if high == 0 {
+			continue
+		}
+		out = append(out, line[last:m[0]]...)
+		last = m[1]
+		var r = utf8.RuneError
+		if i+1 < len(matches) && high < 0xDC00 && matches[i+1][0] == m[1] {
+			if low := surrogate(matches[i+1]); low >= 0xDC00 {
+				r = 0x10000 + rune(high-0xD800)<<10 + rune(low-0xDC00)
+				last = matches[i+1][1]
+				i++
+			}
+		}
+		out = append(out, "&#"+strconv.Itoa(int(r))+";"...)
+	}
+	return append(out, line[last:]...)
+}
+
+// surrogateReader corrects surrogate character references line by line;
+// a reference never spans lines.
+type surrogateReader struct {
+	r   *bufio.Reader
+	buf []byte
+	err error
+}
+
+func (s *surrogateReader) Read(p []byte) (int, error) {
+	for len(s.buf) == 0 {
+		if s.err != nil {
+			return 0, s.err
+		}
+		var line []byte
+		line, s.err = s.r.ReadBytes('\n')
+		s.buf = joinSurrogateReferences(line)
+	}
+	n := copy(p, s.buf)
+	s.buf = s.buf[n:]
+	return n, nil
+}
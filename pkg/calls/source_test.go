@@ -0,0 +1,89 @@
+package calls
+
+import (
+	"bufio"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDetectSource(t *testing.T) {
+	var tests = []struct {
+		desc string
+		head string
+		want SourceFormat
+	}{
+		{"comment",
+			"<?xml version='1.0' encoding='UTF-8' standalone='yes' ?>\n<!--File Created By SMS Backup & Restore v7.21 on 04/11/2014 07:01:35-->\n<calls count=\"0\">",
+			SourceFormat{sbrApp, "7.21"}},
+		{"backup set",
+			"<?xml version='1.0' encoding='UTF-8' standalone='yes' ?>\n<calls count=\"0\" backup_set=\"a1\" backup_date=\"1600000000000\" type=\"full\">",
+			SourceFormat{App: sbrApp}},
+		{"unknown", emptyCalls, SourceFormat{}},
+	}
+	for _, tt := range tests {
+		var r = bufio.NewReader(strings.NewReader(tt.head))
+		if got := DetectSource(r); got != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.desc, got, tt.want)
+		}
+		if rest, _ := io.ReadAll(r); string(rest) != tt.head {
+			t.Errorf("%s: consumed the file, left %q", tt.desc, rest)
+		}
+	}
+}
+
+func TestJoinSurrogateReferences(t *testing.T) {
+	var tests = []struct {
+		in, want string
+	}{
+		{`contact_name="Hi &#55357;&#56832;!"`, `contact_name="Hi &#128512;!"`},
+		{`contact_name="&#55357;x&#56832;"`, `contact_name="&#65533;x&#65533;"`},
+		{`contact_name="&#233;&#38;"`, `contact_name="&#233;&#38;"`},
+	}
+	for _, tt := range tests {
+		if got := string(joinSurrogateReferences([]byte(tt.in))); got != tt.want {
+			t.Errorf("joinSurrogateReferences(%q) got %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCoalesceSurrogateReferences(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "calls.xml"), emptyCalls)
+	source := filepath.Join(dir, "calls-source.xml")
+	writeFile(t, source, `<?xml version='1.0' encoding='UTF-8' standalone='yes' ?>
+<!--File Created By SMS Backup & Restore v7.21 on 04/11/2014 07:01:35-->
+<calls count="1">
+  <call number="5555550013" duration="33" date="1415054053000" type="2" contact_name="Smile &#55357;&#56832;" />
+</calls>`)
+
+	c := mustInit(t, dir, Options{})
+	result, err := c.Coalesce(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.New != 1 || result.Rejected != 0 {
+		t.Fatalf("result got %+v, want 1 new call", result)
+	}
+	err = c.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+	all, err := ReadCalls(RepositoryFile(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if all[0].ContactName != "Smile \U0001F600" {
+		t.Errorf("contact got %q", all[0].ContactName)
+	}
+	err = StreamProvenance(ProvenanceFile(dir), func(p Provenance) error {
+		if p.Format != "SMS Backup & Restore 7.21" {
+			t.Errorf("provenance format got %q", p.Format)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,255 @@
+package calls
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// UnknownContact is the contact_name backups give calls with numbers which
+// are not in the contacts of the phone.
+const UnknownContact = "(Unknown)"
+
+// ContactStats aggregates the calls with a single contact.
+type ContactStats struct {
+	Contact     string `json:"contact"`
+	Calls       int    `json:"calls"`
+	Incoming    int    `json:"incoming"`
+	Outgoing    int    `json:"outgoing"`
+	Missed      int    `json:"missed"`
+	TalkSeconds int    `json:"talk_seconds"`
+}
+
+// Stats aggregates a set of calls.
+type Stats struct {
+	Calls     int `json:"calls"`
+	Incoming  int `json:"incoming"`
+	Outgoing  int `json:"outgoing"`
+	Missed    int `json:"missed"`
+	Voicemail int `json:"voicemail"`
+	Rejected  int `json:"rejected"`
+	Blocked   int `json:"blocked"`
+	// MissedRate is the share of inbound calls (incoming and missed) which were missed.
+	MissedRate  float64 `json:"missed_rate"`
+	TalkSeconds int     `json:"talk_seconds"`
+	// AverageSeconds is the mean duration of calls which connected.
+	AverageSeconds float64        `json:"average_seconds"`
+	ByHour         [24]int        `json:"by_hour"`
+	ByWeekday      [7]int         `json:"by_weekday"`
+	Contacts       []ContactStats `json:"contacts"`
+}
+
+// Contact returns the name used to group a call, falling back to the number
+// when the backup did not know the contact.
+func (call *Call) Contact() string {
+	if call.ContactName == "" || call.ContactName == UnknownContact {
+		return call.Number
+	}
+	return call.ContactName
+}
+
+// Seconds returns the duration of the call, or 0 when it is not a number.
+func (call *Call) Seconds() int {
+	s, err := strconv.Atoi(call.Duration)
+	if err != nil {
+		return 0
+	}
+	return s
+}
+
+// Summarize aggregates calls, bucketing hours and weekdays in loc.
+// Contacts are ordered by talk time then by number of calls.
+func Summarize(calls []Call, loc *time.Location) Stats {
+	var s = Stats{Contacts: []ContactStats{}}
+	var connected int
+	var byContact = map[string]*ContactStats{}
+
+	for i := range calls {
+		var c = &calls[i]
+		var cs, ok = byContact[c.Contact()]
+		if !ok {
+			cs = &ContactStats{Contact: c.Contact()}
+			byContact[c.Contact()] = cs
+		}
+
+		var seconds = c.Seconds()
+		s.Calls++
+		cs.Calls++
+		s.TalkSeconds += seconds
+		cs.TalkSeconds += seconds
+		if seconds > 0 {
+			connected++
+		}
+		switch c.Type {
+		case Incoming:
+			s.Incoming++
+			cs.Incoming++
+		case Outgoing:
+			s.Outgoing++
+			cs.Outgoing++
+		case Missed:
+			s.Missed++
+			cs.Missed++
+		case Voicemail:
+			s.Voicemail++
+		case Rejected:
+			s.Rejected++
+		case Blocked:
+			s.Blocked++
+		}
+
+		var t = c.Time().In(loc)
+		s.ByHour[t.Hour()]++
+		s.ByWeekday[t.Weekday()]++
+	}
+
+	if inbound := s.Incoming + s.Missed; inbound > 0 {
+		s.MissedRate = float64(s.Missed) / float64(inbound)
+	}
+	if connected > 0 {
+		s.AverageSeconds = float64(s.TalkSeconds) / float64(connected)
+	}
+
+	for _, cs := range byContact {
+		s.Contacts = append(s.Contacts, *cs)
+	}
+	sort.Slice(s.Contacts, func(i, j int) bool {
+		a, b := s.Contacts[i], s.Contacts[j]
+		if a.TalkSeconds != b.TalkSeconds {
+			return a.TalkSeconds > b.TalkSeconds
+		}
+		if a.Calls != b.Calls {
+			return a.Calls > b.Calls
+		}
+		return a.Contact < b.Contact
+	})
+	return s
+}
+
+// BusiestHour returns the hour of the day with the most calls.
+func (s Stats) BusiestHour() int {
+	var busiest int
+	for h, n := range s.ByHour {
+		if n > s.ByHour[busiest] {
+			busiest = h
+		}
+	}
+	return busiest
+}
+
+// BusiestWeekday returns the day of the week with the most calls.
+func (s Stats) BusiestWeekday() time.Weekday {
+	var busiest int
+	for d, n := range s.ByWeekday {
+		if n > s.ByWeekday[busiest] {
+			busiest = d
+		}
+	}
+	return time.Weekday(busiest)
+}
+
+// Gap is a stretch of time without calls between two calls.
+type Gap struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	Days  float64   `json:"days"`
+}
+
+// Coverage describes how continuously a set of calls spans its time range.
+type Coverage struct {
+	Calls int       `json:"calls"`
+	First time.Time `json:"first"`
+	Last  time.Time `json:"last"`
+	// Gaps lists, in order, the gaps of at least the minimum length; they
+	// likely point to missing backups.
+	Gaps []Gap `json:"gaps"`
+}
+
+// FindGaps reports the gaps of at least minGap between consecutive calls.
+func FindGaps(calls []Call, minGap time.Duration) Coverage {
+	var coverage = Coverage{Calls: len(calls), Gaps: []Gap{}}
+	if len(calls) == 0 {
+		return coverage
+	}
+
+	var dates = make([]int, 0, len(calls))
+	for _, c := range calls {
+		dates = append(dates, c.Date)
+	}
+	sort.Ints(dates)
+
+	var at = func(i int) time.Time {
+		return time.Unix(0, int64(dates[i])*int64(time.Millisecond)).UTC()
+	}
+	coverage.First, coverage.Last = at(0), at(len(dates)-1)
+	for i := 1; i < len(dates); i++ {
+		var start, end = at(i - 1), at(i)
+		if gap := end.Sub(start); gap >= minGap {
+			coverage.Gaps = append(coverage.Gaps, Gap{start, end, gap.Hours() / 24})
+		}
+	}
+	return coverage
+}
+
+// Fields calls can be grouped by.
+const (
+	GroupContact = "contact"
+	GroupMonth   = "month"
+	GroupType    = "type"
+)
+
+// GroupFields lists the fields calls can be grouped by.
+var GroupFields = []string{GroupContact, GroupMonth, GroupType}
+
+// Group aggregates the calls which share the values of the grouped fields.
+type Group struct {
+	// Values holds the value of each grouped field, in the order given.
+	Values      []string `json:"values"`
+	Calls       int      `json:"calls"`
+	TalkSeconds int      `json:"talk_seconds"`
+}
+
+// groupValue returns the value of field for call, with months in loc.
+func groupValue(call *Call, field string, loc *time.Location) string {
+	switch field {
+	case GroupContact:
+		return call.Contact()
+	case GroupMonth:
+		return call.Time().In(loc).Format("2006-01")
+	default:
+		return TypeName(call.Type)
+	}
+}
+
+// GroupBy aggregates calls by the values of fields, which are GroupFields,
+// bucketing months in loc. Groups are ordered by their values.
+func GroupBy(calls []Call, fields []string, loc *time.Location) []Group {
+	var groups = []Group{}
+	var index = map[string]int{}
+	for i := range calls {
+		var values = make([]string, len(fields))
+		for j, f := range fields {
+			values[j] = groupValue(&calls[i], f, loc)
+		}
+		var key = fmt.Sprintf("%q", values)
+		n, ok := index[key]
+		if !ok {
+			n = len(groups)
+			index[key] = n
+			groups = append(groups, Group{Values: values})
+		}
+		groups[n].Calls++
+		groups[n].TalkSeconds += calls[i].Seconds()
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		a, b := groups[i].Values, groups[j].Values
+		for k := range a {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return false
+	})
+	return groups
+}
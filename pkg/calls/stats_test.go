@@ -0,0 +1,104 @@
+package calls
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSummarize(t *testing.T) {
+	// 2014-09-15 is a Monday
+	var monday9am = int(time.Date(2014, 9, 15, 9, 0, 0, 0, time.UTC).UnixNano() / int64(time.Millisecond))
+	var hour = int(time.Hour / time.Millisecond)
+	var in = []Call{
+		{Number: "5555550001", Duration: "60", Date: monday9am, Type: Incoming, ContactName: "Jane"},
+		{Number: "5555550001", Duration: "120", Date: monday9am + hour, Type: Outgoing, ContactName: "Jane"},
+		{Number: "5555550002", Duration: "0", Date: monday9am + 24*hour, Type: Missed, ContactName: "(Unknown)"},
+		{Number: "5555550002", Duration: "30", Date: monday9am + 25*hour, Type: Incoming, ContactName: "(Unknown)"},
+		{Number: "5555550003", Duration: "0", Date: monday9am + 26*hour, Type: Missed},
+	}
+
+	s := Summarize(in, time.UTC)
+
+	if s.Calls != 5 || s.Incoming != 2 || s.Outgoing != 1 || s.Missed != 2 {
+		t.Errorf("counts got %d/%d/%d/%d, want 5/2/1/2", s.Calls, s.Incoming, s.Outgoing, s.Missed)
+	}
+	if s.TalkSeconds != 210 {
+		t.Errorf("talk seconds got %d, want 210", s.TalkSeconds)
+	}
+	if s.AverageSeconds != 70 {
+		t.Errorf("average seconds got %v, want 70", s.AverageSeconds)
+	}
+	if s.MissedRate != 0.5 {
+		t.Errorf("missed rate got %v, want 0.5", s.MissedRate)
+	}
+	if s.BusiestHour() != 9 {
+		t.Errorf("busiest hour got %d, want 9", s.BusiestHour())
+	}
+	if s.BusiestWeekday() != time.Tuesday {
+		t.Errorf("busiest weekday got %v, want Tuesday", s.BusiestWeekday())
+	}
+
+	var contacts []string
+	for _, c := range s.Contacts {
+		contacts = append(contacts, c.Contact)
+	}
+	want := []string{"Jane", "5555550002", "5555550003"}
+	if len(contacts) != len(want) {
+		t.Fatalf("contacts got %v, want %v", contacts, want)
+	}
+	for i := range want {
+		if contacts[i] != want[i] {
+			t.Errorf("contacts got %v, want %v", contacts, want)
+		}
+	}
+}
+
+func TestFindGaps(t *testing.T) {
+	var day = int(24 * time.Hour / time.Millisecond)
+	var start = int(time.Date(2014, 1, 1, 0, 0, 0, 0, time.UTC).UnixNano() / int64(time.Millisecond))
+	var in = []Call{
+		{Date: start + 100*day},
+		{Date: start},
+		{Date: start + 10*day},
+		{Date: start + 11*day},
+		{Date: start + 56*day},
+	}
+
+	c := FindGaps(in, 45*24*time.Hour)
+
+	if c.Calls != 5 || !c.First.Equal(time.Date(2014, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("coverage got %d calls from %v", c.Calls, c.First)
+	}
+	if len(c.Gaps) != 1 {
+		t.Fatalf("gaps got %v, want 1", c.Gaps)
+	}
+	if c.Gaps[0].Days != 45 || !c.Gaps[0].Start.Equal(time.Date(2014, 1, 12, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("gap got %+v, want 45 days from 2014-01-12", c.Gaps[0])
+	}
+}
+
+func TestGroupBy(t *testing.T) {
+	var sept = int(time.Date(2014, 9, 30, 23, 0, 0, 0, time.UTC).UnixNano() / int64(time.Millisecond))
+	var hour = int(time.Hour / time.Millisecond)
+	var in = []Call{
+		{Number: "5555550001", Duration: "60", Date: sept, Type: Incoming, ContactName: "Jane"},
+		{Number: "5555550001", Duration: "120", Date: sept + 2*hour, Type: Outgoing, ContactName: "Jane"},
+		{Number: "5555550002", Duration: "30", Date: sept + 3*hour, Type: Incoming},
+	}
+
+	got := GroupBy(in, []string{GroupMonth, GroupContact}, time.UTC)
+	var want = []Group{
+		{Values: []string{"2014-09", "Jane"}, Calls: 1, TalkSeconds: 60},
+		{Values: []string{"2014-10", "5555550002"}, Calls: 1, TalkSeconds: 30},
+		{Values: []string{"2014-10", "Jane"}, Calls: 1, TalkSeconds: 120},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	got = GroupBy(in, []string{GroupType}, time.UTC)
+	if len(got) != 2 || got[0].Values[0] != "incoming" || got[0].Calls != 2 {
+		t.Errorf("by type got %+v", got)
+	}
+}
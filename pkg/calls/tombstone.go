@@ -0,0 +1,93 @@
+package calls
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Tombstone records a call deleted from the repository, so that importing a
+// backup file which still has it does not bring it back.
+type Tombstone struct {
+	// Record is the Hash of the deleted call; the other fields are its Key.
+	Record    string    `json:"record"`
+	Number    string    `json:"number"`
+	Duration  string    `json:"duration"`
+	Date      int       `json:"date"`
+	Type      string    `json:"type"`
+	Reason    string    `json:"reason,omitempty"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// call returns the deleted call as far as its Key describes it.
+func (t *Tombstone) call() Call {
+	return Call{Number: t.Number, Duration: t.Duration, Date: t.Date, Type: t.Type}
+}
+
+// TombstoneFile returns the path of the tombstones of the repository at
+// rootDir.
+func TombstoneFile(rootDir string) string {
+	return filepath.Join(rootDir, "tombstones", "calls.jsonl")
+}
+
+// StreamTombstones invokes callback for each tombstone in the file at
+// filePath. A missing file has no tombstones.
+func StreamTombstones(filePath string, callback func(Tombstone) error) error {
+	f, err := os.Open(filePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var t Tombstone
+	return streamJSONLines(f, filePath, &t, func() error { return callback(t) })
+}
+
+// DeleteCalls removes the calls of the repository at rootDir which selected
+// returns true for. The calls file and a tombstone for each deleted call are
+// committed together unless dryRun is set.
+func DeleteCalls(rootDir string, selected func(*Call) bool, reason string, dryRun bool) ([]Tombstone, error) {
+	var callsFile = RepositoryFile(rootDir)
+	all, err := ReadCalls(callsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var deletedAt = time.Now().UTC()
+	var kept = all[:0]
+	var tombstones []Tombstone
+	for i := range all {
+		var call = &all[i]
+		if !selected(call) {
+			kept = append(kept, *call)
+			continue
+		}
+		tombstones = append(tombstones, Tombstone{Record: call.Hash(), Number: call.Number, Duration: call.Duration,
+			Date: call.Date, Type: call.Type, Reason: reason, DeletedAt: deletedAt})
+	}
+	if len(tombstones) == 0 || dryRun {
+		return tombstones, nil
+	}
+
+	var entries = make([]interface{}, len(tombstones))
+	for i := range tombstones {
+		entries[i] = tombstones[i]
+	}
+	var tx transaction
+	err = stageCalls(&tx, callsFile, kept)
+	if err == nil {
+		err = restageIndex(&tx, callsFile)
+	}
+	if err == nil {
+		err = appendJSONLines(&tx, TombstoneFile(rootDir), entries)
+	}
+	if err == nil {
+		err = tx.commit()
+	} else {
+		tx.abort()
+	}
+	return tombstones, err
+}
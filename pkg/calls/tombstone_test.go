@@ -0,0 +1,102 @@
+package calls
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestDeleteCallsLeavesTombstones(t *testing.T) {
+	dir := t.TempDir()
+	err := WriteCalls(RepositoryFile(dir), []Call{
+		{Number: "5555550013", Duration: "33", Date: 1388534400000, Type: Outgoing},
+		{Number: "5555550014", Duration: "12", Date: 1420070400000, Type: Incoming},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var selected = func(c *Call) bool { return c.Number == "5555550013" }
+	dry, err := DeleteCalls(dir, selected, "testing", true)
+	if err != nil || len(dry) != 1 {
+		t.Fatalf("dry run got %v, %v, want 1 tombstone", dry, err)
+	}
+	deleted, err := DeleteCalls(dir, selected, "testing", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 1 || deleted[0].Reason != "testing" || deleted[0].DeletedAt.IsZero() {
+		t.Fatalf("tombstones got %+v, want one with a reason and time", deleted)
+	}
+	stored, err := ReadCalls(RepositoryFile(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != 1 || stored[0].Number != "5555550014" {
+		t.Errorf("calls got %+v, want only 5555550014", stored)
+	}
+	var logged []Tombstone
+	err = StreamTombstones(TombstoneFile(dir), func(ts Tombstone) error {
+		logged = append(logged, ts)
+		return nil
+	})
+	if err != nil || len(logged) != 1 || logged[0].Record != deleted[0].Record {
+		t.Errorf("logged got %+v, %v, want %+v", logged, err, deleted)
+	}
+
+	// a backup file which still has the call does not bring it back, even
+	// with the number written differently
+	source := filepath.Join(dir, "calls-source.xml")
+	writeFile(t, source, `<calls count="2">
+  <call number="+15555550013" duration="33" date="1388534400000" type="2" />
+  <call number="5555550015" duration="1" date="1420070500000" type="1" />
+</calls>`)
+	c := mustInit(t, dir, Options{DefaultRegion: "US"})
+	result, err := c.Coalesce(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.New != 1 || result.Filtered != 1 {
+		t.Errorf("new/filtered got %d/%d, want 1/1", result.New, result.Filtered)
+	}
+}
+
+func TestEditCallsLeavesTombstones(t *testing.T) {
+	dir := t.TempDir()
+	err := WriteCalls(RepositoryFile(dir), []Call{{Number: "5555550013", Duration: "33", Date: 1388534400000, Type: Outgoing}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var all = func(*Call) bool { return true }
+	// only the date change alters the key
+	for _, c := range []Change{{"contact_name", "Alice"}, {"date", "1388534500000"}} {
+		_, err = EditCalls(dir, all, []Change{c}, "wrong clock", false)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	var logged []Tombstone
+	err = StreamTombstones(TombstoneFile(dir), func(ts Tombstone) error {
+		logged = append(logged, ts)
+		return nil
+	})
+	if err != nil || len(logged) != 1 || logged[0].Date != 1388534400000 || logged[0].Reason != "wrong clock" {
+		t.Fatalf("tombstones got %+v, %v, want the call before the date change", logged, err)
+	}
+
+	// the backup file with the wrong date does not bring the call back
+	source := filepath.Join(dir, "calls-source.xml")
+	writeFile(t, source, `<calls count="1">
+  <call number="5555550013" duration="33" date="1388534400000" type="2" />
+</calls>`)
+	c := mustInit(t, dir, Options{})
+	result, err := c.Coalesce(source)
+	if err == nil {
+		err = c.Flush()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.New != 0 || result.Total != 1 {
+		t.Errorf("new/total got %d/%d, want 0/1", result.New, result.Total)
+	}
+}
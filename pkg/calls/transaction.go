@@ -0,0 +1,168 @@
+package calls
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// stagedFile is a file written next to its target, waiting to replace it.
+type stagedFile struct {
+	// tmp is empty when the target is removed instead.
+	tmp    string
+	target string
+	// backup holds the replaced target until the transaction completes; it
+	// is empty when the target did not exist.
+	backup string
+}
+
+// transaction replaces several files of the repository together. Each file
+// is first written to a temporary file next to it; commit then renames them
+// all into place and puts the previous files back when any rename fails.
+type transaction struct {
+	staged []stagedFile
+	// generation, when set, is the GenerationFile commit bumps so that
+	// snapshots opened meanwhile are retried.
+	generation string
+}
+
+// stage calls write with the path of a temporary file which commit later
+// renames to target.
+func (tx *transaction) stage(target string, write func(tmp string) error) error {
+	err := os.MkdirAll(filepath.Dir(target), 0755)
+	if err != nil {
+		return err
+	}
+	var tmp = tempFile(target)
+	err = write(tmp)
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	tx.staged = append(tx.staged, stagedFile{tmp: tmp, target: target})
+	return nil
+}
+
+// remove stages target to be removed by commit.
+func (tx *transaction) remove(target string) {
+	tx.staged = append(tx.staged, stagedFile{target: target})
+}
+
+// abort removes the files staged so far.
+func (tx *transaction) abort() {
+	for _, s := range tx.staged {
+		if s.tmp != "" {
+			os.Remove(s.tmp)
+		}
+	}
+	tx.staged = nil
+}
+
+// commit renames the staged files into place. On failure the files already
+// replaced are restored and the remaining staged files are removed.
+func (tx *transaction) commit() error {
+	if tx.generation != "" {
+		g, err := ReadGeneration(tx.generation)
+		var committing = g | 1
+		if err == nil {
+			err = writeGeneration(tx.generation, committing)
+		}
+		if err != nil {
+			tx.abort()
+			return err
+		}
+		// the files are consistent again whether or not the commit succeeds
+		defer writeGeneration(tx.generation, committing+1)
+	}
+
+	for i := range tx.staged {
+		var s = &tx.staged[i]
+		if _, err := os.Stat(s.target); err == nil {
+			// a link keeps the target in place, so readers never miss it
+			s.backup = s.target + ".bak"
+			os.Remove(s.backup)
+			err = os.Link(s.target, s.backup)
+			if err != nil {
+				err = os.Rename(s.target, s.backup)
+			}
+			if err != nil {
+				s.backup = ""
+				return tx.rollback(i, err)
+			}
+		}
+		var err error
+		if s.tmp != "" {
+			err = os.Rename(s.tmp, s.target)
+		} else if s.backup != "" {
+			err = os.Remove(s.target)
+		}
+		if err != nil {
+			return tx.rollback(i+1, err)
+		}
+	}
+	for _, s := range tx.staged {
+		if s.backup != "" {
+			os.Remove(s.backup)
+		}
+	}
+	tx.staged = nil
+	return nil
+}
+
+// rollback restores the targets of the first done staged files, removes all
+// staged files and returns cause.
+func (tx *transaction) rollback(done int, cause error) error {
+	for i := done - 1; i >= 0; i-- {
+		var s = tx.staged[i]
+		var err error
+		if s.backup != "" {
+			err = os.Rename(s.backup, s.target)
+		} else {
+			err = os.Remove(s.target)
+		}
+		if err != nil && !os.IsNotExist(err) {
+			cause = fmt.Errorf("%w (restoring %s: %v)", cause, s.target, err)
+		}
+	}
+	tx.abort()
+	return cause
+}
+
+// GenerationFile returns the path of the file counting the commits to the
+// repository at rootDir. It holds an odd number while a commit is renaming
+// files into place.
+func GenerationFile(rootDir string) string {
+	return filepath.Join(rootDir, ".generation")
+}
+
+// ReadGeneration reads the generation file at filePath; a missing file is
+// generation 0.
+func ReadGeneration(filePath string) (uint64, error) {
+	data, err := os.ReadFile(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	g, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", filePath, err)
+	}
+	return g, nil
+}
+
+func writeGeneration(filePath string, g uint64) error {
+	var tmp = filePath + ".tmp"
+	err := os.WriteFile(tmp, []byte(strconv.FormatUint(g, 10)+"\n"), 0644)
+	if err == nil {
+		err = os.Rename(tmp, filePath)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
@@ -0,0 +1,105 @@
+package calls
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func stageText(t *testing.T, tx *transaction, filePath, content string) {
+	err := tx.stage(filePath, func(tmp string) error {
+		return os.WriteFile(tmp, []byte(content), 0644)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func assertFile(t *testing.T, filePath, want string) {
+	got, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Errorf("read %s: %v", filePath, err)
+	} else if string(got) != want {
+		t.Errorf("%s got %q, want %q", filePath, got, want)
+	}
+}
+
+func TestTransactionCommit(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "calls.xml")
+	created := filepath.Join(dir, "provenance", "calls.jsonl")
+	err := os.WriteFile(existing, []byte("old"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tx transaction
+	stageText(t, &tx, existing, "new")
+	stageText(t, &tx, created, "entries")
+	assertFile(t, existing, "old")
+
+	err = tx.commit()
+	if err != nil {
+		t.Fatalf("err got %v, want nil", err)
+	}
+	assertFile(t, existing, "new")
+	assertFile(t, created, "entries")
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		t.Errorf("files left behind: %v", entries)
+	}
+}
+
+func TestTransactionRollback(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "calls.xml")
+	created := filepath.Join(dir, "rejections.xml")
+	failing := filepath.Join(dir, "rejections.json")
+	err := os.WriteFile(existing, []byte("old"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tx transaction
+	stageText(t, &tx, existing, "new")
+	stageText(t, &tx, created, "rejections")
+	stageText(t, &tx, failing, "index")
+	// the last rename fails
+	os.Remove(tx.staged[2].tmp)
+
+	err = tx.commit()
+	if err == nil {
+		t.Fatalf("err got nil, want an error")
+	}
+	assertFile(t, existing, "old")
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("files left behind: %v", entries)
+	}
+}
+
+func TestTransactionRemoveRollback(t *testing.T) {
+	dir := t.TempDir()
+	removed := filepath.Join(dir, "calls.xml")
+	failing := filepath.Join(dir, "calls.xml.gz")
+	err := os.WriteFile(removed, []byte("old"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tx transaction
+	tx.remove(removed)
+	stageText(t, &tx, failing, "new")
+	// the rename after the removal fails
+	os.Remove(tx.staged[1].tmp)
+
+	err = tx.commit()
+	if err == nil {
+		t.Fatalf("err got nil, want an error")
+	}
+	assertFile(t, removed, "old")
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("files left behind: %v", entries)
+	}
+}
@@ -0,0 +1,180 @@
+package calls
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// tempFile returns the path a calls file is written to before it is renamed
+// to filePath; it keeps the CompressedSuffix so it is written the same way.
+func tempFile(filePath string) string {
+	if IsCompressed(filePath) {
+		return strings.TrimSuffix(filePath, CompressedSuffix) + ".tmp" + CompressedSuffix
+	}
+	return filePath + ".tmp"
+}
+
+// WriteCalls writes calls, in the given order, as a calls file at filePath,
+// compressing it when filePath has the CompressedSuffix. The count attribute
+// is set from calls. The file is written next to filePath and renamed into
+// place, so a failure never leaves a partial file behind. Its ChecksumFile is
+// replaced with it.
+func WriteCalls(filePath string, calls []Call) error {
+	var tx transaction
+	err := stageCalls(&tx, filePath, calls)
+	if err == nil {
+		err = restageIndex(&tx, filePath)
+	}
+	if err != nil {
+		tx.abort()
+		return err
+	}
+	return tx.commit()
+}
+
+// ConvertCalls replaces the calls file at filePath with one at target holding
+// the same content, compressed when target has the CompressedSuffix. The new
+// file, the checksum files of both and the index are committed together.
+func ConvertCalls(filePath, target string) error {
+	var tx transaction
+	tx.generation = GenerationFile(filepath.Dir(filePath))
+	var hash string
+	err := tx.stage(target, func(tmp string) error {
+		in, err := Open(filePath)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		out, err := Create(tmp)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(out, in)
+		// closing flushes compressed files, so its error matters
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+		if err == nil {
+			hash, err = FileSHA256(tmp)
+		}
+		return err
+	})
+	if err == nil {
+		err = stageChecksum(&tx, target, hash)
+	}
+	if err == nil {
+		err = restageIndex(&tx, target)
+	}
+	if err != nil {
+		tx.abort()
+		return err
+	}
+	tx.remove(filePath)
+	if _, err := os.Stat(ChecksumFile(filePath)); err == nil {
+		tx.remove(ChecksumFile(filePath))
+	}
+	return tx.commit()
+}
+
+// restageIndex stages the index of the repository of the calls file at
+// filePath, staged in tx, when the repository has one, so that it is kept up
+// to date.
+func restageIndex(tx *transaction, filePath string) error {
+	var indexFile = IndexFile(filepath.Dir(filePath))
+	if _, err := os.Stat(indexFile); err != nil {
+		return nil
+	}
+	return stageIndex(tx, indexFile, tempFile(filePath))
+}
+
+// stageCalls stages calls to be written as the calls file at filePath when tx
+// is committed, along with its ChecksumFile.
+func stageCalls(tx *transaction, filePath string, calls []Call) error {
+	// the calls file is at the root of its repository
+	tx.generation = GenerationFile(filepath.Dir(filePath))
+	var hash string
+	err := tx.stage(filePath, func(tmp string) error {
+		xmlFile, err := Create(tmp)
+		if err != nil {
+			return err
+		}
+		err = writeCalls(xmlFile, calls)
+		// closing flushes compressed files, so its error matters
+		if cerr := xmlFile.Close(); err == nil {
+			err = cerr
+		}
+		if err == nil {
+			hash, err = FileSHA256(tmp)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return stageChecksum(tx, filePath, hash)
+}
+
+func writeCalls(w io.Writer, calls []Call) error {
+	// build xml container
+	var wrappedData = Calls{Calls: calls, Count: len(calls)}
+	out, err := xml.MarshalIndent(wrappedData, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, xml.Header)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "<?xml-stylesheet type=\"text/xsl\" href=\"calls.xsl\"?>\n")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// AppendCalls adds calls to the calls file at filePath, keeping it in
+// canonical order. Calls are not deduplicated; coalesce them for that.
+func AppendCalls(filePath string, calls []Call) error {
+	existing, err := ReadCalls(filePath)
+	if err != nil {
+		return err
+	}
+	var all = append(existing, calls...)
+	SortCanonical(all)
+	return WriteCalls(filePath, all)
+}
+
+// RewriteYear replaces the calls of the calls file at filePath which took
+// place in the given UTC year with calls, keeping the other years as they
+// are. Calls in calls outside of year are rejected.
+func RewriteYear(filePath string, year int, calls []Call) error {
+	for _, c := range calls {
+		if c.Time().UTC().Year() != year {
+			return fmt.Errorf("call of %s is not in %d", c.Time().UTC().Format("2006-01-02"), year)
+		}
+	}
+
+	var kept []Call
+	err := StreamCalls(filePath, func(c Call) error {
+		if c.Time().UTC().Year() != year {
+			kept = append(kept, c)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var all = append(kept, calls...)
+	SortCanonical(all)
+	return WriteCalls(filePath, all)
+}
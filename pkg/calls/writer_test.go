@@ -0,0 +1,89 @@
+package calls
+
+import (
+	"encoding/xml"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAppendAndRewriteYear(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "calls.xml.gz")
+	// 2014-01-01 and 2015-01-01 UTC
+	var y2014, y2015 = 1388534400000, 1420070400000
+	err := WriteCalls(file, []Call{{Number: "1", Duration: "0", Date: y2015, Type: Incoming}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = AppendCalls(file, []Call{
+		{Number: "2", Duration: "0", Date: y2014, Type: Incoming},
+		{Number: "3", Duration: "0", Date: y2014 + 1000, Type: Missed},
+	})
+	if err != nil {
+		t.Fatalf("append err got %v, want nil", err)
+	}
+	err = RewriteYear(file, 2014, []Call{{Number: "4", Duration: "5", Date: y2014 + 2000, Type: Outgoing}})
+	if err != nil {
+		t.Fatalf("rewrite err got %v, want nil", err)
+	}
+	err = RewriteYear(file, 2014, []Call{{Number: "5", Duration: "5", Date: y2015, Type: Outgoing}})
+	if err == nil {
+		t.Errorf("rewriting 2014 with a 2015 call got nil err")
+	}
+
+	stored, err := ReadCalls(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var numbers []string
+	for _, c := range stored {
+		numbers = append(numbers, c.Number)
+	}
+	if strings.Join(numbers, ",") != "4,1" {
+		t.Errorf("calls got %v, want [4 1]", numbers)
+	}
+
+	r, err := Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	var calls Calls
+	err = xml.NewDecoder(r).Decode(&calls)
+	if err != nil || calls.Count != 2 {
+		t.Errorf("count attribute got %d, err %v, want 2", calls.Count, err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 3 {
+		t.Errorf("directory got %v, want only the calls file, its checksum and the generation", entries)
+	}
+	if err := VerifyChecksum(file); err != nil {
+		t.Errorf("checksum got %v, want nil", err)
+	}
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "calls.xml")
+	writeFile(t, file, emptyCalls)
+	if err := VerifyChecksum(file); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("without checksum got %v, want %v", err, os.ErrNotExist)
+	}
+
+	err := WriteChecksum(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyChecksum(file); err != nil {
+		t.Errorf("got %v, want nil", err)
+	}
+	writeFile(t, file, emptyCalls+"\n")
+	if err := VerifyChecksum(file); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("after a change got %v, want %v", err, ErrChecksumMismatch)
+	}
+}
@@ -5,10 +5,60 @@ import ()
 type Result struct {
 	Total int
 	New   int
+	// Rejected counts the records which could not be parsed and were skipped.
+	Rejected int
+	// Duplicates counts the parsed records which were already coalesced.
+	Duplicates int
+	// Filtered counts the parsed records left out by a filter or because
+	// they were deleted from the repository.
+	Filtered int
+	// Salvaged counts the records parsed from damaged files in recovery mode.
+	Salvaged int
+	// NewByYear breaks New down by the UTC year of the records.
+	NewByYear map[int]int
+	// NewContacts maps the numbers of the new records which name a contact
+	// unknown to the repository to that name.
+	NewContacts map[string]string
+}
+
+// ParseErrorPolicy decides what happens to a file containing records which
+// cannot be parsed.
+type ParseErrorPolicy string
+
+const (
+	// SkipRecord rejects the unparsable records and keeps the rest of the file.
+	SkipRecord ParseErrorPolicy = "skip"
+	// RejectFile keeps none of the records of the file.
+	RejectFile ParseErrorPolicy = "reject-file"
+	// FailImport stops the whole run.
+	FailImport ParseErrorPolicy = "fail"
+)
+
+// ParseErrorPolicies lists the supported policies.
+var ParseErrorPolicies = []ParseErrorPolicy{SkipRecord, RejectFile, FailImport}
+
+// AbortError is returned by Coalesce when the whole run, rather than only the
+// file being coalesced, must stop.
+type AbortError struct {
+	Err error
+}
+
+func (e *AbortError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *AbortError) Unwrap() error {
+	return e.Err
 }
 
 type Coalescer interface {
 	Coalesce(filePath string) (Result, error)
+	// Prepare parses filePath without changing the coalescer and returns the
+	// function which merges its records, as Coalesce would. Prepare may be
+	// called concurrently; the returned functions must be called one at a time.
+	Prepare(filePath string) (merge func() (Result, error), err error)
 	Supports(filePath string) (bool, error)
+	// Count returns the number of records in filePath without coalescing them.
+	Count(filePath string) (int, error)
 	Flush() error
 }
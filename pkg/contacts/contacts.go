@@ -0,0 +1,101 @@
+// Package contacts keeps the names of the people phone numbers belong to in
+// the contacts.yaml of a repository.
+package contacts
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the name of the contacts file within a repository.
+const FileName = "contacts.yaml"
+
+// File returns the path of the contacts file of the repository at rootDir.
+func File(rootDir string) string {
+	return filepath.Join(rootDir, FileName)
+}
+
+// Contact is a person and the numbers they used.
+type Contact struct {
+	Name    string   `yaml:"name"`
+	Numbers []string `yaml:"numbers"`
+}
+
+// Book is the content of a contacts file.
+type Book struct {
+	Contacts []Contact `yaml:"contacts"`
+	// Region is the region national numbers are interpreted in by
+	// MatchNumber; with none they are compared as written.
+	Region string `yaml:"-"`
+}
+
+// Load reads the contacts file at filePath. A missing file is an empty Book.
+func Load(filePath string) (*Book, error) {
+	data, err := os.ReadFile(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Book{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var b Book
+	err = yaml.Unmarshal(data, &b)
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// Save replaces the contacts file at filePath with b.
+func (b *Book) Save(filePath string) error {
+	data, err := yaml.Marshal(b)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(filePath), ".contacts-*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filePath)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// Name returns the name of the contact with number, as written.
+func (b *Book) Name(number string) (string, bool) {
+	for _, c := range b.Contacts {
+		for _, n := range c.Numbers {
+			if n == number {
+				return c.Name, true
+			}
+		}
+	}
+	return "", false
+}
+
+// Add records number as belonging to the contact called name, unless it
+// already belongs to a contact. It reports whether the book changed.
+func (b *Book) Add(name, number string) bool {
+	if _, ok := b.Name(number); ok {
+		return false
+	}
+	for i := range b.Contacts {
+		if b.Contacts[i].Name == name {
+			b.Contacts[i].Numbers = append(b.Contacts[i].Numbers, number)
+			return true
+		}
+	}
+	b.Contacts = append(b.Contacts, Contact{Name: name, Numbers: []string{number}})
+	return true
+}
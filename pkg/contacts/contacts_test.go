@@ -0,0 +1,82 @@
+package contacts
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestBook(t *testing.T) {
+	dir := t.TempDir()
+	b, err := Load(File(dir))
+	if err != nil {
+		t.Fatalf("missing file got %v, want nil", err)
+	}
+
+	var adds = []struct {
+		name, number string
+		changed      bool
+	}{
+		{"Jack Daniels", "5555550003", true},
+		{"Jack Daniels", "5555550013", true},
+		{"Oscar Wilde", "5555550004", true},
+		{"Someone Else", "5555550003", false},
+	}
+	for _, a := range adds {
+		if got := b.Add(a.name, a.number); got != a.changed {
+			t.Errorf("Add(%q, %q) got %v, want %v", a.name, a.number, got, a.changed)
+		}
+	}
+	err = b.Save(File(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := Load(filepath.Join(dir, FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, b) {
+		t.Errorf("loaded %+v, want %+v", loaded, b)
+	}
+	if name, ok := loaded.Name("5555550013"); !ok || name != "Jack Daniels" {
+		t.Errorf("Name got %q, %v, want Jack Daniels", name, ok)
+	}
+	if _, ok := loaded.Name("5555550099"); ok {
+		t.Errorf("unknown number found")
+	}
+}
+
+func TestMatchNumber(t *testing.T) {
+	var b = Book{Region: "US", Contacts: []Contact{
+		{"Jack Daniels", []string{"5555550003", "+445555550013"}},
+		{"Oscar Wilde", []string{"+1 555 555 0004"}},
+		{"Bank", []string{"72265", "MyBank"}},
+		{"Twin A", []string{"+33123456789"}},
+		{"Twin B", []string{"+34123456789"}},
+	}}
+	tests := []struct {
+		raw  string
+		name string
+		kind MatchKind
+	}{
+		{"5555550003", "Jack Daniels", ExactMatch},
+		{"mybank", "Bank", ExactMatch},
+		{"+15555550003", "Jack Daniels", NormalizedMatch},
+		{"1-555-555-0003", "Jack Daniels", NormalizedMatch},
+		{"(555) 555-0004", "Oscar Wilde", NormalizedMatch},
+		{"72-265", "Bank", NormalizedMatch},
+		{"01144 5555550013", "Jack Daniels", NormalizedMatch},
+		{"5555550013", "Jack Daniels", SuffixMatch},
+		{"2265", "", NoMatch},
+		{"123456789", "", NoMatch},
+		{"OtherBank", "", NoMatch},
+		{"", "", NoMatch},
+	}
+	for _, tt := range tests {
+		c, kind := b.MatchNumber(tt.raw)
+		if c.Name != tt.name || kind != tt.kind {
+			t.Errorf("MatchNumber(%q) got %q, %v, want %q, %v", tt.raw, c.Name, kind, tt.name, tt.kind)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package contacts
+
+import (
+	"strings"
+
+	"github.com/phillipgreen/mobilecombackup/pkg/phone"
+)
+
+// MatchKind is how a number matched a contact.
+type MatchKind int
+
+const (
+	// NoMatch is returned when no contact has the number.
+	NoMatch MatchKind = iota
+	// ExactMatch is a number stored exactly as given, ignoring case for
+	// alphanumeric senders.
+	ExactMatch
+	// NormalizedMatch is a number which is the same once formatting is
+	// removed and national numbers are given the calling code of the Region.
+	NormalizedMatch
+	// SuffixMatch is a number whose last minSuffixDigits or more digits are
+	// all the digits of the other, as when one lacks the calling code of a
+	// region other than the Region.
+	SuffixMatch
+)
+
+func (k MatchKind) String() string {
+	switch k {
+	case ExactMatch:
+		return "exact"
+	case NormalizedMatch:
+		return "normalized"
+	case SuffixMatch:
+		return "suffix"
+	}
+	return "none"
+}
+
+// minSuffixDigits is the fewest digits a SuffixMatch compares, so that short
+// codes only ever match exactly.
+const minSuffixDigits = 7
+
+// canonical returns number in E.164 form, or for short codes its digits, or
+// for alphanumeric senders its lower case.
+func canonical(number, region string) string {
+	var n = phone.Normalize(number, region)
+	if strings.HasPrefix(n, "+") {
+		return n
+	}
+	if d := digits(n); d != "" {
+		return d
+	}
+	return strings.ToLower(strings.TrimSpace(n))
+}
+
+// digits returns the digits of number, or "" when it has anything but digits
+// and formatting.
+func digits(number string) string {
+	var sb strings.Builder
+	for _, r := range strings.TrimSpace(number) {
+		switch {
+		case r >= '0' && r <= '9':
+			sb.WriteRune(r)
+		case strings.ContainsRune("+ -.()/", r):
+			// formatting
+		default:
+			return ""
+		}
+	}
+	return sb.String()
+}
+
+// suffixOf reports whether the shorter of a and b, both digits, has at least
+// minSuffixDigits and ends the other.
+func suffixOf(a, b string) bool {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	return len(a) >= minSuffixDigits && strings.HasSuffix(b, a)
+}
+
+// MatchNumber returns the contact raw belongs to and how it matched, trying
+// the kinds in order. A SuffixMatch shared by several contacts is ambiguous
+// and returns NoMatch.
+func (b *Book) MatchNumber(raw string) (Contact, MatchKind) {
+	var trimmed = strings.TrimSpace(raw)
+	if trimmed == "" {
+		return Contact{}, NoMatch
+	}
+	for _, c := range b.Contacts {
+		for _, n := range c.Numbers {
+			if strings.EqualFold(strings.TrimSpace(n), trimmed) {
+				return c, ExactMatch
+			}
+		}
+	}
+
+	var want = canonical(trimmed, b.Region)
+	for _, c := range b.Contacts {
+		for _, n := range c.Numbers {
+			if canonical(n, b.Region) == want {
+				return c, NormalizedMatch
+			}
+		}
+	}
+
+	// the calling code Region gives national numbers would spoil the suffix
+	var wantDigits = digits(trimmed)
+	if wantDigits == "" {
+		return Contact{}, NoMatch
+	}
+	var found []Contact
+	for _, c := range b.Contacts {
+		for _, n := range c.Numbers {
+			if suffixOf(digits(n), wantDigits) {
+				found = append(found, c)
+				break
+			}
+		}
+	}
+	if len(found) != 1 {
+		return Contact{}, NoMatch
+	}
+	return found[0], SuffixMatch
+}
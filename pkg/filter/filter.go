@@ -0,0 +1,157 @@
+// Package filter selects records with expressions such as
+// "date>=2020-01-01" or "number=+1555*".
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Record is anything whose fields can be filtered on.
+type Record interface {
+	// FilterField returns the value of the named field. Dates are returned
+	// as epoch milliseconds.
+	FilterField(name string) (value string, ok bool)
+}
+
+// Predicate reports whether a record is selected.
+type Predicate func(r Record) bool
+
+// operators are ordered so that two character operators are found first
+var operators = []string{">=", "<=", "!=", "=", ">", "<"}
+
+var dateLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02"}
+
+// Expression compares one field of a record with a value.
+type Expression struct {
+	Field string
+	Op    string
+	Value string
+}
+
+// ErrInvalidExpression is returned by Parse and New for expressions which
+// cannot be parsed.
+var ErrInvalidExpression = errors.New("invalid expression")
+
+// Parse parses an expression of the form field op value, where op is one of
+// =, !=, <, <=, > or >=. With = and != the value may be a glob, as in
+// "number=+1555*". Values which are dates, such as 2020-01-01 (UTC) or an
+// RFC 3339 timestamp, are compared as epoch milliseconds.
+func Parse(expr string) (Expression, error) {
+	for i := 0; i < len(expr); i++ {
+		for _, op := range operators {
+			if strings.HasPrefix(expr[i:], op) {
+				var e = Expression{strings.TrimSpace(expr[:i]), op, strings.TrimSpace(expr[i+len(op):])}
+				if e.Field == "" {
+					return e, fmt.Errorf("%w %q has no field", ErrInvalidExpression, expr)
+				}
+				if _, err := path.Match(e.Value, ""); err != nil {
+					return e, fmt.Errorf("%w %q: %v", ErrInvalidExpression, expr, err)
+				}
+				e.Value = dateMillis(e.Value)
+				return e, nil
+			}
+		}
+	}
+	return Expression{}, fmt.Errorf("%w %q has no operator, expected one of %s", ErrInvalidExpression, expr, strings.Join(operators, " "))
+}
+
+// dateMillis returns value as epoch milliseconds when it is a date.
+func dateMillis(value string) string {
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)
+		}
+	}
+	return value
+}
+
+// Matches reports whether r satisfies the expression. Records without the
+// field never match.
+func (e Expression) Matches(r Record) bool {
+	value, ok := r.FilterField(e.Field)
+	if !ok {
+		return false
+	}
+
+	switch e.Op {
+	case "=":
+		matched, _ := path.Match(e.Value, value)
+		return matched
+	case "!=":
+		matched, _ := path.Match(e.Value, value)
+		return !matched
+	}
+
+	var cmp = compare(value, e.Value)
+	switch e.Op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	default:
+		return cmp >= 0
+	}
+}
+
+// compare orders a and b numerically when both are numbers, and as strings
+// otherwise.
+func compare(a, b string) int {
+	x, xerr := strconv.ParseFloat(a, 64)
+	y, yerr := strconv.ParseFloat(b, 64)
+	if xerr != nil || yerr != nil {
+		return strings.Compare(a, b)
+	}
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+// New returns the predicate which selects records matching every include
+// expression and none of the exclude expressions. It returns nil when there
+// are no expressions, selecting everything.
+func New(includes, excludes []string) (Predicate, error) {
+	if len(includes) == 0 && len(excludes) == 0 {
+		return nil, nil
+	}
+
+	var in, out []Expression
+	for _, s := range includes {
+		e, err := Parse(s)
+		if err != nil {
+			return nil, err
+		}
+		in = append(in, e)
+	}
+	for _, s := range excludes {
+		e, err := Parse(s)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+
+	return func(r Record) bool {
+		for _, e := range in {
+			if !e.Matches(r) {
+				return false
+			}
+		}
+		for _, e := range out {
+			if e.Matches(r) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
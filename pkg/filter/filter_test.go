@@ -0,0 +1,52 @@
+package filter
+
+import (
+	"testing"
+)
+
+type record map[string]string
+
+func (r record) FilterField(name string) (string, bool) {
+	v, ok := r[name]
+	return v, ok
+}
+
+func TestPredicate(t *testing.T) {
+	// 2020-01-01T00:00:00Z is 1577836800000
+	var call = record{"number": "+15555550013", "date": "1577836800000", "duration": "45", "type": "1"}
+
+	var tests = []struct {
+		includes []string
+		excludes []string
+		want     bool
+	}{
+		{nil, nil, true},
+		{[]string{"date>=2020-01-01"}, nil, true},
+		{[]string{"date>2020-01-01"}, nil, false},
+		{[]string{"date<2020-01-01T00:00:01Z"}, nil, true},
+		{[]string{"duration>=45", "duration<100"}, nil, true},
+		{[]string{"duration>5"}, nil, true},
+		{nil, []string{"number=+1555*"}, false},
+		{nil, []string{"number!=+1555*"}, true},
+		{[]string{"type=1"}, []string{"type=3"}, true},
+		{[]string{"contact=Jane"}, nil, false},
+	}
+
+	for _, tt := range tests {
+		p, err := New(tt.includes, tt.excludes)
+		if err != nil {
+			t.Fatalf("%v %v: err got %v, want nil", tt.includes, tt.excludes, err)
+		}
+		if got := p == nil || p(call); got != tt.want {
+			t.Errorf("include %v exclude %v got %v, want %v", tt.includes, tt.excludes, got, tt.want)
+		}
+	}
+}
+
+func TestParseError(t *testing.T) {
+	for _, expr := range []string{"date", ">=2020", "number=[+1"} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("%q got nil err", expr)
+		}
+	}
+}
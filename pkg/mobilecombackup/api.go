@@ -1,7 +1,12 @@
 package mobilecombackup
 
 import (
+	"context"
+	"time"
+
+	"github.com/phillipgreen/mobilecombackup/pkg/calls"
 	"github.com/phillipgreen/mobilecombackup/pkg/coalescer"
+	"github.com/phillipgreen/mobilecombackup/pkg/filter"
 )
 
 type Result struct {
@@ -10,4 +15,73 @@ type Result struct {
 
 type Processor interface {
 	Process(fileRoot string) (Result, error)
+	// ProcessContext is Process which stops between files when ctx is done.
+	// A cancelled run does not modify the repository.
+	ProcessContext(ctx context.Context, fileRoot string) (Result, error)
+}
+
+// Progress is a snapshot of how far a Process call has advanced.
+type Progress struct {
+	FilesTotal   int
+	FilesDone    int
+	RecordsTotal int
+	RecordsDone  int
+	Elapsed      time.Duration
+}
+
+// Rate returns the records processed per second so far.
+func (p Progress) Rate() float64 {
+	if p.Elapsed <= 0 {
+		return 0
+	}
+	return float64(p.RecordsDone) / p.Elapsed.Seconds()
+}
+
+// ETA estimates the time remaining based on the rate so far.
+func (p Progress) ETA() time.Duration {
+	var rate = p.Rate()
+	if rate <= 0 {
+		return 0
+	}
+	var remaining = p.RecordsTotal - p.RecordsDone
+	return time.Duration(float64(remaining) / rate * float64(time.Second))
+}
+
+// Options tunes how a Processor runs.
+type Options struct {
+	// Progress, when set, enables a pre-scan of the files to process and is
+	// called after each file is coalesced.
+	Progress func(Progress)
+	// DefaultRegion is used to normalize national phone numbers so that
+	// differently formatted numbers deduplicate.
+	DefaultRegion string
+	// OnParseError decides what happens to files containing records which
+	// cannot be parsed.
+	OnParseError coalescer.ParseErrorPolicy
+	// Workers bounds how many files are parsed concurrently. Files are still
+	// merged one at a time in the order they are found, so the result does not
+	// depend on it. Values below 1 mean 1.
+	Workers int
+	// Dedup decides which calls are duplicates of each other.
+	Dedup calls.KeyStrategy
+	// Filter, when set, selects the records imported.
+	Filter filter.Predicate
+	// DryRun coalesces as usual but never writes the repository, so the
+	// Result projects what an import would change.
+	DryRun bool
+	// Recover salvages what it can from damaged backup files instead of
+	// applying OnParseError to them.
+	Recover bool
+	// TransformCall, when set, is called with each imported call before it is
+	// deduplicated. It may enrich the call, and returning false skips it.
+	TransformCall func(call *calls.Call) bool
+	// OnCommit, when set, is called with the calls added to the repository
+	// once they are written. It is not called for a DryRun.
+	OnCommit func(added []calls.Call)
+	// Index creates the calls index on the next write; an existing index is
+	// always kept up to date.
+	Index bool
+	// IOThrottle, when positive, is how many bytes per second backup files
+	// are read at, by all workers together.
+	IOThrottle int64
 }
@@ -0,0 +1,144 @@
+package mobilecombackup
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/phillipgreen/mobilecombackup/pkg/validation"
+)
+
+// SizeBudgetExceeded is reported by validate when part of the repository is
+// larger than the budget the repository configuration gives it.
+const SizeBudgetExceeded validation.ViolationType = "size-budget"
+
+// totalBudget is the budget key which covers the whole repository.
+const totalBudget = "total"
+
+// budgetContributors is how many of the largest files a violation names.
+const budgetContributors = 3
+
+func init() {
+	err := validation.RegisterValidator(checkBudgets, validation.Rule{
+		Type:        SizeBudgetExceeded,
+		Severity:    validation.Warning,
+		Description: "Part of the repository is larger than its configured size budget.",
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+var sizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"TB", 1 << 40},
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"B", 1},
+}
+
+// parseSize parses a size such as 50GB or 1.5MB; units are powers of 1024.
+func parseSize(s string) (int64, error) {
+	var trimmed = strings.ToUpper(strings.TrimSpace(s))
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(trimmed, u.suffix) {
+			n, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(trimmed, u.suffix)), 64)
+			if err != nil || n < 0 {
+				return 0, fmt.Errorf("invalid size %q", s)
+			}
+			return int64(n * float64(u.bytes)), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid size %q, expected a number followed by one of B, KB, MB, GB or TB", s)
+}
+
+// formatSize returns n in the largest unit it has at least one of.
+func formatSize(n int64) string {
+	for _, u := range sizeUnits {
+		if n >= u.bytes && u.bytes > 1 {
+			return strconv.FormatFloat(float64(n)/float64(u.bytes), 'f', 1, 64) + u.suffix
+		}
+	}
+	return strconv.FormatInt(n, 10) + "B"
+}
+
+type fileSize struct {
+	path string
+	size int64
+}
+
+// diskUsage returns the size of the files under path, the largest first, and
+// their total.
+func diskUsage(path string) ([]fileSize, int64, error) {
+	var files []fileSize
+	var total int64
+	err := filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			files = append(files, fileSize{p, info.Size()})
+			total += info.Size()
+		}
+		return nil
+	})
+	sort.SliceStable(files, func(i, j int) bool { return files[i].size > files[j].size })
+	return files, total, err
+}
+
+// checkBudgets reports the budgets of the repository configuration at
+// rootDir which are exceeded. Budgets are keyed by a file or directory
+// relative to the repository, or by total for all of it:
+//
+//	budgets:
+//	  total: 2GB
+//	  provenance: 50MB
+func checkBudgets(rootDir string) ([]validation.Violation, error) {
+	repo, err := repositorySettings(rootDir)
+	if err != nil || repo == nil {
+		return nil, err
+	}
+
+	var keys = make([]string, 0, len(repo.Budgets))
+	for k := range repo.Budgets {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var violations []validation.Violation
+	for _, key := range keys {
+		budget, err := parseSize(repo.Budgets[key])
+		if err != nil {
+			return nil, fmt.Errorf("%s: budget of %s: %w", RepositoryConfigFile, key, err)
+		}
+		var path, file = rootDir, "."
+		if key != totalBudget {
+			path, file = filepath.Join(rootDir, key), filepath.ToSlash(filepath.Clean(key))
+		}
+		files, total, err := diskUsage(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if total <= budget {
+			continue
+		}
+
+		var largest []string
+		for i := 0; i < len(files) && i < budgetContributors; i++ {
+			rel, _ := filepath.Rel(rootDir, files[i].path)
+			largest = append(largest, fmt.Sprintf("%s (%s)", filepath.ToSlash(rel), formatSize(files[i].size)))
+		}
+		violations = append(violations, validation.NewViolation(SizeBudgetExceeded, file, 0,
+			fmt.Sprintf("%s uses %s, over its budget of %s; largest: %s", key, formatSize(total), repo.Budgets[key], strings.Join(largest, ", "))))
+	}
+	return violations, nil
+}
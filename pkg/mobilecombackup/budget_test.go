@@ -0,0 +1,70 @@
+package mobilecombackup
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/phillipgreen/mobilecombackup/internal/test_support"
+	"github.com/phillipgreen/mobilecombackup/pkg/validation"
+)
+
+func TestParseSize(t *testing.T) {
+	var tests = []struct {
+		in   string
+		want int64
+	}{
+		{"512", -1},
+		{"512B", 512},
+		{"1.5kb", 1536},
+		{"50GB", 50 << 30},
+		{"-1MB", -1},
+	}
+	for _, tt := range tests {
+		got, err := parseSize(tt.in)
+		if tt.want < 0 {
+			if err == nil {
+				t.Errorf("parseSize(%q) got %d, want an error", tt.in, got)
+			}
+		} else if err != nil || got != tt.want {
+			t.Errorf("parseSize(%q) got %d, %v, want %d", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestCheckBudgets(t *testing.T) {
+	tmpdir := t.TempDir()
+	err := test_support.CopyDir("../../testdata", tmpdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repoDir := filepath.Join(tmpdir, "archive")
+	err = os.WriteFile(filepath.Join(repoDir, RepositoryConfigFile), []byte(`
+budgets:
+  total: 1KB
+  calls.xml: 1MB
+  provenance: 1B
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := validation.ValidateRepository(repoDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found []validation.Violation
+	for _, v := range result.Violations {
+		if v.Type == SizeBudgetExceeded {
+			found = append(found, v)
+		}
+	}
+	if len(found) != 1 {
+		t.Fatalf("violations got %+v, want only the total budget", found)
+	}
+	if found[0].Severity != validation.Warning || found[0].File != "." ||
+		!strings.Contains(found[0].Message, "over its budget of 1KB; largest: calls") {
+		t.Errorf("violation got %+v", found[0])
+	}
+}
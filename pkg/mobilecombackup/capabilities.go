@@ -0,0 +1,115 @@
+package mobilecombackup
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"os"
+	"runtime/debug"
+	"strings"
+
+	"github.com/phillipgreen/mobilecombackup/pkg/validation"
+)
+
+// Capabilities describes what this binary can do, so that wrappers can adapt
+// to the version they shell out to.
+type Capabilities struct {
+	Version           string   `json:"version"`
+	Commands          []string `json:"commands"`
+	ImportFormats     []string `json:"import_formats"`
+	ExportFormats     []string `json:"export_formats"`
+	Validators        []string `json:"validators"`
+	AutofixOperations []string `json:"autofix_operations"`
+	Features          []string `json:"features"`
+}
+
+func version() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok || info.Main.Version == "" {
+		return "unknown"
+	}
+	return info.Main.Version
+}
+
+func capabilities() Capabilities {
+	var c = Capabilities{
+		Version:           version(),
+		Commands:          []string{},
+		ImportFormats:     []string{"calls-xml", "calls-xml.gz"},
+		ExportFormats:     []string{},
+		Validators:        []string{},
+		AutofixOperations: []string{},
+		Features:          []string{"gzip-compression", "prometheus-metrics", "import-filters"},
+	}
+	for _, f := range exportFormats {
+		c.ExportFormats = append(c.ExportFormats, "conversation-"+f)
+	}
+	for _, r := range validation.Rules() {
+		c.Validators = append(c.Validators, string(r.Type))
+	}
+	for _, cmd := range subcommands() {
+		c.Commands = append(c.Commands, cmd.name)
+	}
+	return c
+}
+
+type capabilitiesConfig struct {
+	format string
+}
+
+func parseCapabilitiesFlags(s *settings, progname string, args []string) (conf *capabilitiesConfig, output string, err error) {
+	flags := flag.NewFlagSet(progname, flag.ContinueOnError)
+	var buf bytes.Buffer
+	flags.SetOutput(&buf)
+
+	var c capabilitiesConfig
+	flags.StringVar(&c.format, "format", "table", "output format: "+strings.Join(reportFormats, "|"))
+	var outputJSON bool
+	flags.BoolVar(&outputJSON, "output-json", false, "deprecated, use -format json")
+
+	err = parseArgs(s, flags, args)
+	if err != nil {
+		return nil, buf.String(), err
+	}
+	if outputJSON {
+		c.format = "json"
+	}
+	return &c, buf.String(), nil
+}
+
+func writeCapabilities(w io.Writer, conf *capabilitiesConfig, c Capabilities) error {
+	if conf.format == "json" || conf.format == "yaml" {
+		return writeStructured(w, conf.format, c)
+	}
+
+	return writeRows(w, "table", nil, [][]string{
+		{"version:", c.Version},
+		{"commands:", strings.Join(c.Commands, ", ")},
+		{"import formats:", strings.Join(c.ImportFormats, ", ")},
+		{"export formats:", strings.Join(c.ExportFormats, ", ")},
+		{"validators:", strings.Join(c.Validators, ", ")},
+		{"autofix operations:", strings.Join(c.AutofixOperations, ", ")},
+		{"features:", strings.Join(c.Features, ", ")},
+	})
+}
+
+func runCapabilities(s *settings, progname string, args []string) (exitCode int, output *string, err error) {
+	conf, o, err := parseCapabilitiesFlags(s, progname, args)
+	if err == flag.ErrHelp {
+		return 4, nil, err
+	} else if err != nil {
+		return 3, &o, err
+	}
+
+	err = checkFormat(conf.format, reportFormats)
+	if err != nil {
+		return 2, nil, err
+	}
+
+	err = writeCapabilities(os.Stdout, conf, capabilities())
+	if err != nil {
+		return 1, nil, err
+	}
+
+	return 0, nil, nil
+}
@@ -0,0 +1,57 @@
+package mobilecombackup
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestWriteCapabilitiesJSON(t *testing.T) {
+	var buf bytes.Buffer
+	err := writeCapabilities(&buf, &capabilitiesConfig{format: "json"}, capabilities())
+	if err != nil {
+		t.Fatalf("err got %v, want nil", err)
+	}
+
+	var decoded map[string]interface{}
+	err = json.Unmarshal(buf.Bytes(), &decoded)
+	if err != nil {
+		t.Fatalf("output is not json: %v\n%s", err, buf.String())
+	}
+	for _, key := range []string{"version", "commands", "import_formats", "export_formats", "validators", "autofix_operations", "features"} {
+		if _, ok := decoded[key]; !ok {
+			t.Errorf("key %q missing from %s", key, buf.String())
+		}
+		if decoded[key] == nil {
+			t.Errorf("key %q got null, want value", key)
+		}
+	}
+}
+
+func TestCapabilitiesListsCommands(t *testing.T) {
+	c := capabilities()
+	for _, want := range []string{"import", "capabilities"} {
+		found := false
+		for _, got := range c.Commands {
+			if got == want {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("commands got %v, want to contain %q", c.Commands, want)
+		}
+	}
+}
+
+func TestWriteCapabilitiesText(t *testing.T) {
+	var buf bytes.Buffer
+	err := writeCapabilities(&buf, &capabilitiesConfig{format: "table"}, capabilities())
+	if err != nil {
+		t.Fatalf("err got %v, want nil", err)
+	}
+	if !strings.Contains(buf.String(), "import formats:      calls-xml") ||
+		!strings.Contains(buf.String(), "export formats:      conversation-text, conversation-html") {
+		t.Errorf("output got %q", buf.String())
+	}
+}
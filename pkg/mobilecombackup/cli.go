@@ -2,18 +2,58 @@ package mobilecombackup
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"flag"
 	"fmt"
+	"log"
 	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/phillipgreen/mobilecombackup/pkg/calls"
+	"github.com/phillipgreen/mobilecombackup/pkg/coalescer"
+	"github.com/phillipgreen/mobilecombackup/pkg/filter"
+	"github.com/phillipgreen/mobilecombackup/pkg/phone"
 )
 
 type config struct {
 	repoPath       string
+	quiet          bool
+	region         string
+	onParseError   string
+	workers        int
+	metricsFile    string
+	dryRun         bool
+	dedupFields    string
+	dedupRounding  time.Duration
+	dateTolerance  time.Duration
+	durTolerance   int
+	includes       stringsFlag
+	excludes       stringsFlag
+	format         string
+	recover        bool
+	index          bool
+	ioThrottle     string
+	ioIdle         bool
 	pathsToProcess []string
+	settings       *settings
+}
+
+// stringsFlag collects the values of a repeated flag.
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ", ")
 }
 
-func parseFlags(progname string, args []string) (conf *config, output string, err error) {
+func (s *stringsFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+func parseFlags(s *settings, progname string, args []string) (conf *config, output string, err error) {
 	flags := flag.NewFlagSet(progname, flag.ContinueOnError)
 	var buf bytes.Buffer
 	flags.SetOutput(&buf)
@@ -22,15 +62,44 @@ func parseFlags(progname string, args []string) (conf *config, output string, er
 		fmt.Fprintf(flags.Output(), "Usage of %s [options] [pathToProcess1 ... pathToProcessN]:\n", progname)
 
 		flags.PrintDefaults()
+
+		fmt.Fprintf(flags.Output(), "Global options, given before the command:\n  %s\n    \t%s\n", readOnlyFlag, "fail instead of modifying the repository")
+
+		fmt.Fprintf(flags.Output(), "Commands:\n")
+		for _, c := range subcommands() {
+			fmt.Fprintf(flags.Output(), "  %s\n    \t%s\n", c.name, c.description)
+		}
+
+		fmt.Fprintf(flags.Output(), "Exit codes:\n  1 failure, %d invalid arguments or configuration, %d repository damaged, %d repository busy, %d nothing found\n", exitInvalid, exitIntegrity, exitBusy, exitNotFound)
 	}
 
-	var c config
+	var c = config{settings: s}
 	flags.StringVar(&c.repoPath, "repo", ".", "path which contains repository")
+	flags.BoolVar(&c.quiet, "quiet", false, "do not render a progress bar")
+	flags.StringVar(&c.region, "region", "US", "region used to normalize national phone numbers (empty to compare numbers as written)")
+	flags.IntVar(&c.workers, "workers", 1, "number of files to parse concurrently")
+	flags.StringVar(&c.onParseError, "on-parse-error", string(coalescer.SkipRecord), "what to do with records which cannot be parsed: skip|reject-file|fail")
+	flags.StringVar(&c.metricsFile, "metrics-file", "", "write import metrics to this file in Prometheus textfile format")
+	flags.StringVar(&c.dedupFields, "dedup-fields", "", "comma separated call fields compared to find duplicates: "+strings.Join(calls.KeyFields, ",")+" (all when empty)")
+	flags.DurationVar(&c.dedupRounding, "dedup-date-rounding", 0, "round call dates down to a multiple of this before comparing them, such as 1s")
+	flags.DurationVar(&c.dateTolerance, "dedup-date-tolerance", 0, "also treat calls whose dates differ by at most this, such as 500ms, as duplicates")
+	flags.IntVar(&c.durTolerance, "dedup-duration-tolerance", 0, "also treat calls whose durations differ by at most this many seconds as duplicates")
+	flags.Var(&c.includes, "include", "only import records matching this expression, such as 'date>=2020-01-01' (repeatable, all must match)")
+	flags.Var(&c.excludes, "exclude", "do not import records matching this expression, such as 'number=+1555*' (repeatable)")
+	flags.BoolVar(&c.dryRun, "dry-run", false, "report what would be imported without changing the repository")
+	flags.BoolVar(&c.recover, "recover", false, "salvage the readable records of damaged backup files instead of applying -on-parse-error")
+	flags.BoolVar(&c.index, "index", false, "maintain an index of the calls for lookup, kept up to date once it exists")
+	flags.StringVar(&c.ioThrottle, "io-throttle", "", "read backup files at most this fast per second, such as 20MB (unlimited when empty)")
+	flags.BoolVar(&c.ioIdle, "io-idle", false, "run at idle CPU and disk priority so other programs are not slowed down")
+	flags.StringVar(&c.format, "format", "table", "format of the dry-run report: "+strings.Join(reportFormats, "|"))
+	var outputFormat string
+	flags.StringVar(&outputFormat, "output-format", "", "deprecated, use -format")
 
-	err = flags.Parse(args)
+	err = parseArgs(s, flags, args)
 	if err != nil {
 		return nil, buf.String(), err
 	}
+	c.format = deprecatedFormat(c.format, outputFormat)
 	c.pathsToProcess = flags.Args()
 	return &c, buf.String(), nil
 }
@@ -39,35 +108,193 @@ func validateConfig(conf *config) error {
 	if len(conf.pathsToProcess) <= 0 {
 		return errors.New("Atleast one path to process must be specified")
 	}
+	if conf.workers < 1 {
+		return fmt.Errorf("Workers must be atleast 1, got %d", conf.workers)
+	}
+	if !isParseErrorPolicy(conf.onParseError) {
+		return fmt.Errorf("Unknown parse error policy %q", conf.onParseError)
+	}
+	if conf.dedupFields != "" {
+		if _, err := calls.ParseKeyFields(conf.dedupFields); err != nil {
+			return fmt.Errorf("Invalid dedup fields: %w", err)
+		}
+	}
+	if _, err := filter.New(conf.includes, conf.excludes); err != nil {
+		return fmt.Errorf("Invalid filter: %w", err)
+	}
+	if conf.dedupRounding < 0 {
+		return fmt.Errorf("Dedup date rounding must not be negative, got %v", conf.dedupRounding)
+	}
+	if conf.dateTolerance < 0 || conf.durTolerance < 0 {
+		return fmt.Errorf("Dedup tolerances must not be negative, got %v and %d", conf.dateTolerance, conf.durTolerance)
+	}
+	if conf.ioThrottle != "" {
+		if n, err := parseSize(conf.ioThrottle); err != nil || n <= 0 {
+			return fmt.Errorf("Invalid io throttle %q, expected a size such as 20MB", conf.ioThrottle)
+		}
+	}
+	if err := checkFormat(conf.format, reportFormats); err != nil {
+		return err
+	}
+	if conf.region != "" && !phone.IsRegion(conf.region) {
+		return fmt.Errorf("Unknown region %q, expected one of %s", conf.region, strings.Join(phone.Regions(), ", "))
+	}
 	return nil
 }
 
+func isParseErrorPolicy(policy string) bool {
+	for _, p := range coalescer.ParseErrorPolicies {
+		if policy == string(p) {
+			return true
+		}
+	}
+	return false
+}
+
 func doWork(conf *config) error {
+	var started = time.Now()
+	var summary = importSummary{DryRun: conf.dryRun, NewByYear: map[int]int{}, NewContacts: map[string]string{}}
+	var err = importPaths(conf, &summary)
+	if err == nil && conf.dryRun {
+		err = writeImportReport(os.Stdout, conf.format, summary)
+	}
+	if conf.metricsFile != "" {
+		merr := writeMetricsFile(conf.metricsFile, summary.metrics(err == nil, started))
+		if merr != nil && err == nil {
+			err = fmt.Errorf("Failed to write metrics: %w", merr)
+		}
+	}
+	return err
+}
 
-	mcb, err := Init(conf.repoPath)
+func importPaths(conf *config, summary *importSummary) error {
+	var options = Options{
+		DefaultRegion: conf.region,
+		OnParseError:  coalescer.ParseErrorPolicy(conf.onParseError),
+		Workers:       conf.workers,
+		Dedup:         calls.KeyStrategy{DateRounding: conf.dedupRounding, DateTolerance: conf.dateTolerance, DurationTolerance: conf.durTolerance},
+		DryRun:        conf.dryRun,
+		Recover:       conf.recover,
+		Index:         conf.index,
+	}
+	if conf.ioThrottle != "" {
+		options.IOThrottle, _ = parseSize(conf.ioThrottle)
+	}
+	if conf.ioIdle {
+		if err := idlePriority(); err != nil {
+			log.Printf("Running at normal priority: %v", err)
+		}
+	}
+	// fields and filters are validated by validateConfig
+	if conf.dedupFields != "" {
+		options.Dedup.Fields, _ = calls.ParseKeyFields(conf.dedupFields)
+	}
+	options.Filter, _ = filter.New(conf.includes, conf.excludes)
+	if !conf.quiet && isTerminal(os.Stderr) {
+		options.Progress = func(p Progress) {
+			renderProgress(os.Stderr, p)
+		}
+	}
+
+	mcb, err := InitWithOptions(conf.repoPath, options)
 	if err != nil {
 		return err
 	}
 
-	var errorCount int
-	for _, path := range conf.pathsToProcess {
-		result, err := mcb.Process(path)
-		if err != nil {
+	// an interrupt abandons the path being processed, leaving the
+	// repository as the previous path left it
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	for i, path := range conf.pathsToProcess {
+		if ctx.Err() != nil {
+			return fmt.Errorf("Interrupted after processing %d of %d paths", i, len(conf.pathsToProcess))
+		}
+		summary.Paths++
+		result, err := mcb.ProcessContext(ctx, path)
+		var abortErr *coalescer.AbortError
+		if errors.As(err, &abortErr) {
+			// the coalescer still holds what the aborted path merged, which
+			// the next path would flush
+			summary.Failures += 1
+			return fmt.Errorf("Aborted processing %s, leaving %d paths unprocessed: %w", path, len(conf.pathsToProcess)-i-1, err)
+		} else if err != nil {
 			fmt.Fprintf(os.Stderr, "Failure: %v\n", err.Error())
-			errorCount += 1
+			summary.Failures += 1
 		} else {
-			fmt.Printf("Success: %v\n", result)
+			if !conf.dryRun {
+				fmt.Printf("Success: %v\n", result)
+			}
+			summary.add(result.Calls)
 		}
 	}
-	if errorCount > 0 {
-		return fmt.Errorf("Had %d failures", errorCount)
+	if summary.Failures > 0 {
+		return fmt.Errorf("Had %d failures", summary.Failures)
 	} else {
 		return nil
 	}
 }
 
+type command struct {
+	name        string
+	description string
+	run         func(s *settings, progname string, args []string) (exitCode int, output *string, err error)
+}
+
+func subcommands() []command {
+	return []command{
+		{"import", "coalesce backup files into the repository (default)", runImport},
+		{"init", "create an empty repository", runInit},
+		{"capabilities", "describe the formats and features supported by this binary", runCapabilities},
+		{"serve", "expose the repository over a read-only HTTP API", runServe},
+		{"stats", "summarize the repository contents", runStats},
+		{"validate", "check the repository for problems", runValidate},
+		{"watch", "validate the repository periodically and report new violations", runWatch},
+		{"doctor", "check the environment and repository for problems and suggest fixes", runDoctor},
+		{"verify", "check the repository files against their recorded checksums", runVerify},
+		{"verify-source", "check that every record of a backup file is in the repository", runVerifySource},
+		{"provenance", "trace records back to the backup files they were imported from", runProvenance},
+		{"lookup", "find calls by record hash, using the index when it is current", runLookup},
+		{"dedup", "audit how calls are deduplicated", runDedup},
+		{"normalize", "rewrite the repository in canonical order", runNormalize},
+		{"normalize-dates", "regenerate readable dates in one time zone", runNormalizeDates},
+		{"compress", "store the repository calls file gzip compressed (or -d to decompress)", runCompress},
+		{"edit", "correct fields of stored calls, recording each change in an audit log", runEdit},
+		{"delete", "remove stored calls, leaving tombstones so that imports do not bring them back", runDelete},
+		{"compact", "remove readable dates and contact names which can be regenerated (or -rehydrate to restore them)", runCompact},
+		{"history", "list, compare and restore saved versions of contacts.yaml", runHistory},
+		{"export", "write records from the repository in readable form", runExport},
+		{"completion", "print a bash, zsh or fish completion script", runCompletion},
+	}
+}
+
 func Run(args []string) (exitCode int, output *string, err error) {
-	conf, o, err := parseFlags(args[0], args[1:])
+	if len(args) > 1 && args[1] == completeCommand {
+		return runComplete(args[2:])
+	}
+
+	s, err := useSettings()
+	if err != nil {
+		return 2, nil, fmt.Errorf("Invalid configuration: %w", err)
+	}
+	args, s.readOnly = stripReadOnlyFlag(args)
+
+	var run = runImport
+	var progname, rest = args[0], args[1:]
+	if len(args) > 1 {
+		for _, c := range append(subcommands(), hiddenSubcommands()...) {
+			if args[1] == c.name {
+				run, progname, rest = c.run, args[0]+" "+c.name, args[2:]
+				break
+			}
+		}
+	}
+	exitCode, output, err = run(s, progname, rest)
+	return failureExitCode(exitCode, err), output, err
+}
+
+func runImport(s *settings, progname string, args []string) (exitCode int, output *string, err error) {
+	conf, o, err := parseFlags(s, progname, args)
 	if err == flag.ErrHelp {
 		return 4, nil, err
 	} else if err != nil {
@@ -79,6 +306,13 @@ func Run(args []string) (exitCode int, output *string, err error) {
 		return 2, nil, err
 	}
 
+	if !conf.dryRun {
+		err = checkWritable(conf.settings, conf.repoPath)
+		if err != nil {
+			return 2, nil, err
+		}
+	}
+
 	err = doWork(conf)
 	if err != nil {
 		return 1, nil, err
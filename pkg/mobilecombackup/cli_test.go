@@ -1,9 +1,14 @@
 package mobilecombackup
 
 import (
+	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
+
+	"github.com/phillipgreen/mobilecombackup/internal/test_support"
+	"github.com/phillipgreen/mobilecombackup/pkg/calls"
 )
 
 func TestParseFlagsCorrect(t *testing.T) {
@@ -12,15 +17,37 @@ func TestParseFlagsCorrect(t *testing.T) {
 		conf config
 	}{
 		{[]string{},
-			config{repoPath: ".", pathsToProcess: []string{}}},
+			config{repoPath: ".", region: "US", onParseError: "skip", workers: 1, format: "table", pathsToProcess: []string{}}},
 
 		{[]string{"-repo", "r/path", "myPath1", "myPath2"},
-			config{repoPath: "r/path", pathsToProcess: []string{"myPath1", "myPath2"}}},
+			config{repoPath: "r/path", region: "US", onParseError: "skip", workers: 1, format: "table", pathsToProcess: []string{"myPath1", "myPath2"}}},
+
+		{[]string{"-quiet", "myPath1"},
+			config{repoPath: ".", quiet: true, region: "US", onParseError: "skip", workers: 1, format: "table", pathsToProcess: []string{"myPath1"}}},
+
+		{[]string{"-workers", "8", "myPath1"},
+			config{repoPath: ".", region: "US", onParseError: "skip", workers: 8, format: "table", pathsToProcess: []string{"myPath1"}}},
+
+		{[]string{"-region", "GB", "myPath1"},
+			config{repoPath: ".", region: "GB", onParseError: "skip", workers: 1, format: "table", pathsToProcess: []string{"myPath1"}}},
+
+		{[]string{"-include", "date>=2020-01-01", "-exclude", "number=+1555*", "-exclude", "type=3", "myPath1"},
+			config{repoPath: ".", region: "US", onParseError: "skip", workers: 1, format: "table",
+				includes: stringsFlag{"date>=2020-01-01"}, excludes: stringsFlag{"number=+1555*", "type=3"}, pathsToProcess: []string{"myPath1"}}},
+
+		{[]string{"-dry-run", "-format", "yaml", "myPath1"},
+			config{repoPath: ".", region: "US", onParseError: "skip", workers: 1, dryRun: true, format: "yaml", pathsToProcess: []string{"myPath1"}}},
+
+		{[]string{"-dry-run", "-output-format", "json", "myPath1"},
+			config{repoPath: ".", region: "US", onParseError: "skip", workers: 1, dryRun: true, format: "json", pathsToProcess: []string{"myPath1"}}},
+
+		{[]string{"-output-format", "text", "myPath1"},
+			config{repoPath: ".", region: "US", onParseError: "skip", workers: 1, format: "table", pathsToProcess: []string{"myPath1"}}},
 	}
 
 	for _, tt := range tests {
 		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
-			conf, output, err := parseFlags("prog", tt.args)
+			conf, output, err := parseFlags(nil, "prog", tt.args)
 			if err != nil {
 				t.Errorf("err got %v, want nil", err)
 			}
@@ -44,7 +71,7 @@ func TestParseFlagsError(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
-			conf, output, err := parseFlags("prog", tt.args)
+			conf, output, err := parseFlags(nil, "prog", tt.args)
 			if conf != nil {
 				t.Errorf("conf got %v, want nil", conf)
 			}
@@ -64,9 +91,11 @@ func TestValidateConfigCorrect(t *testing.T) {
 		conf config
 	}{
 		{"specified repo path and single pathsToProcess",
-			config{repoPath: "other/path", pathsToProcess: []string{"myPath"}}},
+			config{repoPath: "other/path", onParseError: "skip", workers: 1, format: "table", pathsToProcess: []string{"myPath"}}},
 		{"default repo path and multiple pathsToProcess",
-			config{repoPath: ".", pathsToProcess: []string{"myPath1", "myPath2"}}},
+			config{repoPath: ".", onParseError: "fail", workers: 4, format: "json", pathsToProcess: []string{"myPath1", "myPath2"}}},
+		{"io throttle",
+			config{repoPath: ".", onParseError: "skip", workers: 2, format: "table", ioThrottle: "20MB", pathsToProcess: []string{"myPath"}}},
 	}
 
 	for _, tt := range tests {
@@ -88,6 +117,30 @@ func TestValidateConfigError(t *testing.T) {
 		{"specified repo path and no pathsToProcess",
 			config{repoPath: "other/path", pathsToProcess: []string{}},
 			"Atleast one path to process must be specified"},
+		{"no workers",
+			config{repoPath: ".", onParseError: "skip", workers: 0, pathsToProcess: []string{"myPath"}},
+			"Workers must be atleast 1"},
+		{"unknown parse error policy",
+			config{repoPath: ".", onParseError: "ignore", workers: 1, pathsToProcess: []string{"myPath"}},
+			"Unknown parse error policy \"ignore\""},
+		{"unknown region",
+			config{repoPath: ".", region: "XX", onParseError: "skip", workers: 1, format: "table", pathsToProcess: []string{"myPath"}},
+			"Unknown region \"XX\""},
+		{"unknown dedup field",
+			config{repoPath: ".", onParseError: "skip", workers: 1, format: "table", dedupFields: "number,text", pathsToProcess: []string{"myPath"}},
+			"Invalid dedup fields"},
+		{"filter without operator",
+			config{repoPath: ".", onParseError: "skip", workers: 1, format: "table", includes: stringsFlag{"date"}, pathsToProcess: []string{"myPath"}},
+			"Invalid filter"},
+		{"unknown output format",
+			config{repoPath: ".", onParseError: "skip", workers: 1, format: "xml", pathsToProcess: []string{"myPath"}},
+			"Unknown format \"xml\""},
+		{"io throttle without unit",
+			config{repoPath: ".", onParseError: "skip", workers: 1, format: "table", ioThrottle: "20", pathsToProcess: []string{"myPath"}},
+			"Invalid io throttle \"20\""},
+		{"zero io throttle",
+			config{repoPath: ".", onParseError: "skip", workers: 1, format: "table", ioThrottle: "0MB", pathsToProcess: []string{"myPath"}},
+			"Invalid io throttle"},
 	}
 
 	for _, tt := range tests {
@@ -99,3 +152,45 @@ func TestValidateConfigError(t *testing.T) {
 		})
 	}
 }
+
+func TestImportAbortStopsRun(t *testing.T) {
+	tmpdir := t.TempDir()
+	err := test_support.CopyDir("../../testdata", tmpdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repoDir := filepath.Join(tmpdir, "archive")
+	before, err := calls.ReadCalls(calls.RepositoryFile(repoDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the first path merges a new call before failing on a damaged file
+	var first, second = filepath.Join(tmpdir, "first"), filepath.Join(tmpdir, "second")
+	var files = map[string]string{
+		filepath.Join(first, "a-calls.xml"): `<calls count="1"><call number="5555550901" duration="1" date="1420070400000" type="1" /></calls>`,
+		filepath.Join(first, "b-calls.xml"): `<calls count="1"><call number="5555550902" dura`,
+		filepath.Join(second, "calls.xml"):  `<calls count="1"><call number="5555550903" duration="1" date="1420070500000" type="1" /></calls>`,
+	}
+	for name, content := range files {
+		err = os.MkdirAll(filepath.Dir(name), 0755)
+		if err == nil {
+			err = os.WriteFile(name, []byte(content), 0644)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	exitCode, _, err := Run([]string{"mobilecombackup", "import", "-quiet", "-on-parse-error", "fail", "-repo", repoDir, first, second})
+	if exitCode != 1 || err == nil || !strings.Contains(err.Error(), "Aborted") {
+		t.Errorf("got exit code %d, err %v, want 1 and an abort", exitCode, err)
+	}
+	after, err := calls.ReadCalls(calls.RepositoryFile(repoDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(after) != len(before) {
+		t.Errorf("calls got %d, want the %d from before the run", len(after), len(before))
+	}
+}
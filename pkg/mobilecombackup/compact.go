@@ -0,0 +1,237 @@
+package mobilecombackup
+
+import (
+	"bytes"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/phillipgreen/mobilecombackup/pkg/calls"
+	"github.com/phillipgreen/mobilecombackup/pkg/contacts"
+)
+
+type compactConfig struct {
+	repoPath  string
+	tz        string
+	rehydrate bool
+	settings  *settings
+}
+
+func parseCompactFlags(s *settings, progname string, args []string) (conf *compactConfig, output string, err error) {
+	flags := flag.NewFlagSet(progname, flag.ContinueOnError)
+	var buf bytes.Buffer
+	flags.SetOutput(&buf)
+
+	var c = compactConfig{settings: s}
+	flags.StringVar(&c.repoPath, "repo", ".", "path which contains repository")
+	flags.StringVar(&c.tz, "tz", "Local", "IANA time zone readable dates are regenerated in, such as America/New_York")
+	flags.BoolVar(&c.rehydrate, "rehydrate", false, "restore the readable dates and contact names which compaction removed")
+
+	err = parseArgs(s, flags, args)
+	if err != nil {
+		return nil, buf.String(), err
+	}
+	return &c, buf.String(), nil
+}
+
+// compactedAttr names the attribute listing the fields compactCalls removed
+// from a call, so that rehydrateCalls restores those and no others.
+var compactedAttr = xml.Name{Local: "compacted"}
+
+// Fields compactCalls removes.
+const (
+	compactedReadableDate = "readable_date"
+	compactedContactName  = "contact_name"
+)
+
+// compactedFields returns the fields compactCalls removed from c.
+func compactedFields(c *calls.Call) []string {
+	for _, a := range c.Extra {
+		if a.Name == compactedAttr {
+			return strings.Fields(a.Value)
+		}
+	}
+	return nil
+}
+
+// setCompactedFields records fields as removed from c, dropping the attribute
+// when there are none.
+func setCompactedFields(c *calls.Call, fields []string) {
+	var extra = c.Extra[:0]
+	for _, a := range c.Extra {
+		if a.Name != compactedAttr {
+			extra = append(extra, a)
+		}
+	}
+	if len(fields) > 0 {
+		extra = append(extra, xml.Attr{Name: compactedAttr, Value: strings.Join(fields, " ")})
+	}
+	c.Extra = extra
+	if len(c.Extra) == 0 {
+		c.Extra = nil
+	}
+}
+
+func hasField(fields []string, field string) bool {
+	for _, f := range fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// compactCalls removes the readable date and contact name of every call which
+// rehydrateCalls restores exactly, recording contact names in book. It returns
+// how many calls changed.
+func compactCalls(all []calls.Call, book *contacts.Book, loc *time.Location) int {
+	var changed = make(map[int]bool)
+	// calls compacted earlier whose number has no contact were unknown, and
+	// must stay so if the number gets one now
+	var unknown []int
+	for i := range all {
+		if hasField(compactedFields(&all[i]), compactedContactName) {
+			if _, ok := book.Name(all[i].Number); !ok {
+				unknown = append(unknown, i)
+			}
+		}
+	}
+	// every name is recorded before any is removed, so that a call is only
+	// compacted as unknown when no call of its number has a name
+	for i := range all {
+		if name := all[i].ContactName; name != "" && name != calls.UnknownContact {
+			book.Add(name, all[i].Number)
+		}
+	}
+	for _, i := range unknown {
+		if _, ok := book.Name(all[i].Number); ok {
+			var c = &all[i]
+			var fields = compactedFields(c)
+			var kept = fields[:0]
+			for _, f := range fields {
+				if f != compactedContactName {
+					kept = append(kept, f)
+				}
+			}
+			c.ContactName = calls.UnknownContact
+			setCompactedFields(c, kept)
+			changed[i] = true
+		}
+	}
+
+	for i := range all {
+		var c = &all[i]
+		var fields = compactedFields(c)
+		var stripped = len(fields)
+		if c.ReadableDate != "" && c.ReadableDate == c.FormatReadableDate(loc) {
+			c.ReadableDate = ""
+			fields = append(fields, compactedReadableDate)
+		}
+		if c.ContactName != "" {
+			// a number whose contact was renamed keeps the names which differ
+			name, ok := book.Name(c.Number)
+			if ok && name == c.ContactName || !ok && c.ContactName == calls.UnknownContact {
+				c.ContactName = ""
+				fields = append(fields, compactedContactName)
+			}
+		}
+		if len(fields) > stripped {
+			setCompactedFields(c, fields)
+			changed[i] = true
+		}
+	}
+	return len(changed)
+}
+
+// rehydrateCalls regenerates the readable dates in loc and the contact names
+// from book which compactCalls removed, returning how many calls changed.
+func rehydrateCalls(all []calls.Call, book *contacts.Book, loc *time.Location) int {
+	var changed int
+	for i := range all {
+		var c = &all[i]
+		var fields = compactedFields(c)
+		if len(fields) == 0 {
+			continue
+		}
+		if hasField(fields, compactedReadableDate) {
+			c.ReadableDate = c.FormatReadableDate(loc)
+		}
+		if hasField(fields, compactedContactName) {
+			c.ContactName = calls.UnknownContact
+			if name, ok := book.Name(c.Number); ok {
+				c.ContactName = name
+			}
+		}
+		setCompactedFields(c, nil)
+		changed++
+	}
+	return changed
+}
+
+// compactRepository compacts, or rehydrates, the calls of the repository at
+// repoPath, returning how many changed and how many calls there are.
+func compactRepository(repoPath string, rehydrate bool, loc *time.Location) (changed int, total int, err error) {
+	var callsFile = calls.RepositoryFile(repoPath)
+	all, err := calls.ReadCalls(callsFile)
+	if err != nil {
+		return 0, 0, err
+	}
+	var contactsFile = contacts.File(repoPath)
+	book, err := contacts.Load(contactsFile)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if rehydrate {
+		changed = rehydrateCalls(all, book, loc)
+	} else {
+		changed = compactCalls(all, book, loc)
+	}
+	if changed == 0 {
+		return 0, len(all), nil
+	}
+	// names must be recorded before the calls lose them
+	if !rehydrate && len(book.Contacts) > 0 {
+		err = saveHistory(repoPath, contacts.FileName, time.Now())
+		if err == nil {
+			err = book.Save(contactsFile)
+		}
+		if err != nil {
+			return 0, len(all), err
+		}
+	}
+	return changed, len(all), calls.WriteCalls(callsFile, all)
+}
+
+func runCompact(s *settings, progname string, args []string) (exitCode int, output *string, err error) {
+	conf, o, err := parseCompactFlags(s, progname, args)
+	if err == flag.ErrHelp {
+		return 4, nil, err
+	} else if err != nil {
+		return 3, &o, err
+	}
+
+	loc, err := time.LoadLocation(conf.tz)
+	if err != nil {
+		return 2, nil, fmt.Errorf("Unknown time zone %q: %w", conf.tz, err)
+	}
+
+	err = checkWritable(conf.settings, conf.repoPath)
+	if err != nil {
+		return 2, nil, err
+	}
+
+	changed, total, err := compactRepository(conf.repoPath, conf.rehydrate, loc)
+	if err != nil {
+		return 1, nil, err
+	}
+
+	var verb = "Compacted"
+	if conf.rehydrate {
+		verb = "Rehydrated"
+	}
+	fmt.Printf("%s %d of %d calls\n", verb, changed, total)
+	return 0, nil, nil
+}
@@ -0,0 +1,137 @@
+package mobilecombackup
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/phillipgreen/mobilecombackup/internal/test_support"
+	"github.com/phillipgreen/mobilecombackup/pkg/calls"
+	"github.com/phillipgreen/mobilecombackup/pkg/contacts"
+)
+
+func TestCompactRepository(t *testing.T) {
+	tmpdir := t.TempDir()
+	err := test_support.CopyDir("../../testdata", tmpdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repoDir := filepath.Join(tmpdir, "archive")
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var callsFile = calls.RepositoryFile(repoDir)
+	before, err := calls.ReadCalls(callsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changed, total, err := compactRepository(repoDir, false, loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed != total || total != len(before) {
+		t.Errorf("compacted %d of %d, want all %d", changed, total, len(before))
+	}
+	content, err := os.ReadFile(callsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(content), `readable_date="`) || strings.Contains(string(content), `contact_name="`) {
+		t.Errorf("compacted file still has derived attributes:\n%s", content)
+	}
+	book, err := contacts.Load(contacts.File(repoDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name, ok := book.Name("5555550004"); !ok || name != "Oscar Wilde" {
+		t.Errorf("contact got %q, %v, want Oscar Wilde", name, ok)
+	}
+
+	_, _, err = compactRepository(repoDir, true, loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, err := calls.ReadCalls(callsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(after, before) {
+		t.Errorf("rehydrated calls differ from the original")
+	}
+}
+
+func TestCompactRoundTrip(t *testing.T) {
+	repoDir := t.TempDir()
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var readable = func(date int) string {
+		c := calls.Call{Date: date}
+		return c.FormatReadableDate(loc)
+	}
+	var before = []calls.Call{
+		// unknown until a later call of the number has a name
+		{Number: "5555550021", Duration: "5", Date: 1388534400000, Type: calls.Incoming, ReadableDate: readable(1388534400000), ContactName: calls.UnknownContact},
+		{Number: "5555550021", Duration: "6", Date: 1388534500000, Type: calls.Incoming, ReadableDate: readable(1388534500000), ContactName: "Ada Lovelace"},
+		// never had the derived attributes
+		{Number: "5555550022", Duration: "7", Date: 1388534600000, Type: calls.Outgoing},
+		// a renamed contact keeps the name which differs
+		{Number: "5555550021", Duration: "8", Date: 1388534700000, Type: calls.Outgoing, ReadableDate: "yesterday", ContactName: "Ada King"},
+		{Number: "5555550023", Duration: "9", Date: 1388534800000, Type: calls.Missed, ReadableDate: readable(1388534800000), ContactName: calls.UnknownContact},
+	}
+	var callsFile = calls.RepositoryFile(repoDir)
+	err = calls.WriteCalls(callsFile, before)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, err = calls.ReadCalls(callsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changed, _, err := compactRepository(repoDir, false, loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed != 3 {
+		t.Errorf("compacted %d calls, want 3", changed)
+	}
+	// a later import gives the unknown number a name, which the earlier
+	// unknown call must not take on
+	compacted, err := calls.ReadCalls(callsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compacted = append(compacted, calls.Call{Number: "5555550023", Duration: "10", Date: 1388534900000, Type: calls.Incoming, ContactName: "Grace Hopper"})
+	err = calls.WriteCalls(callsFile, compacted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	added, err := calls.ReadCalls(callsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before = append(before, added[len(added)-1])
+	_, _, err = compactRepository(repoDir, false, loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err = compactRepository(repoDir, true, loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, err := calls.ReadCalls(callsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(after, before) {
+		t.Errorf("rehydrated calls got\n%+v\nwant\n%+v", after, before)
+	}
+}
@@ -0,0 +1,268 @@
+package mobilecombackup
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/phillipgreen/mobilecombackup/pkg/calls"
+	"github.com/phillipgreen/mobilecombackup/pkg/validation"
+)
+
+// completeCommand is the hidden command the completion scripts call to
+// complete the words typed so far.
+const completeCommand = "__complete"
+
+// describeFlags, when set, is given the flag set of the command being run
+// instead of parsing its arguments.
+var describeFlags func(*flag.FlagSet)
+
+// nestedSubcommands lists the commands which dispatch to subcommands.
+var nestedSubcommands = map[string]func() []command{
+	"stats":      statsSubcommands,
+	"dedup":      dedupSubcommands,
+	"provenance": provenanceSubcommands,
+	"export":     exportSubcommands,
+	"history":    historySubcommands,
+}
+
+// filterFields are the fields -include and -exclude expressions compare.
+var filterFields = []string{"number", "duration", "date", "type", "contact"}
+
+// usageValues finds the choices listed in a flag usage, such as "text|json".
+var usageValues = regexp.MustCompile(`[\w-]+(\|[\w-]+)+`)
+
+var completionScripts = map[string]string{
+	"bash": `_{{name}}() {
+	local IFS=$'\n'
+	COMPREPLY=($({{name}} __complete "${COMP_WORDS[@]:1:COMP_CWORD}"))
+}
+complete -o default -F _{{name}} {{name}}
+`,
+	"zsh": `#compdef {{name}}
+_{{name}}() {
+	local -a candidates
+	candidates=("${(@f)$({{name}} __complete "${(@)words[2,CURRENT]}")}")
+	if [[ -n "${candidates[1]}" ]]; then
+		compadd -a candidates
+	else
+		_files
+	fi
+}
+compdef _{{name}} {{name}}
+`,
+	"fish": `function __{{name}}_complete
+	set -l words (commandline -opc)
+	{{name}} __complete $words[2..-1] (commandline -ct)
+end
+complete -c {{name}} -a '(__{{name}}_complete)'
+`,
+}
+
+// commandFlags returns the flags of c, or nil when it takes none itself.
+func commandFlags(c command) *flag.FlagSet {
+	var flags *flag.FlagSet
+	describeFlags = func(f *flag.FlagSet) { flags = f }
+	defer func() { describeFlags = nil }()
+	c.run(nil, c.name, nil)
+	return flags
+}
+
+// repoCompletions suggests -include and -exclude expressions built from the
+// calls of the repository at repoPath.
+func repoCompletions(repoPath string) []string {
+	var years = map[int]bool{}
+	var contacts = map[string]bool{}
+	err := calls.StreamCalls(calls.RepositoryFile(repoPath), func(c calls.Call) error {
+		years[c.Time().UTC().Year()] = true
+		if c.ContactName != "" && c.ContactName != "(Unknown)" {
+			contacts[c.ContactName] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil
+	}
+
+	var candidates []string
+	for year := range years {
+		candidates = append(candidates, fmt.Sprintf("date>=%d-01-01", year), fmt.Sprintf("date<%d-01-01", year+1))
+	}
+	for contact := range contacts {
+		candidates = append(candidates, "contact="+contact)
+	}
+	return candidates
+}
+
+// complete returns the candidates for the last of words, which follow the
+// program name on the command line.
+func complete(words []string) []string {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	var current = words[len(words)-1]
+	var typed = words[:len(words)-1]
+
+	// find the command being typed, which is import when none is named
+	var cmd = command{name: "import", run: runImport}
+	var choices []command
+	if len(typed) == 0 {
+		choices = subcommands()
+	} else {
+		for _, c := range subcommands() {
+			if typed[0] == c.name {
+				cmd = c
+				typed = typed[1:]
+				break
+			}
+		}
+	}
+	if nested, ok := nestedSubcommands[cmd.name]; ok {
+		choices = nested()
+		for _, c := range choices {
+			if len(typed) > 0 && typed[0] == c.name {
+				cmd, choices = c, nil
+				typed = typed[1:]
+				break
+			}
+		}
+	}
+
+	var candidates []string
+	var flags = commandFlags(cmd)
+	var previous *flag.Flag
+	if flags != nil && len(typed) > 0 {
+		previous = flags.Lookup(strings.TrimLeft(typed[len(typed)-1], "-"))
+	}
+	if previous != nil && !isBoolFlag(previous) {
+		// the flag value is being typed
+		switch previous.Name {
+		case "include", "exclude":
+			for _, f := range filterFields {
+				candidates = append(candidates, f+"=")
+			}
+			candidates = append(candidates, repoCompletions(typedRepo(typed))...)
+		case "ignore":
+			// complete the last of the comma separated types
+			var typedTypes = current[:strings.LastIndex(current, ",")+1]
+			for _, r := range validation.Rules() {
+				candidates = append(candidates, typedTypes+string(r.Type))
+			}
+		default:
+			if values := usageValues.FindString(previous.Usage); values != "" {
+				candidates = strings.Split(values, "|")
+			}
+		}
+	} else if strings.HasPrefix(current, "-") && flags != nil {
+		flags.VisitAll(func(f *flag.Flag) {
+			candidates = append(candidates, "-"+f.Name)
+		})
+	} else {
+		for _, c := range choices {
+			candidates = append(candidates, c.name)
+		}
+	}
+
+	var matching []string
+	for _, c := range candidates {
+		if strings.HasPrefix(c, current) {
+			matching = append(matching, c)
+		}
+	}
+	sort.Strings(matching)
+	return matching
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// typedRepo returns the -repo value among words, defaulting to the current
+// directory.
+func typedRepo(words []string) string {
+	var repo = "."
+	for i, w := range words {
+		w = "-" + strings.TrimLeft(w, "-")
+		if w == "-repo" && i+1 < len(words) {
+			repo = words[i+1]
+		} else if strings.HasPrefix(w, "-repo=") {
+			repo = strings.TrimPrefix(w, "-repo=")
+		}
+	}
+	return repo
+}
+
+func runComplete(words []string) (exitCode int, output *string, err error) {
+	for _, c := range complete(words) {
+		fmt.Println(c)
+	}
+	return 0, nil, nil
+}
+
+type completionConfig struct {
+	shell string
+}
+
+func parseCompletionFlags(s *settings, progname string, args []string) (conf *completionConfig, output string, err error) {
+	flags := flag.NewFlagSet(progname, flag.ContinueOnError)
+	var buf bytes.Buffer
+	flags.SetOutput(&buf)
+
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage of %s bash|zsh|fish:\n", progname)
+		flags.PrintDefaults()
+	}
+
+	err = parseArgs(s, flags, args)
+	if err != nil {
+		return nil, buf.String(), err
+	}
+	var c completionConfig
+	if flags.NArg() > 0 {
+		c.shell = flags.Arg(0)
+	}
+	return &c, buf.String(), nil
+}
+
+func validateCompletionConfig(conf *completionConfig) error {
+	if _, ok := completionScripts[conf.shell]; !ok {
+		return fmt.Errorf("Unknown shell %q, expected one of bash, zsh, fish", conf.shell)
+	}
+	return nil
+}
+
+// writeCompletionScript writes the completion script of shell for the
+// program installed as name.
+func writeCompletionScript(w io.Writer, shell, name string) error {
+	_, err := io.WriteString(w, strings.ReplaceAll(completionScripts[shell], "{{name}}", name))
+	return err
+}
+
+func runCompletion(s *settings, progname string, args []string) (exitCode int, output *string, err error) {
+	conf, o, err := parseCompletionFlags(s, progname, args)
+	if err == flag.ErrHelp {
+		return 4, nil, err
+	} else if err != nil {
+		return 3, &o, err
+	}
+
+	err = validateCompletionConfig(conf)
+	if err != nil {
+		return 2, nil, err
+	}
+
+	var name = filepath.Base(strings.Fields(progname)[0])
+	err = writeCompletionScript(os.Stdout, conf.shell, name)
+	if err != nil {
+		return 1, nil, err
+	}
+
+	return 0, nil, nil
+}
@@ -0,0 +1,61 @@
+package mobilecombackup
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/phillipgreen/mobilecombackup/internal/test_support"
+)
+
+func TestComplete(t *testing.T) {
+	tmpdir := t.TempDir()
+	err := test_support.CopyDir("../../testdata", tmpdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repoDir := filepath.Join(tmpdir, "archive")
+
+	var tests = []struct {
+		words []string
+		want  []string
+	}{
+		{[]string{"st"}, []string{"stats"}},
+		{[]string{"stats", ""}, []string{"calls", "coverage", "group"}},
+		{[]string{"stats", "calls", "-f"}, []string{"-format"}},
+		{[]string{"stats", "calls", "-format", ""}, []string{"csv", "json", "table", "yaml"}},
+		{[]string{"validate", "-format", "s"}, []string{"sarif"}},
+		{[]string{"-qu"}, []string{"-quiet"}},
+		{[]string{"-quiet", "x"}, nil},
+		{[]string{"-repo", repoDir, "-include", "contact=J"}, []string{"contact=Jack Daniels", "contact=John Stuart"}},
+		{[]string{"-repo=" + repoDir, "-exclude", "date>=2015"}, []string{"date>=2015-01-01"}},
+		{[]string{"-include", "da"}, []string{"date="}},
+		{[]string{"validate", "-ignore", "count-mismatch,du"}, []string{"count-mismatch,duplicate-record"}},
+		{[]string{"validate", "-fail-on", "n"}, []string{"never"}},
+	}
+	for _, tt := range tests {
+		got := complete(tt.words)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("complete(%q) got %q, want %q", tt.words, got, tt.want)
+		}
+	}
+
+	if got := complete(nil); len(got) != len(subcommands()) {
+		t.Errorf("complete(nil) got %q, want every command", got)
+	}
+}
+
+func TestWriteCompletionScript(t *testing.T) {
+	for shell := range completionScripts {
+		var buf bytes.Buffer
+		err := writeCompletionScript(&buf, shell, "mcb")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(buf.String(), "mcb __complete") || strings.Contains(buf.String(), "{{") {
+			t.Errorf("%s script got %q", shell, buf.String())
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package mobilecombackup
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/phillipgreen/mobilecombackup/pkg/calls"
+)
+
+type compressConfig struct {
+	repoPath   string
+	decompress bool
+	settings   *settings
+}
+
+func parseCompressFlags(s *settings, progname string, args []string) (conf *compressConfig, output string, err error) {
+	flags := flag.NewFlagSet(progname, flag.ContinueOnError)
+	var buf bytes.Buffer
+	flags.SetOutput(&buf)
+
+	var c = compressConfig{settings: s}
+	flags.StringVar(&c.repoPath, "repo", ".", "path which contains repository")
+	flags.BoolVar(&c.decompress, "d", false, "decompress instead of compress")
+
+	err = parseArgs(s, flags, args)
+	if err != nil {
+		return nil, buf.String(), err
+	}
+	return &c, buf.String(), nil
+}
+
+// convertCallsFile rewrites the repository's calls file as compressed or
+// plain XML, keeping its content byte for byte. It returns the new path.
+func convertCallsFile(repoPath string, compress bool) (string, error) {
+	var source = calls.RepositoryFile(repoPath)
+	if calls.IsCompressed(source) && compress {
+		return source, fmt.Errorf("%s is already compressed", source)
+	}
+	if !calls.IsCompressed(source) && !compress {
+		return source, fmt.Errorf("%s is not compressed", source)
+	}
+	var target = strings.TrimSuffix(source, calls.CompressedSuffix)
+	if compress {
+		target = source + calls.CompressedSuffix
+	}
+
+	err := calls.ConvertCalls(source, target)
+	if err != nil {
+		return source, err
+	}
+	return target, nil
+}
+
+func runCompress(s *settings, progname string, args []string) (exitCode int, output *string, err error) {
+	conf, o, err := parseCompressFlags(s, progname, args)
+	if err == flag.ErrHelp {
+		return 4, nil, err
+	} else if err != nil {
+		return 3, &o, err
+	}
+
+	err = checkWritable(conf.settings, conf.repoPath)
+	if err != nil {
+		return 2, nil, err
+	}
+
+	target, err := convertCallsFile(conf.repoPath, !conf.decompress)
+	if err != nil {
+		return 1, nil, err
+	}
+
+	fmt.Printf("Wrote %s\n", target)
+	return 0, nil, nil
+}
@@ -0,0 +1,103 @@
+package mobilecombackup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/phillipgreen/mobilecombackup/internal/test_support"
+	"github.com/phillipgreen/mobilecombackup/pkg/calls"
+	"github.com/phillipgreen/mobilecombackup/pkg/validation"
+)
+
+func TestConvertCallsFile(t *testing.T) {
+	tmpdir := t.TempDir()
+	err := test_support.CopyDir("../../testdata", tmpdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repoDir := filepath.Join(tmpdir, "archive")
+	original, err := os.ReadFile(filepath.Join(repoDir, "calls.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var indexFile = calls.IndexFile(repoDir)
+	err = calls.BuildIndex(calls.RepositoryFile(repoDir), indexFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	target, err := convertCallsFile(repoDir, true)
+	if err != nil {
+		t.Fatalf("err got %v, want nil", err)
+	}
+	if target != filepath.Join(repoDir, "calls.xml.gz") || calls.RepositoryFile(repoDir) != target {
+		t.Errorf("target got %s, repository file %s", target, calls.RepositoryFile(repoDir))
+	}
+	if _, err := os.Stat(filepath.Join(repoDir, "calls.xml")); !os.IsNotExist(err) {
+		t.Errorf("plain calls file still exists: %v", err)
+	}
+	// the conversion is a commit like any other
+	if g, err := calls.ReadGeneration(calls.GenerationFile(repoDir)); err != nil || g != 2 {
+		t.Errorf("generation got %d, %v, want 2", g, err)
+	}
+	if err := calls.VerifyChecksum(target); err != nil {
+		t.Errorf("checksum of %s: %v", target, err)
+	}
+	if _, err := os.Stat(calls.ChecksumFile(filepath.Join(repoDir, "calls.xml"))); !os.IsNotExist(err) {
+		t.Errorf("checksum of the plain calls file still exists: %v", err)
+	}
+	idx, err := calls.OpenIndex(indexFile, target)
+	if err != nil {
+		t.Errorf("index of %s: %v", target, err)
+	} else {
+		idx.Close()
+	}
+
+	// the compressed repository can be validated and imported into
+	result, err := validation.ValidateRepository(repoDir)
+	if err != nil || len(result.Violations) != 0 {
+		t.Errorf("validate got %v, %v", result.Violations, err)
+	}
+	processor, err := Init(repoDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := processor.Process(filepath.Join(tmpdir, "to_process"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Calls.Total != 22 {
+		t.Errorf("total got %d, want 22", res.Calls.Total)
+	}
+	if count, err := calls.CountCalls(calls.RepositoryFile(repoDir)); err != nil || count != 22 {
+		t.Errorf("stored got %d, %v, want 22", count, err)
+	}
+
+	_, err = convertCallsFile(repoDir, true)
+	if err == nil {
+		t.Errorf("compressing twice got nil err")
+	}
+
+	// round trip keeps the content
+	err = os.WriteFile(filepath.Join(tmpdir, "calls.xml"), original, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = convertCallsFile(tmpdir, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = convertCallsFile(tmpdir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roundTripped, err := os.ReadFile(filepath.Join(tmpdir, "calls.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(roundTripped) != string(original) {
+		t.Errorf("round trip changed content")
+	}
+}
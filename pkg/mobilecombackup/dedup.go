@@ -0,0 +1,110 @@
+package mobilecombackup
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/phillipgreen/mobilecombackup/pkg/calls"
+	"github.com/phillipgreen/mobilecombackup/pkg/phone"
+)
+
+type dedupAuditConfig struct {
+	repoPath string
+	region   string
+	window   time.Duration
+	format   string
+}
+
+func parseDedupAuditFlags(s *settings, progname string, args []string) (conf *dedupAuditConfig, output string, err error) {
+	flags := flag.NewFlagSet(progname, flag.ContinueOnError)
+	var buf bytes.Buffer
+	flags.SetOutput(&buf)
+
+	var c dedupAuditConfig
+	flags.StringVar(&c.repoPath, "repo", ".", "path which contains repository")
+	flags.StringVar(&c.region, "region", "US", "region used to normalize national phone numbers (empty to compare numbers as written)")
+	flags.DurationVar(&c.window, "window", time.Minute, "report calls with the same number at most this far apart")
+	flags.StringVar(&c.format, "format", "table", "output format: "+strings.Join(renderFormats, "|"))
+
+	err = parseArgs(s, flags, args)
+	if err != nil {
+		return nil, buf.String(), err
+	}
+	return &c, buf.String(), nil
+}
+
+func validateDedupAuditConfig(conf *dedupAuditConfig) error {
+	if conf.window < 0 {
+		return fmt.Errorf("Window must not be negative, got %v", conf.window)
+	}
+	if conf.region != "" && !phone.IsRegion(conf.region) {
+		return fmt.Errorf("Unknown region %q, expected one of %s", conf.region, strings.Join(phone.Regions(), ", "))
+	}
+	return checkFormat(conf.format, renderFormats)
+}
+
+func writeNearDuplicates(w io.Writer, format string, found []calls.NearDuplicate) error {
+	if format == "json" || format == "yaml" {
+		return writeStructured(w, format, found)
+	}
+
+	var rows = make([][]string, 0, len(found))
+	for _, d := range found {
+		rows = append(rows, []string{
+			d.First.Number,
+			d.First.Time().UTC().Format(time.RFC3339),
+			d.Second.Time().UTC().Format(time.RFC3339),
+			time.Duration(d.SecondsApart * float64(time.Second)).String(),
+			d.First.Type + "/" + d.Second.Type,
+			d.First.Duration + "/" + d.Second.Duration,
+		})
+	}
+	return writeRows(w, format, []string{"NUMBER", "FIRST", "SECOND", "APART", "TYPES", "DURATIONS"}, rows)
+}
+
+func runDedupAudit(s *settings, progname string, args []string) (exitCode int, output *string, err error) {
+	conf, o, err := parseDedupAuditFlags(s, progname, args)
+	if err == flag.ErrHelp {
+		return 4, nil, err
+	} else if err != nil {
+		return 3, &o, err
+	}
+
+	err = validateDedupAuditConfig(conf)
+	if err != nil {
+		return 2, nil, err
+	}
+
+	all, err := calls.ReadCalls(calls.RepositoryFile(conf.repoPath))
+	if err != nil {
+		return 1, nil, err
+	}
+
+	err = writeNearDuplicates(os.Stdout, conf.format, calls.FindNearDuplicates(all, conf.region, conf.window))
+	if err != nil {
+		return 1, nil, err
+	}
+	return 0, nil, nil
+}
+
+func dedupSubcommands() []command {
+	return []command{
+		{"audit", "list calls with the same number close together for review", runDedupAudit},
+	}
+}
+
+func runDedup(s *settings, progname string, args []string) (exitCode int, output *string, err error) {
+	var names []string
+	for _, c := range dedupSubcommands() {
+		if len(args) > 0 && args[0] == c.name {
+			return c.run(s, progname+" "+c.name, args[1:])
+		}
+		names = append(names, c.name)
+	}
+	return 2, nil, fmt.Errorf("Usage of %s: expected one of %s", progname, strings.Join(names, ", "))
+}
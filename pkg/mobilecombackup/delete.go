@@ -0,0 +1,106 @@
+package mobilecombackup
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/phillipgreen/mobilecombackup/pkg/calls"
+	"github.com/phillipgreen/mobilecombackup/pkg/filter"
+)
+
+type deleteConfig struct {
+	repoPath string
+	hashes   stringsFlag
+	includes stringsFlag
+	excludes stringsFlag
+	reason   string
+	dryRun   bool
+	format   string
+	settings *settings
+}
+
+func parseDeleteFlags(s *settings, progname string, args []string) (conf *deleteConfig, output string, err error) {
+	flags := flag.NewFlagSet(progname, flag.ContinueOnError)
+	var buf bytes.Buffer
+	flags.SetOutput(&buf)
+
+	var c = deleteConfig{settings: s}
+	flags.StringVar(&c.repoPath, "repo", ".", "path which contains repository")
+	flags.Var(&c.hashes, "hash", "delete the call with this record hash (repeatable)")
+	flags.Var(&c.includes, "include", "delete the calls matching this expression, such as 'number=5555550013' (repeatable, all must match)")
+	flags.Var(&c.excludes, "exclude", "do not delete calls matching this expression (repeatable)")
+	flags.StringVar(&c.reason, "reason", "", "why the calls are deleted, kept in their tombstones")
+	flags.BoolVar(&c.dryRun, "dry-run", false, "report the calls which would be deleted without deleting them")
+	flags.StringVar(&c.format, "format", "table", "output format: "+strings.Join(renderFormats, "|"))
+
+	err = parseArgs(s, flags, args)
+	if err != nil {
+		return nil, buf.String(), err
+	}
+	return &c, buf.String(), nil
+}
+
+func validateDeleteConfig(conf *deleteConfig) error {
+	if len(conf.hashes) == 0 && len(conf.includes) == 0 {
+		return errors.New("Atleast one -hash or -include must select the calls to delete")
+	}
+	if _, err := filter.New(conf.includes, conf.excludes); err != nil {
+		return fmt.Errorf("Invalid filter: %w", err)
+	}
+	return checkFormat(conf.format, renderFormats)
+}
+
+func runDelete(s *settings, progname string, args []string) (exitCode int, output *string, err error) {
+	conf, o, err := parseDeleteFlags(s, progname, args)
+	if err == flag.ErrHelp {
+		return 4, nil, err
+	} else if err != nil {
+		return 3, &o, err
+	}
+
+	err = validateDeleteConfig(conf)
+	if err != nil {
+		return 2, nil, err
+	}
+	if !conf.dryRun {
+		err = checkWritable(conf.settings, conf.repoPath)
+		if err != nil {
+			return 2, nil, err
+		}
+	}
+
+	selected, _ := callSelector(conf.hashes, conf.includes, conf.excludes)
+	tombstones, err := calls.DeleteCalls(conf.repoPath, selected, conf.reason, conf.dryRun)
+	if err != nil {
+		return 1, nil, err
+	}
+
+	if conf.format == "json" || conf.format == "yaml" {
+		if tombstones == nil {
+			tombstones = []calls.Tombstone{}
+		}
+		err = writeStructured(os.Stdout, conf.format, tombstones)
+	} else {
+		var rows [][]string
+		for _, ts := range tombstones {
+			rows = append(rows, []string{ts.Record, ts.Number, ts.Duration, strconv.Itoa(ts.Date), ts.Type})
+		}
+		err = writeRows(os.Stdout, conf.format, []string{"RECORD", "NUMBER", "DURATION", "DATE", "TYPE"}, rows)
+	}
+	if err != nil {
+		return 1, nil, err
+	}
+	if conf.format == "table" {
+		var verb = "Deleted"
+		if conf.dryRun {
+			verb = "Would delete"
+		}
+		fmt.Printf("%s %d calls\n", verb, len(tombstones))
+	}
+	return 0, nil, nil
+}
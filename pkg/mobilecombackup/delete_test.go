@@ -0,0 +1,35 @@
+package mobilecombackup
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateDeleteConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"hash", []string{"-hash", "abc"}, ""},
+		{"include", []string{"-include", "number=5555550004", "-reason", "spam"}, ""},
+		{"no selector", []string{"-reason", "spam"}, "select"},
+		{"bad filter", []string{"-include", "number"}, "Invalid filter"},
+		{"bad format", []string{"-hash", "abc", "-format", "xml"}, "Unknown format"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf, _, err := parseDeleteFlags(nil, "delete", tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = validateDeleteConfig(conf)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("err got %v, want nil", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("err got %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,260 @@
+package mobilecombackup
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/phillipgreen/mobilecombackup/pkg/calls"
+	"github.com/phillipgreen/mobilecombackup/pkg/validation"
+)
+
+// Statuses of a doctor check.
+const (
+	checkOK      = "ok"
+	checkWarning = "warning"
+	checkFailed  = "failed"
+)
+
+// minimumFree is the free disk space below which doctor warns, however small
+// the repository is.
+const minimumFree = 100 << 20
+
+// minimumOpenFiles is the open file limit below which doctor warns, as
+// concurrent imports each hold several files open.
+const minimumOpenFiles = 256
+
+// doctorCheck is the outcome of one check of the environment or repository.
+type doctorCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail"`
+	// Suggestion says how to fix a check which is not ok.
+	Suggestion string `json:"suggestion,omitempty"`
+}
+
+type doctorConfig struct {
+	repoPath string
+	format   string
+	settings *settings
+}
+
+func parseDoctorFlags(s *settings, progname string, args []string) (conf *doctorConfig, output string, err error) {
+	flags := flag.NewFlagSet(progname, flag.ContinueOnError)
+	var buf bytes.Buffer
+	flags.SetOutput(&buf)
+
+	var c = doctorConfig{settings: s}
+	flags.StringVar(&c.repoPath, "repo", ".", "path which contains repository")
+	flags.StringVar(&c.format, "format", "table", "output format: "+strings.Join(renderFormats, "|"))
+
+	err = parseArgs(s, flags, args)
+	if err != nil {
+		return nil, buf.String(), err
+	}
+	return &c, buf.String(), nil
+}
+
+// diagnose runs the checks of the repository at repoPath and its environment.
+// Checks which need a repository are left out when there is none, and those
+// which create files in it when it is read-only under s.
+func diagnose(s *settings, repoPath string) []doctorCheck {
+	var callsFile = calls.RepositoryFile(repoPath)
+	info, err := os.Stat(callsFile)
+	if err != nil {
+		return []doctorCheck{{"repository", checkFailed, err.Error(),
+			fmt.Sprintf("create one with: mobilecombackup init -repo %s", repoPath)}}
+	}
+	var checks = []doctorCheck{
+		{Name: "repository", Status: checkOK, Detail: callsFile},
+		checkRepositoryWritable(s, repoPath),
+		checkDiskSpace(repoPath, info.Size()),
+		checkOpenFiles(),
+	}
+	if checkWritable(s, repoPath) == nil {
+		checks = append(checks, checkCaseSensitivity(repoPath))
+	}
+	return append(checks, checkCommitState(repoPath), checkValidation(repoPath))
+}
+
+func checkRepositoryWritable(s *settings, repoPath string) doctorCheck {
+	var c = doctorCheck{Name: "writable"}
+	if err := checkWritable(s, repoPath); err != nil {
+		c.Status, c.Detail = checkWarning, err.Error()
+		c.Suggestion = "imports and other changes are refused until read-only mode is turned off"
+		return c
+	}
+	f, err := os.CreateTemp(repoPath, ".doctor-*")
+	if err != nil {
+		c.Status, c.Detail = checkFailed, err.Error()
+		c.Suggestion = "give the user running mobilecombackup write permission on " + repoPath
+		return c
+	}
+	f.Close()
+	os.Remove(f.Name())
+	c.Status, c.Detail = checkOK, "files can be created in "+repoPath
+	return c
+}
+
+// checkDiskSpace warns when there is not room for a commit, which writes a new
+// copy of the calls file before replacing the old one.
+func checkDiskSpace(repoPath string, repositorySize int64) doctorCheck {
+	var c = doctorCheck{Name: "disk space"}
+	free, err := diskFree(repoPath)
+	if err != nil {
+		c.Status, c.Detail = checkWarning, err.Error()
+		return c
+	}
+	var needed = uint64(2 * repositorySize)
+	if needed < minimumFree {
+		needed = minimumFree
+	}
+	c.Detail = formatSize(int64(free)) + " free"
+	if free < needed {
+		c.Status = checkWarning
+		c.Suggestion = fmt.Sprintf("free up space: atleast %s is needed to rewrite the repository safely", formatSize(int64(needed)))
+		return c
+	}
+	c.Status = checkOK
+	return c
+}
+
+func checkOpenFiles() doctorCheck {
+	var c = doctorCheck{Name: "open files"}
+	limit, err := openFileLimit()
+	if err != nil {
+		c.Status, c.Detail = checkWarning, err.Error()
+		return c
+	}
+	c.Detail = fmt.Sprintf("limit of %d open files", limit)
+	if limit < minimumOpenFiles {
+		c.Status = checkWarning
+		c.Suggestion = fmt.Sprintf("raise the limit with 'ulimit -n %d' or import with fewer -workers", 4*minimumOpenFiles)
+		return c
+	}
+	c.Status = checkOK
+	return c
+}
+
+// checkCaseSensitivity warns about file systems which do not tell apart names
+// differing only in case, where backup files named that way overwrite each
+// other when copied in.
+func checkCaseSensitivity(repoPath string) doctorCheck {
+	var c = doctorCheck{Name: "case sensitivity"}
+	f, err := os.CreateTemp(repoPath, ".doctor-case-*")
+	if err != nil {
+		c.Status, c.Detail = checkWarning, err.Error()
+		return c
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	var upper = filepath.Join(repoPath, strings.ToUpper(filepath.Base(f.Name())))
+	if _, err := os.Stat(upper); err == nil {
+		c.Status, c.Detail = checkWarning, "the file system ignores the case of file names"
+		c.Suggestion = "rename backup files whose names differ only in case before importing them"
+		return c
+	}
+	c.Status, c.Detail = checkOK, "the file system tells apart file names by case"
+	return c
+}
+
+// checkCommitState reports a commit in progress, which is either another
+// mobilecombackup changing the repository or one which was interrupted.
+func checkCommitState(repoPath string) doctorCheck {
+	var c = doctorCheck{Name: "commit"}
+	g, err := calls.ReadGeneration(calls.GenerationFile(repoPath))
+	if err != nil {
+		c.Status, c.Detail = checkFailed, err.Error()
+		c.Suggestion = "remove " + calls.GenerationFile(repoPath) + " if no other mobilecombackup is running"
+		return c
+	}
+	if g%2 == 1 {
+		c.Status, c.Detail = checkWarning, fmt.Sprintf("generation %d: a commit is in progress or was interrupted", g)
+		c.Suggestion = "if no other mobilecombackup is running, check the repository with: mobilecombackup verify -repo " + repoPath
+		return c
+	}
+	c.Status, c.Detail = checkOK, fmt.Sprintf("generation %d: no commit in progress", g)
+	return c
+}
+
+func checkValidation(repoPath string) doctorCheck {
+	var c = doctorCheck{Name: "validation"}
+	result, err := validation.ValidateRepository(repoPath)
+	if err != nil {
+		c.Status, c.Detail = checkFailed, err.Error()
+		return c
+	}
+	var errs, warnings = result.Errors(), len(result.Violations) - result.Errors()
+	c.Detail = fmt.Sprintf("%d errors, %d warnings", errs, warnings)
+	switch {
+	case errs > 0:
+		c.Status = checkFailed
+	case warnings > 0:
+		c.Status = checkWarning
+	default:
+		c.Status = checkOK
+		return c
+	}
+	c.Suggestion = "see the violations with: mobilecombackup validate -repo " + repoPath
+	return c
+}
+
+// writeDoctorChecks writes checks in format, followed by the suggestions when
+// it is a table.
+func writeDoctorChecks(w io.Writer, format string, checks []doctorCheck) error {
+	if format == "json" || format == "yaml" {
+		return writeStructured(w, format, checks)
+	}
+	var rows [][]string
+	for _, c := range checks {
+		rows = append(rows, []string{c.Name, c.Status, c.Detail, c.Suggestion})
+	}
+	if format == "csv" {
+		return writeRows(w, format, []string{"CHECK", "STATUS", "DETAIL", "SUGGESTION"}, rows)
+	}
+	for i := range rows {
+		rows[i] = rows[i][:3]
+	}
+	err := writeRows(w, format, []string{"CHECK", "STATUS", "DETAIL"}, rows)
+	for _, c := range checks {
+		if err == nil && c.Suggestion != "" {
+			_, err = fmt.Fprintf(w, "%s: %s\n", c.Name, c.Suggestion)
+		}
+	}
+	return err
+}
+
+func runDoctor(s *settings, progname string, args []string) (exitCode int, output *string, err error) {
+	conf, o, err := parseDoctorFlags(s, progname, args)
+	if err == flag.ErrHelp {
+		return 4, nil, err
+	} else if err != nil {
+		return 3, &o, err
+	}
+
+	err = checkFormat(conf.format, renderFormats)
+	if err != nil {
+		return 2, nil, err
+	}
+
+	var checks = diagnose(conf.settings, conf.repoPath)
+	err = writeDoctorChecks(os.Stdout, conf.format, checks)
+	if err != nil {
+		return 1, nil, err
+	}
+	var failed int
+	for _, c := range checks {
+		if c.Status == checkFailed {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return 1, nil, fmt.Errorf("%d checks failed", failed)
+	}
+	return 0, nil, nil
+}
@@ -0,0 +1,24 @@
+package mobilecombackup
+
+import "syscall"
+
+// diskFree returns how many bytes an unprivileged user can still write to the
+// file system holding path.
+func diskFree(path string) (uint64, error) {
+	var fs syscall.Statfs_t
+	err := syscall.Statfs(path, &fs)
+	if err != nil {
+		return 0, err
+	}
+	return fs.Bavail * uint64(fs.Bsize), nil
+}
+
+// openFileLimit returns how many files the process may have open at once.
+func openFileLimit() (uint64, error) {
+	var limit syscall.Rlimit
+	err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &limit)
+	if err != nil {
+		return 0, err
+	}
+	return limit.Cur, nil
+}
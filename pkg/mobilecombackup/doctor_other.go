@@ -0,0 +1,16 @@
+//go:build !linux
+// +build !linux
+
+package mobilecombackup
+
+import "errors"
+
+// diskFree is only supported on Linux.
+func diskFree(path string) (uint64, error) {
+	return 0, errors.New("free disk space can only be checked on Linux")
+}
+
+// openFileLimit is only supported on Linux.
+func openFileLimit() (uint64, error) {
+	return 0, errors.New("the open file limit can only be checked on Linux")
+}
@@ -0,0 +1,75 @@
+package mobilecombackup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/phillipgreen/mobilecombackup/internal/test_support"
+	"github.com/phillipgreen/mobilecombackup/pkg/calls"
+)
+
+func TestDiagnose(t *testing.T) {
+	var tests = []struct {
+		name    string
+		prepare func(repoDir string) error
+		check   string
+		status  string
+	}{
+		{"healthy", func(string) error { return nil }, "validation", checkOK},
+		{"missing repository", os.RemoveAll, "repository", checkFailed},
+		{"read only", func(repoDir string) error {
+			return os.WriteFile(filepath.Join(repoDir, RepositoryConfigFile), []byte("read_only: true\n"), 0644)
+		}, "writable", checkWarning},
+		{"interrupted commit", func(repoDir string) error {
+			return os.WriteFile(calls.GenerationFile(repoDir), []byte("7\n"), 0644)
+		}, "commit", checkWarning},
+		{"corrupt generation", func(repoDir string) error {
+			return os.WriteFile(calls.GenerationFile(repoDir), []byte("seven\n"), 0644)
+		}, "commit", checkFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpdir := t.TempDir()
+			err := test_support.CopyDir("../../testdata", tmpdir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			repoDir := filepath.Join(tmpdir, "archive")
+			err = tt.prepare(repoDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var found bool
+			for _, c := range diagnose(nil, repoDir) {
+				if c.Name != tt.check {
+					continue
+				}
+				found = true
+				if c.Status != tt.status {
+					t.Errorf("%s got %s: %s, want %s", c.Name, c.Status, c.Detail, tt.status)
+				}
+				if (c.Suggestion == "") != (c.Status == checkOK) {
+					t.Errorf("%s suggestion got %q with status %s", c.Name, c.Suggestion, c.Status)
+				}
+			}
+			if !found {
+				t.Errorf("check %s missing", tt.check)
+			}
+			// a read-only repository is left alone
+			if tt.name == "read only" {
+				for _, c := range diagnose(nil, repoDir) {
+					if c.Name == "case sensitivity" {
+						t.Errorf("read-only repository got a %s check", c.Name)
+					}
+				}
+			}
+			// the checks leave nothing behind
+			leftover, _ := filepath.Glob(filepath.Join(repoDir, ".doctor-*"))
+			if len(leftover) > 0 {
+				t.Errorf("left %v behind", leftover)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package mobilecombackup
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/phillipgreen/mobilecombackup/pkg/calls"
+	"github.com/phillipgreen/mobilecombackup/pkg/filter"
+)
+
+type editConfig struct {
+	repoPath string
+	hashes   stringsFlag
+	includes stringsFlag
+	excludes stringsFlag
+	sets     stringsFlag
+	reason   string
+	dryRun   bool
+	format   string
+	settings *settings
+}
+
+func parseEditFlags(s *settings, progname string, args []string) (conf *editConfig, output string, err error) {
+	flags := flag.NewFlagSet(progname, flag.ContinueOnError)
+	var buf bytes.Buffer
+	flags.SetOutput(&buf)
+
+	var c = editConfig{settings: s}
+	flags.StringVar(&c.repoPath, "repo", ".", "path which contains repository")
+	flags.Var(&c.hashes, "hash", "edit the call with this record hash (repeatable)")
+	flags.Var(&c.includes, "include", "edit the calls matching this expression, such as 'number=5555550013' (repeatable, all must match)")
+	flags.Var(&c.excludes, "exclude", "do not edit calls matching this expression (repeatable)")
+	flags.Var(&c.sets, "set", "field=value to change, where field is one of "+strings.Join(calls.EditableFields, ",")+" (repeatable)")
+	flags.StringVar(&c.reason, "reason", "", "why the calls are edited, kept in the audit log")
+	flags.BoolVar(&c.dryRun, "dry-run", false, "report the changes without making them")
+	flags.StringVar(&c.format, "format", "table", "output format: "+strings.Join(renderFormats, "|"))
+
+	err = parseArgs(s, flags, args)
+	if err != nil {
+		return nil, buf.String(), err
+	}
+	return &c, buf.String(), nil
+}
+
+// parseChanges parses -set values into the changes they make.
+func parseChanges(sets []string) ([]calls.Change, error) {
+	var changes []calls.Change
+	for _, s := range sets {
+		var i = strings.Index(s, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("Invalid change %q, expected field=value", s)
+		}
+		var field = s[:i]
+		var known bool
+		for _, f := range calls.EditableFields {
+			known = known || f == field
+		}
+		if !known {
+			return nil, fmt.Errorf("Unknown field %q, expected one of %s", field, strings.Join(calls.EditableFields, ", "))
+		}
+		changes = append(changes, calls.Change{Field: field, Value: s[i+1:]})
+	}
+	return changes, nil
+}
+
+func validateEditConfig(conf *editConfig) error {
+	if len(conf.hashes) == 0 && len(conf.includes) == 0 {
+		return errors.New("Atleast one -hash or -include must select the calls to edit")
+	}
+	if len(conf.sets) == 0 {
+		return errors.New("Atleast one -set must be specified")
+	}
+	if _, err := parseChanges(conf.sets); err != nil {
+		return err
+	}
+	if _, err := filter.New(conf.includes, conf.excludes); err != nil {
+		return fmt.Errorf("Invalid filter: %w", err)
+	}
+	return checkFormat(conf.format, renderFormats)
+}
+
+// callSelector returns whether a call is selected by record hashes and filter
+// includes and excludes. With both hashes and includes, a call must match both.
+func callSelector(hashes, includes, excludes []string) (func(*calls.Call) bool, error) {
+	var selected = map[string]bool{}
+	for _, h := range hashes {
+		selected[strings.ToLower(h)] = true
+	}
+	predicate, err := filter.New(includes, excludes)
+	if err != nil {
+		return nil, err
+	}
+	return func(c *calls.Call) bool {
+		if len(selected) > 0 && !selected[c.Hash()] {
+			return false
+		}
+		return predicate == nil || predicate(c)
+	}, nil
+}
+
+func runEdit(s *settings, progname string, args []string) (exitCode int, output *string, err error) {
+	conf, o, err := parseEditFlags(s, progname, args)
+	if err == flag.ErrHelp {
+		return 4, nil, err
+	} else if err != nil {
+		return 3, &o, err
+	}
+
+	err = validateEditConfig(conf)
+	if err != nil {
+		return 2, nil, err
+	}
+	if !conf.dryRun {
+		err = checkWritable(conf.settings, conf.repoPath)
+		if err != nil {
+			return 2, nil, err
+		}
+	}
+
+	changes, _ := parseChanges(conf.sets)
+	selected, _ := callSelector(conf.hashes, conf.includes, conf.excludes)
+	edits, err := calls.EditCalls(conf.repoPath, selected, changes, conf.reason, conf.dryRun)
+	if err != nil {
+		return 1, nil, err
+	}
+
+	if conf.format == "json" || conf.format == "yaml" {
+		if edits == nil {
+			edits = []calls.Edit{}
+		}
+		err = writeStructured(os.Stdout, conf.format, edits)
+	} else {
+		var rows [][]string
+		for _, e := range edits {
+			rows = append(rows, []string{e.Record, e.Field, e.Old, e.New})
+		}
+		err = writeRows(os.Stdout, conf.format, []string{"RECORD", "FIELD", "OLD", "NEW"}, rows)
+	}
+	if err != nil {
+		return 1, nil, err
+	}
+	if conf.format == "table" {
+		var verb = "Made"
+		if conf.dryRun {
+			verb = "Would make"
+		}
+		fmt.Printf("%s %d changes\n", verb, len(edits))
+	}
+	return 0, nil, nil
+}
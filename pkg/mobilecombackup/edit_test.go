@@ -0,0 +1,70 @@
+package mobilecombackup
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/phillipgreen/mobilecombackup/pkg/calls"
+)
+
+func TestValidateEditConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"hash", []string{"-hash", "abc", "-set", "contact_name=Alice"}, ""},
+		{"include", []string{"-include", "number=5555550004", "-set", "date=1", "-set", "type=2"}, ""},
+		{"no selector", []string{"-set", "contact_name=Alice"}, "select"},
+		{"no change", []string{"-hash", "abc"}, "-set"},
+		{"malformed change", []string{"-hash", "abc", "-set", "contact_name"}, "field=value"},
+		{"unknown field", []string{"-hash", "abc", "-set", "body=hi"}, "Unknown field"},
+		{"bad filter", []string{"-include", "number", "-set", "type=2"}, "Invalid filter"},
+		{"bad format", []string{"-hash", "abc", "-set", "type=2", "-format", "xml"}, "Unknown format"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf, _, err := parseEditFlags(nil, "edit", tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = validateEditConfig(conf)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("err got %v, want nil", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("err got %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCallSelector(t *testing.T) {
+	var a = calls.Call{Number: "5555550004", Duration: "0", Date: 1000, Type: calls.Incoming}
+	var b = calls.Call{Number: "5555550004", Duration: "0", Date: 2000, Type: calls.Incoming}
+	tests := []struct {
+		name string
+		args []string
+		a, b bool
+	}{
+		{"hash", []string{"-hash", strings.ToUpper(a.Hash())}, true, false},
+		{"include", []string{"-include", "number=5555550004"}, true, true},
+		{"hash and include", []string{"-hash", a.Hash(), "-include", "number=1"}, false, false},
+		{"exclude", []string{"-include", "number=5555550004", "-exclude", "date<1970-01-01T00:00:02Z"}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf, _, err := parseEditFlags(nil, "edit", tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			selected, err := callSelector(conf.hashes, conf.includes, conf.excludes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if selected(&a) != tt.a || selected(&b) != tt.b {
+				t.Errorf("selected got %v, %v, want %v, %v", selected(&a), selected(&b), tt.a, tt.b)
+			}
+		})
+	}
+}
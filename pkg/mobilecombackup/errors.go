@@ -0,0 +1,70 @@
+package mobilecombackup
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/phillipgreen/mobilecombackup/pkg/calls"
+	"github.com/phillipgreen/mobilecombackup/pkg/filter"
+)
+
+// ErrNotFound is matched by the errors of commands which found none of the
+// records they were asked for.
+var ErrNotFound = errors.New("not found")
+
+// notFoundError keeps the message of a command which found nothing while
+// matching ErrNotFound.
+type notFoundError struct {
+	msg string
+}
+
+func (e *notFoundError) Error() string { return e.msg }
+
+func (e *notFoundError) Is(target error) bool { return target == ErrNotFound }
+
+func notFound(format string, a ...interface{}) error {
+	return &notFoundError{fmt.Sprintf(format, a...)}
+}
+
+// Exit codes of failures which scripts may want to tell apart; 1 is any
+// other failure.
+const (
+	// exitInvalid is also the exit code of invalid configuration.
+	exitInvalid   = 2
+	exitIntegrity = 5
+	exitBusy      = 6
+	exitNotFound  = 7
+)
+
+// failureExitCodes maps the errors commands fail with to exit codes, the
+// first which matches applying.
+var failureExitCodes = []struct {
+	err  error
+	code int
+}{
+	{ErrReadOnly, exitInvalid},
+	{calls.ErrInvalidHash, exitInvalid},
+	{calls.ErrUnknownField, exitInvalid},
+	{calls.ErrInvalidValue, exitInvalid},
+	{filter.ErrInvalidExpression, exitInvalid},
+	{calls.ErrChecksumMismatch, exitIntegrity},
+	{calls.ErrCountMismatch, exitIntegrity},
+	{calls.ErrStaleIndex, exitIntegrity},
+	{calls.ErrCorruptIndex, exitIntegrity},
+	{calls.ErrDamagedRepository, exitIntegrity},
+	{calls.ErrSnapshotBusy, exitBusy},
+	{ErrNotFound, exitNotFound},
+}
+
+// failureExitCode refines the exit code 1 of a command which failed with err.
+func failureExitCode(exitCode int, err error) int {
+	if exitCode != 1 || err == nil {
+		return exitCode
+	}
+	for _, f := range failureExitCodes {
+		if errors.Is(err, f.err) {
+			return f.code
+		}
+	}
+	return exitCode
+}
@@ -0,0 +1,72 @@
+package mobilecombackup
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/phillipgreen/mobilecombackup/internal/test_support"
+	"github.com/phillipgreen/mobilecombackup/pkg/calls"
+)
+
+func TestFailureExitCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		exitCode int
+		err      error
+		want     int
+	}{
+		{"plain failure", 1, errors.New("boom"), 1},
+		{"checksum", 1, fmt.Errorf("verifying: %w", calls.ErrChecksumMismatch), exitIntegrity},
+		{"busy", 1, calls.ErrSnapshotBusy, exitBusy},
+		{"not found", 1, notFound("No records found for %q", "x"), exitNotFound},
+		{"invalid hash", 1, fmt.Errorf("%w %q", calls.ErrInvalidHash, "zz"), exitInvalid},
+		{"parse error kept", 3, calls.ErrChecksumMismatch, 3},
+		{"success", 0, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := failureExitCode(tt.exitCode, tt.err); got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	var err = notFound("No records found for %q", "x")
+	if err.Error() != `No records found for "x"` {
+		t.Errorf("message got %q", err.Error())
+	}
+}
+
+func TestRunNotFoundExitCode(t *testing.T) {
+	tmpdir := t.TempDir()
+	err := test_support.CopyDir("../../testdata", tmpdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var missing = strings.Repeat("0", 64)
+	exitCode, _, err := Run([]string{"mobilecombackup", "lookup", "-repo", filepath.Join(tmpdir, "archive"), missing})
+	if exitCode != exitNotFound || !errors.Is(err, ErrNotFound) {
+		t.Errorf("got exit code %d, err %v, want %d", exitCode, err, exitNotFound)
+	}
+}
+
+func TestRunDamagedRepositoryExitCode(t *testing.T) {
+	tmpdir := t.TempDir()
+	err := test_support.CopyDir("../../testdata", tmpdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var repoDir = filepath.Join(tmpdir, "archive")
+	err = os.WriteFile(calls.RepositoryFile(repoDir), []byte("<calls count=\"1\">\n  <call number=\"1\" dura"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exitCode, _, err := Run([]string{"mobilecombackup", "import", "-quiet", "-repo", repoDir, filepath.Join(tmpdir, "to_process")})
+	if exitCode != exitIntegrity || !errors.Is(err, calls.ErrDamagedRepository) {
+		t.Errorf("got exit code %d, err %v, want %d", exitCode, err, exitIntegrity)
+	}
+}
@@ -0,0 +1,212 @@
+package mobilecombackup
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/phillipgreen/mobilecombackup/pkg/calls"
+	"github.com/phillipgreen/mobilecombackup/pkg/contacts"
+	"github.com/phillipgreen/mobilecombackup/pkg/phone"
+)
+
+var exportFormats = []string{"text", "html"}
+
+type exportConfig struct {
+	repoPath string
+	contact  string
+	format   string
+	tz       string
+	region   string
+}
+
+func parseExportFlags(s *settings, progname string, args []string) (conf *exportConfig, output string, err error) {
+	flags := flag.NewFlagSet(progname, flag.ContinueOnError)
+	var buf bytes.Buffer
+	flags.SetOutput(&buf)
+
+	var c exportConfig
+	flags.StringVar(&c.repoPath, "repo", ".", "path which contains repository")
+	flags.StringVar(&c.contact, "contact", "", "contact name, or number, whose conversation is exported")
+	flags.StringVar(&c.format, "format", "text", "output format: "+strings.Join(exportFormats, "|"))
+	flags.StringVar(&c.tz, "tz", "Local", "IANA time zone times are shown in, such as America/New_York")
+	flags.StringVar(&c.region, "region", "US", "region used to match national phone numbers (empty to match numbers as written)")
+
+	err = parseArgs(s, flags, args)
+	if err != nil {
+		return nil, buf.String(), err
+	}
+	return &c, buf.String(), nil
+}
+
+func validateExportConfig(conf *exportConfig) error {
+	if conf.contact == "" {
+		return errors.New("A contact must be specified")
+	}
+	if conf.region != "" && !phone.IsRegion(conf.region) {
+		return fmt.Errorf("Unknown region %q, expected one of %s", conf.region, strings.Join(phone.Regions(), ", "))
+	}
+	for _, f := range exportFormats {
+		if conf.format == f {
+			return nil
+		}
+	}
+	return fmt.Errorf("Unknown format %q, expected one of %s", conf.format, strings.Join(exportFormats, ", "))
+}
+
+// transcriptEntry is one event of a conversation.
+type transcriptEntry struct {
+	Time        time.Time
+	Description string
+}
+
+// transcript is the conversation with a contact, in chronological order.
+type transcript struct {
+	Contact string
+	Entries []transcriptEntry
+}
+
+// describeCall returns how a call appears in a transcript.
+func describeCall(c *calls.Call) string {
+	var d = calls.TypeName(c.Type) + " call"
+	if seconds := c.Seconds(); seconds > 0 {
+		d += ", " + (time.Duration(seconds) * time.Second).String()
+	}
+	return d
+}
+
+// displayName returns the name of the contact of c, from the backup or else
+// from book, falling back to the number.
+func displayName(c *calls.Call, book *contacts.Book) string {
+	if name := c.Contact(); name != c.Number {
+		return name
+	}
+	if match, kind := book.MatchNumber(c.Number); kind != contacts.NoMatch {
+		return match.Name
+	}
+	return c.Number
+}
+
+// conversation collects the transcript of the repository at repoPath with
+// contact, a contact name compared ignoring case or a number matched as
+// contacts.Book.MatchNumber does, with times in loc. National numbers are
+// interpreted in region.
+func conversation(repoPath, contact, region string, loc *time.Location) (transcript, error) {
+	book, err := contacts.Load(contacts.File(repoPath))
+	if err != nil {
+		return transcript{}, err
+	}
+	book.Region = region
+	var wanted = contacts.Book{Region: region, Contacts: []contacts.Contact{{Numbers: []string{contact}}}}
+
+	var matched []calls.Call
+	err = calls.StreamCalls(calls.RepositoryFile(repoPath), func(c calls.Call) error {
+		if _, kind := wanted.MatchNumber(c.Number); kind != contacts.NoMatch || strings.EqualFold(displayName(&c, book), contact) {
+			matched = append(matched, c)
+		}
+		return nil
+	})
+	if err != nil {
+		return transcript{}, err
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		return matched[i].Date < matched[j].Date
+	})
+
+	var t = transcript{Contact: contact}
+	for i := range matched {
+		// show the name as the backups or contacts spell it
+		if name := displayName(&matched[i], book); strings.EqualFold(name, contact) {
+			t.Contact = name
+		}
+		t.Entries = append(t.Entries, transcriptEntry{matched[i].Time().In(loc), describeCall(&matched[i])})
+	}
+	return t, nil
+}
+
+var transcriptHTML = template.Must(template.New("transcript").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Conversation with {{.Contact}}</title>
+</head>
+<body>
+<h1>Conversation with {{.Contact}}</h1>
+<ol>
+{{- range .Entries}}
+<li><time datetime="{{.Time.Format "2006-01-02T15:04:05Z07:00"}}">{{.Time.Format "2006-01-02 15:04:05"}}</time> {{.Description}}</li>
+{{- end}}
+</ol>
+</body>
+</html>
+`))
+
+func writeTranscript(w io.Writer, format string, t transcript) error {
+	if format == "html" {
+		return transcriptHTML.Execute(w, t)
+	}
+	_, err := fmt.Fprintf(w, "Conversation with %s\n", t.Contact)
+	for _, e := range t.Entries {
+		if err != nil {
+			break
+		}
+		_, err = fmt.Fprintf(w, "%s  %s\n", e.Time.Format("2006-01-02 15:04:05"), e.Description)
+	}
+	return err
+}
+
+func runExportConversation(s *settings, progname string, args []string) (exitCode int, output *string, err error) {
+	conf, o, err := parseExportFlags(s, progname, args)
+	if err == flag.ErrHelp {
+		return 4, nil, err
+	} else if err != nil {
+		return 3, &o, err
+	}
+
+	err = validateExportConfig(conf)
+	if err != nil {
+		return 2, nil, err
+	}
+	loc, err := time.LoadLocation(conf.tz)
+	if err != nil {
+		return 2, nil, fmt.Errorf("Unknown time zone %q: %w", conf.tz, err)
+	}
+
+	t, err := conversation(conf.repoPath, conf.contact, conf.region, loc)
+	if err != nil {
+		return 1, nil, err
+	}
+	if len(t.Entries) == 0 {
+		return 1, nil, notFound("No records found for %q", conf.contact)
+	}
+
+	err = writeTranscript(os.Stdout, conf.format, t)
+	if err != nil {
+		return 1, nil, err
+	}
+	return 0, nil, nil
+}
+
+func exportSubcommands() []command {
+	return []command{
+		{"conversation", "write the records exchanged with one contact as a transcript", runExportConversation},
+	}
+}
+
+func runExport(s *settings, progname string, args []string) (exitCode int, output *string, err error) {
+	var names []string
+	for _, c := range exportSubcommands() {
+		if len(args) > 0 && args[0] == c.name {
+			return c.run(s, progname+" "+c.name, args[1:])
+		}
+		names = append(names, c.name)
+	}
+	return 2, nil, fmt.Errorf("Usage of %s: expected one of %s", progname, strings.Join(names, ", "))
+}
@@ -0,0 +1,86 @@
+package mobilecombackup
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/phillipgreen/mobilecombackup/internal/test_support"
+)
+
+func TestConversation(t *testing.T) {
+	tmpdir := t.TempDir()
+	err := test_support.CopyDir("../../testdata", tmpdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repoDir := filepath.Join(tmpdir, "archive")
+
+	for _, contact := range []string{"oscar wilde", "5555550004", "+1 (555) 555-0004"} {
+		got, err := conversation(repoDir, contact, "US", time.UTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got.Entries) < 2 {
+			t.Fatalf("%s: entries got %d, want atleast 2", contact, len(got.Entries))
+		}
+		var first = got.Entries[0]
+		if !first.Time.Equal(time.Unix(1411220005, 294e6)) || first.Description != "incoming call, 39s" {
+			t.Errorf("%s: first entry got %+v", contact, first)
+		}
+		for i := 1; i < len(got.Entries); i++ {
+			if got.Entries[i].Time.Before(got.Entries[i-1].Time) {
+				t.Errorf("%s: entry %d is out of order", contact, i)
+			}
+		}
+	}
+
+	got, err := conversation(repoDir, "oscar wilde", "US", time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Contact != "Oscar Wilde" {
+		t.Errorf("contact got %q, want %q", got.Contact, "Oscar Wilde")
+	}
+	var text, html bytes.Buffer
+	if err := writeTranscript(&text, "text", got); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(text.String(), "2014-09-20 13:33:25  incoming call, 39s\n") {
+		t.Errorf("text got %q", text.String())
+	}
+	if err := writeTranscript(&html, "html", got); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(html.String(), "<h1>Conversation with Oscar Wilde</h1>") {
+		t.Errorf("html got %q", html.String())
+	}
+}
+
+func TestConversationCompacted(t *testing.T) {
+	tmpdir := t.TempDir()
+	err := test_support.CopyDir("../../testdata", tmpdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repoDir := filepath.Join(tmpdir, "archive")
+	want, err := conversation(repoDir, "Oscar Wilde", "US", time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the names are then only in contacts.yaml
+	_, _, err = compactRepository(repoDir, false, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := conversation(repoDir, "oscar wilde", "US", time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Contact != "Oscar Wilde" || len(got.Entries) != len(want.Entries) {
+		t.Errorf("got %s with %d entries, want Oscar Wilde with %d", got.Contact, len(got.Entries), len(want.Entries))
+	}
+}
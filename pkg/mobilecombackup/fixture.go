@@ -0,0 +1,188 @@
+package mobilecombackup
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/phillipgreen/mobilecombackup/pkg/calls"
+)
+
+type fixtureConfig struct {
+	repoPath     string
+	backupFile   string
+	contacts     int
+	callsPerYear int
+	from         int
+	to           int
+	unknownShare float64
+	seed         int64
+	tz           string
+}
+
+func parseFixtureFlags(s *settings, progname string, args []string) (conf *fixtureConfig, output string, err error) {
+	flags := flag.NewFlagSet(progname, flag.ContinueOnError)
+	var buf bytes.Buffer
+	flags.SetOutput(&buf)
+
+	var c fixtureConfig
+	flags.StringVar(&c.repoPath, "repo", "", "path of the repository to create, which must not exist yet")
+	flags.StringVar(&c.backupFile, "backup", "", "also write the calls as a backup file to import at this path")
+	flags.IntVar(&c.contacts, "contacts", 50, "number of contacts calls are made with")
+	flags.IntVar(&c.callsPerYear, "calls-per-year", 1000, "number of calls in each year")
+	flags.IntVar(&c.from, "from", 2015, "first year with calls")
+	flags.IntVar(&c.to, "to", 2024, "last year with calls")
+	flags.Float64Var(&c.unknownShare, "unknown", 0.1, "share of calls with numbers which are not contacts")
+	flags.Int64Var(&c.seed, "seed", 1, "seed of the generator; the same seed generates the same calls")
+	flags.StringVar(&c.tz, "tz", "UTC", "IANA time zone readable dates are written in")
+
+	err = parseArgs(s, flags, args)
+	if err != nil {
+		return nil, buf.String(), err
+	}
+	return &c, buf.String(), nil
+}
+
+func validateFixtureConfig(conf *fixtureConfig) error {
+	if conf.repoPath == "" {
+		return errors.New("A repository path must be specified")
+	}
+	if conf.contacts < 1 || conf.callsPerYear < 0 {
+		return fmt.Errorf("Contacts must be atleast 1 and calls per year not negative, got %d and %d", conf.contacts, conf.callsPerYear)
+	}
+	if conf.from > conf.to {
+		return fmt.Errorf("First year %d is after last year %d", conf.from, conf.to)
+	}
+	if conf.unknownShare < 0 || conf.unknownShare > 1 {
+		return fmt.Errorf("Unknown share must be between 0 and 1, got %v", conf.unknownShare)
+	}
+	return nil
+}
+
+// fixtureTypes are the call types generated, weighted by how often they
+// occur.
+var fixtureTypes = []struct {
+	callType string
+	weight   int
+}{
+	{calls.Incoming, 40},
+	{calls.Outgoing, 40},
+	{calls.Missed, 14},
+	{calls.Voicemail, 3},
+	{calls.Rejected, 2},
+	{calls.Blocked, 1},
+}
+
+func fixtureType(rng *rand.Rand) string {
+	var total int
+	for _, t := range fixtureTypes {
+		total += t.weight
+	}
+	var n = rng.Intn(total)
+	for _, t := range fixtureTypes {
+		if n < t.weight {
+			return t.callType
+		}
+		n -= t.weight
+	}
+	return calls.Incoming
+}
+
+// generateCalls returns callsPerYear calls for each year of conf, in canonical
+// order. A few contacts get most of the calls, as they would in a real
+// backup, and unanswered calls have no duration.
+func generateCalls(conf *fixtureConfig, loc *time.Location) []calls.Call {
+	var rng = rand.New(rand.NewSource(conf.seed))
+	var popularity = rand.NewZipf(rng, 1.2, 1, uint64(conf.contacts-1))
+	var seen = map[calls.Key]bool{}
+
+	var all []calls.Call
+	for year := conf.from; year <= conf.to; year++ {
+		var start = time.Date(year, 1, 1, 0, 0, 0, 0, loc).UnixNano() / int64(time.Millisecond)
+		var end = time.Date(year+1, 1, 1, 0, 0, 0, 0, loc).UnixNano() / int64(time.Millisecond)
+		for i := 0; i < conf.callsPerYear; i++ {
+			var c = calls.Call{Date: int(start + rng.Int63n(end-start)), Type: fixtureType(rng), Duration: "0"}
+			if rng.Float64() < conf.unknownShare {
+				c.Number = fmt.Sprintf("+1555%07d", 5000000+rng.Intn(5000000))
+				c.ContactName = calls.UnknownContact
+			} else {
+				var contact = int(popularity.Uint64())
+				c.Number = fmt.Sprintf("+1555%07d", contact)
+				c.ContactName = fmt.Sprintf("Contact %d", contact+1)
+			}
+			switch c.Type {
+			case calls.Incoming, calls.Outgoing, calls.Voicemail:
+				c.Duration = fmt.Sprint(1 + int(rng.ExpFloat64()*180))
+			}
+			c.ReadableDate = c.FormatReadableDate(loc)
+			if seen[c.Key()] {
+				i--
+				continue
+			}
+			seen[c.Key()] = true
+			all = append(all, c)
+		}
+	}
+	calls.SortCanonical(all)
+	return all
+}
+
+// writeBackupFile writes generated as a backup file at filePath, as a phone
+// would.
+func writeBackupFile(filePath string, generated []calls.Call) error {
+	out, err := xml.MarshalIndent(calls.Calls{Calls: generated, Count: len(generated)}, "", "  ")
+	if err != nil {
+		return err
+	}
+	var content = append([]byte(xml.Header), out...)
+	return os.WriteFile(filePath, append(content, '\n'), 0644)
+}
+
+func runGenerateFixture(s *settings, progname string, args []string) (exitCode int, output *string, err error) {
+	conf, o, err := parseFixtureFlags(s, progname, args)
+	if err == flag.ErrHelp {
+		return 4, nil, err
+	} else if err != nil {
+		return 3, &o, err
+	}
+
+	err = validateFixtureConfig(conf)
+	if err != nil {
+		return 2, nil, err
+	}
+	loc, err := time.LoadLocation(conf.tz)
+	if err != nil {
+		return 2, nil, fmt.Errorf("Unknown time zone %q: %w", conf.tz, err)
+	}
+	if _, err := os.Stat(conf.repoPath); err == nil {
+		return 2, nil, fmt.Errorf("%s already exists", conf.repoPath)
+	}
+
+	var generated = generateCalls(conf, loc)
+	err = initRepository(conf.repoPath)
+	if err == nil {
+		err = calls.WriteCalls(calls.RepositoryFile(conf.repoPath), generated)
+	}
+	if err == nil && conf.backupFile != "" {
+		err = writeBackupFile(conf.backupFile, generated)
+	}
+	if err != nil {
+		return 1, nil, err
+	}
+
+	fmt.Printf("Generated %d calls with %d contacts in %s\n", len(generated), conf.contacts, conf.repoPath)
+	return 0, nil, nil
+}
+
+// hiddenSubcommands are run like subcommands but left out of the usage and
+// completions, being meant for development.
+func hiddenSubcommands() []command {
+	return []command{
+		{"generate-fixture", "create a repository of synthetic calls for benchmarks", runGenerateFixture},
+	}
+}
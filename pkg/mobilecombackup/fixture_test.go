@@ -0,0 +1,60 @@
+package mobilecombackup
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/phillipgreen/mobilecombackup/pkg/calls"
+	"github.com/phillipgreen/mobilecombackup/pkg/validation"
+)
+
+func TestGenerateFixture(t *testing.T) {
+	dir := t.TempDir()
+	var repoDir, backupFile = filepath.Join(dir, "repo"), filepath.Join(dir, "calls-fixture.xml")
+	exitCode, _, err := runGenerateFixture(nil, "generate-fixture", []string{
+		"-repo", repoDir, "-backup", backupFile, "-contacts", "5", "-calls-per-year", "50", "-from", "2020", "-to", "2021", "-seed", "7",
+	})
+	if exitCode != 0 || err != nil {
+		t.Fatalf("got exit code %d, err %v", exitCode, err)
+	}
+
+	stored, err := calls.ReadCalls(calls.RepositoryFile(repoDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != 100 {
+		t.Errorf("calls got %d, want 100", len(stored))
+	}
+	backup, err := calls.ReadCalls(backupFile)
+	if err != nil || !reflect.DeepEqual(backup, stored) {
+		t.Errorf("backup file differs from the repository, err %v", err)
+	}
+	result, err := validation.ValidateRepository(repoDir)
+	if err != nil || len(result.Violations) != 0 {
+		t.Errorf("validate got %v, %v, want no violations", result.Violations, err)
+	}
+
+	exitCode, _, _ = runGenerateFixture(nil, "generate-fixture", []string{"-repo", repoDir})
+	if exitCode != 2 {
+		t.Errorf("existing repository got exit code %d, want 2", exitCode)
+	}
+}
+
+func TestGenerateCallsIsReproducible(t *testing.T) {
+	var conf = fixtureConfig{contacts: 3, callsPerYear: 20, from: 2019, to: 2019, unknownShare: 0.5, seed: 3}
+	var a, b = generateCalls(&conf, time.UTC), generateCalls(&conf, time.UTC)
+	if !reflect.DeepEqual(a, b) {
+		t.Errorf("the same seed generated different calls")
+	}
+	for _, c := range a {
+		if c.Time().Year() != 2019 {
+			t.Errorf("call at %v is outside 2019", c.Time())
+		}
+		var answered = c.Type == calls.Incoming || c.Type == calls.Outgoing || c.Type == calls.Voicemail
+		if answered != (c.Duration != "0") {
+			t.Errorf("call %+v has an unlikely duration", c)
+		}
+	}
+}
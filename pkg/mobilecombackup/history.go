@@ -0,0 +1,373 @@
+package mobilecombackup
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/phillipgreen/mobilecombackup/pkg/contacts"
+)
+
+// HistoryDir is the directory of a repository which keeps the previous
+// versions of its historyFiles.
+const HistoryDir = "history"
+
+// historyFiles are the files of a repository whose previous versions are kept
+// before they are replaced.
+var historyFiles = []string{contacts.FileName}
+
+// defaultHistoryKeep is how many versions of each file are kept unless the
+// repository configuration sets history_keep.
+const defaultHistoryKeep = 20
+
+// historyStamp names versions so that they sort in the order they were saved.
+const historyStamp = "20060102T150405.000000000Z"
+
+// historyKeep returns how many versions of each file the repository at
+// repoPath keeps.
+func historyKeep(repoPath string) (int, error) {
+	repo, err := repositorySettings(repoPath)
+	if err != nil {
+		return 0, err
+	}
+	if repo == nil || repo.HistoryKeep == nil {
+		return defaultHistoryKeep, nil
+	}
+	if *repo.HistoryKeep < 0 {
+		return 0, fmt.Errorf("%s: history_keep must not be negative, got %d", RepositoryConfigFile, *repo.HistoryKeep)
+	}
+	return *repo.HistoryKeep, nil
+}
+
+// historyVersions returns the names of the saved versions of file in the
+// repository at repoPath, oldest first.
+func historyVersions(repoPath, file string) ([]string, error) {
+	var ext = filepath.Ext(file)
+	var prefix = strings.TrimSuffix(file, ext) + "-"
+	entries, err := os.ReadDir(filepath.Join(repoPath, HistoryDir))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var versions []string
+	for _, e := range entries {
+		var name = e.Name()
+		if !e.IsDir() && strings.HasPrefix(name, prefix) && strings.HasSuffix(name, ext) {
+			if _, err := time.Parse(historyStamp, strings.TrimSuffix(strings.TrimPrefix(name, prefix), ext)); err == nil {
+				versions = append(versions, name)
+			}
+		}
+	}
+	sort.Strings(versions)
+	return versions, nil
+}
+
+// saveHistory copies file of the repository at repoPath, if it exists, into
+// the HistoryDir as a version saved at now, then removes the oldest versions
+// beyond those the repository keeps.
+func saveHistory(repoPath, file string, now time.Time) error {
+	keep, err := historyKeep(repoPath)
+	if err != nil {
+		return err
+	}
+	content, err := os.ReadFile(filepath.Join(repoPath, file))
+	if errors.Is(err, os.ErrNotExist) || keep == 0 {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var dir = filepath.Join(repoPath, HistoryDir)
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+	var ext = filepath.Ext(file)
+	var version = strings.TrimSuffix(file, ext) + "-" + now.UTC().Format(historyStamp) + ext
+	err = os.WriteFile(filepath.Join(dir, version), content, 0644)
+	if err != nil {
+		return err
+	}
+
+	versions, err := historyVersions(repoPath, file)
+	for i := 0; err == nil && i < len(versions)-keep; i++ {
+		err = os.Remove(filepath.Join(dir, versions[i]))
+	}
+	return err
+}
+
+// maxDiffCells bounds the table diffLines matches the changed lines with;
+// larger changes are shown as replacing every changed line.
+const maxDiffCells = 1 << 22
+
+// diffLines writes the lines of a and b as a diff, prefixing the lines only in
+// a with -, those only in b with + and those in both with a space.
+func diffLines(w io.Writer, a, b []string) error {
+	var err error
+	var line = func(prefix, s string) {
+		if err == nil {
+			_, err = fmt.Fprintf(w, "%s%s\n", prefix, s)
+		}
+	}
+
+	// the lines before and after the changes need no matching
+	var prefix int
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	var suffix int
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	for _, s := range a[:prefix] {
+		line(" ", s)
+	}
+	var changedA, changedB = a[prefix : len(a)-suffix], b[prefix : len(b)-suffix]
+	if (len(changedA)+1)*(len(changedB)+1) > maxDiffCells {
+		for _, s := range changedA {
+			line("-", s)
+		}
+		for _, s := range changedB {
+			line("+", s)
+		}
+	} else {
+		diffChanged(changedA, changedB, line)
+	}
+	for _, s := range a[len(a)-suffix:] {
+		line(" ", s)
+	}
+	return err
+}
+
+// diffChanged passes the lines of a and b to line as diffLines writes them,
+// matching them by their longest common subsequence.
+func diffChanged(a, b []string, line func(prefix, s string)) {
+	// lengths of the longest common subsequences of the suffixes
+	var lcs = make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var i, j int
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			line(" ", a[i])
+			i, j = i+1, j+1
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			line("-", a[i])
+			i++
+		default:
+			line("+", b[j])
+			j++
+		}
+	}
+}
+
+// readLines returns the lines of the file at filePath, none when it does not
+// exist.
+func readLines(filePath string) ([]string, error) {
+	content, err := os.ReadFile(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.TrimSuffix(string(content), "\n"), "\n"), nil
+}
+
+// replaceFile writes content to a temporary file next to filePath and renames
+// it into place.
+func replaceFile(filePath string, content []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(filePath), "."+filepath.Base(filePath)+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(content)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filePath)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+type historyConfig struct {
+	repoPath string
+	file     string
+	version  string
+	settings *settings
+}
+
+func parseHistoryFlags(s *settings, progname string, args []string) (conf *historyConfig, output string, err error) {
+	flags := flag.NewFlagSet(progname, flag.ContinueOnError)
+	var buf bytes.Buffer
+	flags.SetOutput(&buf)
+
+	var c = historyConfig{settings: s}
+	flags.StringVar(&c.repoPath, "repo", ".", "path which contains repository")
+	flags.StringVar(&c.file, "file", contacts.FileName, "file whose history is used: "+strings.Join(historyFiles, "|"))
+	flags.StringVar(&c.version, "version", "", "saved version, as listed by history list (the latest when empty)")
+
+	err = parseArgs(s, flags, args)
+	if err != nil {
+		return nil, buf.String(), err
+	}
+	return &c, buf.String(), nil
+}
+
+func validateHistoryConfig(conf *historyConfig) error {
+	for _, f := range historyFiles {
+		if conf.file == f {
+			return nil
+		}
+	}
+	return fmt.Errorf("No history is kept of %q, expected one of %s", conf.file, strings.Join(historyFiles, ", "))
+}
+
+// historyVersion returns the path of the version of conf.file named by
+// conf.version, or the latest one.
+func historyVersion(conf *historyConfig) (string, error) {
+	versions, err := historyVersions(conf.repoPath, conf.file)
+	if err != nil {
+		return "", err
+	}
+	if len(versions) == 0 {
+		return "", fmt.Errorf("No versions of %s have been saved", conf.file)
+	}
+	if conf.version == "" {
+		return filepath.Join(conf.repoPath, HistoryDir, versions[len(versions)-1]), nil
+	}
+	for _, v := range versions {
+		if v == conf.version {
+			return filepath.Join(conf.repoPath, HistoryDir, v), nil
+		}
+	}
+	return "", fmt.Errorf("Unknown version %q of %s", conf.version, conf.file)
+}
+
+// runHistoryCommand parses and validates the flags of a history subcommand
+// before running it.
+func runHistoryCommand(s *settings, progname string, args []string, run func(conf *historyConfig) (int, error)) (exitCode int, output *string, err error) {
+	conf, o, err := parseHistoryFlags(s, progname, args)
+	if err == flag.ErrHelp {
+		return 4, nil, err
+	} else if err != nil {
+		return 3, &o, err
+	}
+
+	err = validateHistoryConfig(conf)
+	if err != nil {
+		return 2, nil, err
+	}
+	exitCode, err = run(conf)
+	return exitCode, nil, err
+}
+
+func runHistoryList(s *settings, progname string, args []string) (exitCode int, output *string, err error) {
+	return runHistoryCommand(s, progname, args, func(conf *historyConfig) (int, error) {
+		versions, err := historyVersions(conf.repoPath, conf.file)
+		if err != nil {
+			return 1, err
+		}
+		for _, v := range versions {
+			fmt.Println(v)
+		}
+		return 0, nil
+	})
+}
+
+func runHistoryDiff(s *settings, progname string, args []string) (exitCode int, output *string, err error) {
+	return runHistoryCommand(s, progname, args, func(conf *historyConfig) (int, error) {
+		version, err := historyVersion(conf)
+		if err != nil {
+			return 1, err
+		}
+		saved, err := readLines(version)
+		if err != nil {
+			return 1, err
+		}
+		current, err := readLines(filepath.Join(conf.repoPath, conf.file))
+		if err != nil {
+			return 1, err
+		}
+		fmt.Printf("--- %s\n+++ %s\n", filepath.Base(version), conf.file)
+		err = diffLines(os.Stdout, saved, current)
+		if err != nil {
+			return 1, err
+		}
+		return 0, nil
+	})
+}
+
+func runHistoryRestore(s *settings, progname string, args []string) (exitCode int, output *string, err error) {
+	return runHistoryCommand(s, progname, args, func(conf *historyConfig) (int, error) {
+		err := checkWritable(conf.settings, conf.repoPath)
+		if err != nil {
+			return 2, err
+		}
+		version, err := historyVersion(conf)
+		if err != nil {
+			return 1, err
+		}
+		content, err := os.ReadFile(version)
+		if err != nil {
+			return 1, err
+		}
+		// the version replaced can be restored in turn
+		err = saveHistory(conf.repoPath, conf.file, time.Now())
+		if err == nil {
+			err = replaceFile(filepath.Join(conf.repoPath, conf.file), content)
+		}
+		if err != nil {
+			return 1, err
+		}
+		fmt.Printf("Restored %s from %s\n", conf.file, filepath.Base(version))
+		return 0, nil
+	})
+}
+
+func historySubcommands() []command {
+	return []command{
+		{"list", "list the saved versions of a file, oldest first", runHistoryList},
+		{"diff", "show the changes to a file since a saved version", runHistoryDiff},
+		{"restore", "replace a file with a saved version", runHistoryRestore},
+	}
+}
+
+func runHistory(s *settings, progname string, args []string) (exitCode int, output *string, err error) {
+	var names []string
+	for _, c := range historySubcommands() {
+		if len(args) > 0 && args[0] == c.name {
+			return c.run(s, progname+" "+c.name, args[1:])
+		}
+		names = append(names, c.name)
+	}
+	return 2, nil, fmt.Errorf("Usage of %s: expected one of %s", progname, strings.Join(names, ", "))
+}
@@ -0,0 +1,102 @@
+package mobilecombackup
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/phillipgreen/mobilecombackup/pkg/contacts"
+)
+
+func TestSaveHistory(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, RepositoryConfigFile), []byte("history_keep: 2\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var start = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	err = saveHistory(dir, contacts.FileName, start)
+	if err != nil {
+		t.Fatalf("missing file got %v, want nil", err)
+	}
+
+	for i, content := range []string{"one\n", "two\n", "three\n"} {
+		err = os.WriteFile(contacts.File(dir), []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = saveHistory(dir, contacts.FileName, start.Add(time.Duration(i)*time.Second))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	versions, err := historyVersions(dir, contacts.FileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want = []string{"contacts-20240501T120001.000000000Z.yaml", "contacts-20240501T120002.000000000Z.yaml"}
+	if strings.Join(versions, ",") != strings.Join(want, ",") {
+		t.Errorf("versions got %v, want %v", versions, want)
+	}
+
+	_, _, err = runHistoryRestore(nil, "history restore", []string{"-repo", dir, "-version", want[0]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(contacts.File(dir))
+	if err != nil || string(content) != "two\n" {
+		t.Errorf("restored got %q, %v, want two", content, err)
+	}
+	versions, err = historyVersions(dir, contacts.FileName)
+	if err != nil || len(versions) != 2 || versions[0] != want[1] {
+		t.Errorf("versions after restore got %v, %v, want the replaced version kept", versions, err)
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	var b bytes.Buffer
+	err := diffLines(&b, []string{"contacts:", "- name: A", "- name: B"}, []string{"contacts:", "- name: B", "- name: C"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want = " contacts:\n-- name: A\n - name: B\n+- name: C\n"
+	if b.String() != want {
+		t.Errorf("diff got %q, want %q", b.String(), want)
+	}
+}
+
+func TestDiffLinesLargeChange(t *testing.T) {
+	// too many changed lines to match, between an unchanged first and last line
+	var a, b = []string{"contacts:"}, []string{"contacts:"}
+	for i := 0; i < 3000; i++ {
+		a = append(a, fmt.Sprintf("- name: A%d", i))
+		b = append(b, fmt.Sprintf("- name: B%d", i))
+	}
+	a, b = append(a, "end"), append(b, "end")
+
+	var out bytes.Buffer
+	err := diffLines(&out, a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines = strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 6002 || lines[0] != " contacts:" || lines[1] != "-- name: A0" ||
+		lines[3001] != "+- name: B0" || lines[6001] != " end" {
+		t.Errorf("diff got %d lines starting %q, want the changed lines replaced", len(lines), lines[:3])
+	}
+}
+
+func TestValidateHistoryConfig(t *testing.T) {
+	conf, _, err := parseHistoryFlags(nil, "history list", []string{"-file", "calls.xml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validateHistoryConfig(conf); err == nil {
+		t.Errorf("calls.xml got nil err")
+	}
+}
@@ -0,0 +1,39 @@
+package mobilecombackup
+
+import (
+	"os"
+	"strconv"
+	"syscall"
+)
+
+const (
+	ioprioWhoProcess = 1
+	ioprioClassIdle  = 3
+	ioprioClassShift = 13
+	lowestNice       = 19
+)
+
+// idlePriority gives the process the idle I/O scheduling class and the lowest
+// CPU priority. Linux keeps both per thread, so every thread of the process
+// is changed; threads started later inherit them.
+func idlePriority() error {
+	tasks, err := os.ReadDir("/proc/self/task")
+	if err != nil {
+		return err
+	}
+	for _, t := range tasks {
+		tid, err := strconv.Atoi(t.Name())
+		if err != nil {
+			continue
+		}
+		_, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(tid), ioprioClassIdle<<ioprioClassShift)
+		if errno != 0 {
+			return errno
+		}
+		err = syscall.Setpriority(syscall.PRIO_PROCESS, tid, lowestNice)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
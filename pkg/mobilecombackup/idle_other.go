@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package mobilecombackup
+
+import "errors"
+
+// idlePriority is only supported on Linux.
+func idlePriority() error {
+	return errors.New("idle priority is only supported on Linux")
+}
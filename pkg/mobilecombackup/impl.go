@@ -1,6 +1,8 @@
 package mobilecombackup
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -8,33 +10,80 @@ import (
 
 	"github.com/phillipgreen/mobilecombackup/pkg/calls"
 	"github.com/phillipgreen/mobilecombackup/pkg/coalescer"
+	"github.com/phillipgreen/mobilecombackup/pkg/throttle"
 )
 
 type processorState struct {
 	outputDir     string
 	callCoalescer coalescer.Coalescer
+	options       Options
 }
 
-func coalesce(c coalescer.Coalescer, fileRoot string) (coalescer.Result, error) {
-	var res coalescer.Result = coalescer.Result{Total: 0, New: 0}
+func coalesce(ctx context.Context, c coalescer.Coalescer, fileRoot string, opts Options) (coalescer.Result, error) {
+	var res coalescer.Result = coalescer.Result{Total: 0, New: 0, NewByYear: map[int]int{}, NewContacts: map[string]string{}}
+
+	// cancelling stops the walk and the parsing of paths however the run
+	// ends, so none of their goroutines are left behind
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
 	// find all files to process
-	paths := searchPath(c, fileRoot)
-	results := coalescePaths(c, paths)
+	paths := searchPath(runCtx, c, fileRoot)
+	var tracker *progressTracker
+	if opts.Progress != nil {
+		paths, tracker = prescan(runCtx, c, paths, opts.Progress)
+	}
+	var abort error
+	results := coalescePaths(runCtx, cancel, c, paths, opts.Workers, tracker, &abort)
 
+	var coalesced int
 	for r := range results {
 		res.Total = r.Total
 		res.New += r.New
+		res.Rejected += r.Rejected
+		res.Duplicates += r.Duplicates
+		res.Filtered += r.Filtered
+		res.Salvaged += r.Salvaged
+		for year, n := range r.NewByYear {
+			res.NewByYear[year] += n
+		}
+		for number, name := range r.NewContacts {
+			res.NewContacts[number] = name
+		}
+		coalesced++
+	}
+
+	if abort != nil {
+		return res, fmt.Errorf("aborted after coalescing %d files, repository left unchanged: %w", coalesced, abort)
+	}
+
+	// nothing is written until the flush, so stopping here leaves the
+	// repository as it was
+	if err := ctx.Err(); err != nil {
+		return res, fmt.Errorf("cancelled after coalescing %d files, repository left unchanged: %w", coalesced, err)
+	}
+
+	if opts.DryRun {
+		return res, nil
+	}
+
+	var err = c.Flush()
+	if err != nil {
+		log.Printf("Error on Flush: %v", err)
+		return res, err
 	}
 
 	return res, nil
 }
 
-func searchPath(c coalescer.Coalescer, root string) <-chan string {
+func searchPath(ctx context.Context, c coalescer.Coalescer, root string) <-chan string {
 	paths := make(chan string, 10)
 
 	go func() {
 		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
 
 			if info.IsDir() {
 				// skip directories
@@ -47,12 +96,16 @@ func searchPath(c coalescer.Coalescer, root string) <-chan string {
 			}
 
 			if supports {
-				paths <- path
+				select {
+				case paths <- path:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
 			}
 
 			return nil
 		})
-		if err != nil {
+		if err != nil && err != ctx.Err() {
 			fmt.Fprintln(os.Stderr, "while walking", root, "got error:", err)
 		}
 		close(paths)
@@ -61,26 +114,74 @@ func searchPath(c coalescer.Coalescer, root string) <-chan string {
 	return paths
 }
 
-func coalescePaths(c coalescer.Coalescer, paths <-chan string) <-chan coalescer.Result {
+type preparedPath struct {
+	path  string
+	merge func() (coalescer.Result, error)
+	err   error
+}
+
+// preparePaths parses up to workers paths at a time. The parsed paths are
+// queued in the order of paths, and at most workers of them wait to be merged.
+func preparePaths(ctx context.Context, c coalescer.Coalescer, paths <-chan string, workers int) <-chan chan preparedPath {
+	queue := make(chan chan preparedPath, workers)
+
+	go func() {
+		defer close(queue)
+		running := make(chan struct{}, workers)
+		for p := range paths {
+			prepared := make(chan preparedPath, 1)
+			select {
+			case queue <- prepared:
+			case <-ctx.Done():
+				return
+			}
+
+			running <- struct{}{}
+			go func(p string) {
+				defer func() { <-running }()
+				merge, err := c.Prepare(p)
+				prepared <- preparedPath{p, merge, err}
+			}(p)
+		}
+	}()
+	return queue
+}
+
+// coalescePaths coalesces each path until paths or ctx is done, merging them
+// in the order of paths. When a coalescer asks for the run to stop, the error
+// is stored in abort and cancel is called before the results are closed.
+func coalescePaths(ctx context.Context, cancel context.CancelFunc, c coalescer.Coalescer, paths <-chan string, workers int, tracker *progressTracker, abort *error) <-chan coalescer.Result {
 	results := make(chan coalescer.Result, 10)
+	if workers < 1 {
+		workers = 1
+	}
+
+	queue := preparePaths(ctx, c, paths, workers)
 
 	go func() {
-		for {
-			p, ok := <-paths
-			if !ok {
+		for prepared := range queue {
+			if ctx.Err() != nil {
 				break
 			}
-			var r, err = c.Coalesce(p)
-			if err != nil {
+			pp := <-prepared
+			var p, err = pp.path, pp.err
+			var r coalescer.Result
+			if err == nil {
+				r, err = pp.merge()
+			}
+			var abortErr *coalescer.AbortError
+			if errors.As(err, &abortErr) {
+				log.Printf("Aborting on [%s]: %v", p, err)
+				*abort = err
+				cancel()
+				break
+			} else if err != nil {
 				log.Printf("Error on Coalescing [%s]: %v", p, err)
 			} else {
 				log.Printf("Coalesced [%s]: %v", p, r)
 				results <- r
 			}
-		}
-		var err = c.Flush()
-		if err != nil {
-			log.Printf("Error on Flush: %v", err)
+			tracker.done(p)
 		}
 		close(results)
 	}()
@@ -88,9 +189,13 @@ func coalescePaths(c coalescer.Coalescer, paths <-chan string) <-chan coalescer.
 }
 
 func (s *processorState) Process(fileRoot string) (Result, error) {
+	return s.ProcessContext(context.Background(), fileRoot)
+}
+
+func (s *processorState) ProcessContext(ctx context.Context, fileRoot string) (Result, error) {
 	var result Result
 
-	var cResult, err = coalesce(s.callCoalescer, fileRoot)
+	var cResult, err = coalesce(ctx, s.callCoalescer, fileRoot, s.options)
 	if err != nil {
 		return result, err
 	}
@@ -99,8 +204,26 @@ func (s *processorState) Process(fileRoot string) (Result, error) {
 }
 
 func Init(rootPath string) (Processor, error) {
-	return &processorState{
-		rootPath,
-		calls.Init(rootPath),
-	}, nil
+	return InitWithOptions(rootPath, Options{})
+}
+
+func InitWithOptions(rootPath string, options Options) (Processor, error) {
+	if _, err := os.Stat(calls.RepositoryFile(rootPath)); err != nil {
+		return nil, fmt.Errorf("%s is not a repository, run init to create one: %w", rootPath, err)
+	}
+	callCoalescer, err := calls.InitWithOptions(rootPath, calls.Options{
+		DefaultRegion: options.DefaultRegion,
+		OnParseError:  options.OnParseError,
+		Dedup:         options.Dedup,
+		Filter:        options.Filter,
+		Recover:       options.Recover,
+		Transform:     options.TransformCall,
+		OnCommit:      options.OnCommit,
+		Index:         options.Index,
+		Throttle:      throttle.New(options.IOThrottle),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &processorState{rootPath, callCoalescer, options}, nil
 }
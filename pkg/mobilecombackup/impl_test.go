@@ -1,12 +1,19 @@
 package mobilecombackup
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"github.com/phillipgreen/mobilecombackup/internal/test_support"
 	"github.com/phillipgreen/mobilecombackup/pkg/coalescer"
+	"os"
 	"path"
 	"path/filepath"
+	"reflect"
+	"runtime"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestProcess(t *testing.T) {
@@ -22,8 +29,8 @@ func TestProcess(t *testing.T) {
 	mockCC := mockCallCoalescer{total: 10}
 
 	processor := processorState{
-		repoDir,
-		&mockCC,
+		outputDir:     repoDir,
+		callCoalescer: &mockCC,
 	}
 
 	result, err := processor.Process(pathToProcess)
@@ -45,10 +52,130 @@ func TestProcess(t *testing.T) {
 	}
 }
 
+func TestProcessReportsProgress(t *testing.T) {
+	tmpdir := t.TempDir()
+	err := test_support.CopyDir("../../testdata", tmpdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var reports []Progress
+	processor := processorState{
+		outputDir:     filepath.Join(tmpdir, "archive"),
+		callCoalescer: &mockCallCoalescer{},
+		options: Options{Progress: func(p Progress) {
+			reports = append(reports, p)
+		}},
+	}
+
+	_, err = processor.Process(filepath.Join(tmpdir, "to_process"))
+	if err != nil {
+		t.Errorf("err got %v, want nil", err)
+	}
+
+	// one report after the pre-scan and one per file
+	if len(reports) != 3 {
+		t.Fatalf("reports got %d, want 3", len(reports))
+	}
+	last := reports[len(reports)-1]
+	if last.FilesTotal != 2 || last.FilesDone != 2 {
+		t.Errorf("files got %d/%d, want 2/2", last.FilesDone, last.FilesTotal)
+	}
+	if last.RecordsTotal != 28 || last.RecordsDone != 28 {
+		t.Errorf("records got %d/%d, want 28/28", last.RecordsDone, last.RecordsTotal)
+	}
+}
+
+func TestProcessContextCancelled(t *testing.T) {
+	tmpdir := t.TempDir()
+	err := test_support.CopyDir("../../testdata", tmpdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mockCC := mockCallCoalescer{}
+	processor := processorState{
+		outputDir:     filepath.Join(tmpdir, "archive"),
+		callCoalescer: &mockCC,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = processor.ProcessContext(ctx, filepath.Join(tmpdir, "to_process"))
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err got %v, want %v", err, context.Canceled)
+	}
+	if mockCC.flushes != 0 {
+		t.Errorf("flushes got %d, want 0", mockCC.flushes)
+	}
+}
+
+func TestProcessWithWorkersMergesInOrder(t *testing.T) {
+	tmpdir := t.TempDir()
+	err := test_support.CopyDir("../../testdata", tmpdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pathToProcess := filepath.Join(tmpdir, "to_process")
+
+	mockCC := mockCallCoalescer{total: 10}
+	processor := processorState{
+		outputDir:     filepath.Join(tmpdir, "archive"),
+		callCoalescer: &mockCC,
+		options:       Options{Workers: 4},
+	}
+
+	result, err := processor.Process(pathToProcess)
+	if err != nil {
+		t.Errorf("err got %v, want nil", err)
+	}
+	if result.Calls.Total != 38 || result.Calls.New != 28 {
+		t.Errorf("total/new got %d/%d, want 38/28", result.Calls.Total, result.Calls.New)
+	}
+	want := []string{
+		filepath.Join(pathToProcess, "00", "calls-test.xml"),
+		filepath.Join(pathToProcess, "01", "calls-test.xml"),
+	}
+	if !reflect.DeepEqual(mockCC.pathsCoalesced, want) {
+		t.Errorf("pathsCoalesced got %v, want %v", mockCC.pathsCoalesced, want)
+	}
+}
+
+func TestProcessAbortStopsWalk(t *testing.T) {
+	dir := t.TempDir()
+	// more paths than are queued, so the walk is still running on abort
+	for i := 0; i < 50; i++ {
+		err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("calls-%02d.xml", i)), nil, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	var before = runtime.NumGoroutine()
+
+	mockCC := mockCallCoalescer{abortOn: "calls-00.xml"}
+	processor := processorState{outputDir: dir, callCoalescer: &mockCC}
+	_, err := processor.Process(dir)
+	var abortErr *coalescer.AbortError
+	if !errors.As(err, &abortErr) {
+		t.Fatalf("err got %v, want an abort", err)
+	}
+
+	var after int
+	for i := 0; i < 100; i++ {
+		if after = runtime.NumGoroutine(); after <= before {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("goroutines got %d after the abort, want %d", after, before)
+}
+
 type mockCallCoalescer struct {
 	pathsCoalesced []string
 	total          int
 	flushes        int
+	// abortOn is the base name of the path whose coalescing aborts the run
+	abortOn string
 }
 
 func (mcc *mockCallCoalescer) Supports(filePath string) (bool, error) {
@@ -57,6 +184,9 @@ func (mcc *mockCallCoalescer) Supports(filePath string) (bool, error) {
 }
 
 func (mcc *mockCallCoalescer) Coalesce(filePath string) (coalescer.Result, error) {
+	if filepath.Base(filePath) == mcc.abortOn {
+		return coalescer.Result{}, &coalescer.AbortError{Err: errors.New("damaged")}
+	}
 	entriesAdded := len(filepath.Base(filePath))
 	mcc.pathsCoalesced = append(mcc.pathsCoalesced, filePath)
 	mcc.total += entriesAdded
@@ -68,8 +198,52 @@ func (mcc *mockCallCoalescer) Coalesce(filePath string) (coalescer.Result, error
 	return result, nil
 }
 
+func (mcc *mockCallCoalescer) Prepare(filePath string) (func() (coalescer.Result, error), error) {
+	return func() (coalescer.Result, error) {
+		return mcc.Coalesce(filePath)
+	}, nil
+}
+
+func (mcc *mockCallCoalescer) Count(filePath string) (int, error) {
+	return len(filepath.Base(filePath)), nil
+}
+
 func (mcc *mockCallCoalescer) Flush() error {
 	mcc.flushes += 1
 
 	return nil
 }
+
+func TestProcessDryRun(t *testing.T) {
+	tmpdir := t.TempDir()
+	err := test_support.CopyDir("../../testdata", tmpdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repoDir := filepath.Join(tmpdir, "archive")
+	before, err := os.ReadFile(filepath.Join(repoDir, "calls.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	processor, err := InitWithOptions(repoDir, Options{DefaultRegion: "US", DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := processor.Process(filepath.Join(tmpdir, "to_process"))
+	if err != nil {
+		t.Fatalf("err got %v, want nil", err)
+	}
+
+	var want = coalescer.Result{Total: 22, New: 6, Duplicates: 18, NewByYear: map[int]int{2014: 5, 2015: 1}, NewContacts: map[string]string{}}
+	if !reflect.DeepEqual(result.Calls, want) {
+		t.Errorf("result got %+v, want %+v", result.Calls, want)
+	}
+	after, err := os.ReadFile(filepath.Join(repoDir, "calls.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(after) != string(before) {
+		t.Errorf("dry run changed the repository")
+	}
+}
@@ -0,0 +1,73 @@
+package mobilecombackup
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/phillipgreen/mobilecombackup/pkg/calls"
+)
+
+type initConfig struct {
+	repoPath string
+}
+
+func parseInitFlags(s *settings, progname string, args []string) (conf *initConfig, output string, err error) {
+	flags := flag.NewFlagSet(progname, flag.ContinueOnError)
+	var buf bytes.Buffer
+	flags.SetOutput(&buf)
+
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage of %s [path]:\n", progname)
+
+		flags.PrintDefaults()
+	}
+
+	err = parseArgs(s, flags, args)
+	if err != nil {
+		return nil, buf.String(), err
+	}
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return nil, buf.String(), fmt.Errorf("At most one path may be specified, got %d", flags.NArg())
+	}
+
+	var c = initConfig{repoPath: "."}
+	if flags.NArg() == 1 {
+		c.repoPath = flags.Arg(0)
+	}
+	return &c, buf.String(), nil
+}
+
+// initRepository creates an empty repository at repoPath, refusing to touch
+// an existing one.
+func initRepository(repoPath string) error {
+	var callsFile = calls.RepositoryFile(repoPath)
+	if _, err := os.Stat(callsFile); err == nil {
+		return fmt.Errorf("%s is already a repository", repoPath)
+	}
+
+	err := os.MkdirAll(repoPath, 0755)
+	if err != nil {
+		return err
+	}
+	return calls.WriteCalls(callsFile, nil)
+}
+
+func runInit(s *settings, progname string, args []string) (exitCode int, output *string, err error) {
+	conf, o, err := parseInitFlags(s, progname, args)
+	if err == flag.ErrHelp {
+		return 4, nil, err
+	} else if err != nil {
+		return 3, &o, err
+	}
+
+	err = initRepository(conf.repoPath)
+	if err != nil {
+		return 1, nil, err
+	}
+
+	fmt.Printf("Initialized repository in %s\n", conf.repoPath)
+	return 0, nil, nil
+}
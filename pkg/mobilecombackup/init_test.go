@@ -0,0 +1,46 @@
+package mobilecombackup
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/phillipgreen/mobilecombackup/pkg/validation"
+)
+
+func TestInitRepository(t *testing.T) {
+	repoDir := filepath.Join(t.TempDir(), "new", "repo")
+
+	err := initRepository(repoDir)
+	if err != nil {
+		t.Fatalf("err got %v, want nil", err)
+	}
+
+	result, err := validation.ValidateRepository(repoDir)
+	if err != nil {
+		t.Fatalf("err got %v, want nil", err)
+	}
+	if len(result.Violations) != 0 {
+		t.Errorf("violations got %v, want none", result.Violations)
+	}
+
+	processor, err := Init(repoDir)
+	if err != nil {
+		t.Fatalf("err got %v, want nil", err)
+	}
+	if processor == nil {
+		t.Errorf("processor got nil")
+	}
+
+	err = initRepository(repoDir)
+	if err == nil || !strings.Contains(err.Error(), "already a repository") {
+		t.Errorf("err got %v, want already a repository", err)
+	}
+}
+
+func TestInitWithoutRepository(t *testing.T) {
+	_, err := Init(t.TempDir())
+	if err == nil || !strings.Contains(err.Error(), "run init") {
+		t.Errorf("err got %v, want hint to run init", err)
+	}
+}
@@ -0,0 +1,163 @@
+package mobilecombackup
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/phillipgreen/mobilecombackup/pkg/calls"
+)
+
+type lookupConfig struct {
+	repoPath string
+	format   string
+	hashes   []string
+}
+
+func parseLookupFlags(s *settings, progname string, args []string) (conf *lookupConfig, output string, err error) {
+	flags := flag.NewFlagSet(progname, flag.ContinueOnError)
+	var buf bytes.Buffer
+	flags.SetOutput(&buf)
+
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage of %s [options] hash1 [... hashN]:\n", progname)
+
+		flags.PrintDefaults()
+	}
+
+	var c lookupConfig
+	flags.StringVar(&c.repoPath, "repo", ".", "path which contains repository")
+	flags.StringVar(&c.format, "format", "table", "output format: "+strings.Join(renderFormats, "|"))
+
+	err = parseArgs(s, flags, args)
+	if err != nil {
+		return nil, buf.String(), err
+	}
+	for _, h := range flags.Args() {
+		c.hashes = append(c.hashes, strings.ToLower(h))
+	}
+	return &c, buf.String(), nil
+}
+
+func validateLookupConfig(conf *lookupConfig) error {
+	if len(conf.hashes) <= 0 {
+		return errors.New("Atleast one record hash must be specified")
+	}
+	return checkFormat(conf.format, renderFormats)
+}
+
+// lookupIndexed finds the calls with the given hashes through the index of the
+// repository at repoPath.
+func lookupIndexed(repoPath string, hashes []string) (map[string]calls.Call, error) {
+	var callsFile = calls.RepositoryFile(repoPath)
+	idx, err := calls.OpenIndex(calls.IndexFile(repoPath), callsFile)
+	if err != nil {
+		return nil, err
+	}
+	defer idx.Close()
+
+	var found = map[string]calls.Call{}
+	for _, h := range hashes {
+		offset, ok, err := idx.Offset(h)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		// the checksum the index relies on may itself be out of date, such as
+		// after the calls file was edited by hand
+		call, err := calls.ReadCallAt(callsFile, offset)
+		if err != nil {
+			return nil, fmt.Errorf("%w: reading offset %d of %s: %v", calls.ErrStaleIndex, offset, callsFile, err)
+		}
+		if call.Hash() != h {
+			return nil, fmt.Errorf("%w: call at offset %d of %s is not %s", calls.ErrStaleIndex, offset, callsFile, h)
+		}
+		found[h] = call
+	}
+	return found, nil
+}
+
+// lookupCalls finds the calls with the given hashes, scanning the calls file
+// when the repository has no current index.
+func lookupCalls(repoPath string, hashes []string) (map[string]calls.Call, error) {
+	found, err := lookupIndexed(repoPath, hashes)
+	if err == nil {
+		return found, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) && !errors.Is(err, calls.ErrStaleIndex) {
+		return nil, err
+	}
+	log.Printf("Scanning calls: %v", err)
+
+	var wanted = map[string]bool{}
+	for _, h := range hashes {
+		wanted[h] = true
+	}
+	found = map[string]calls.Call{}
+	err = calls.StreamCalls(calls.RepositoryFile(repoPath), func(c calls.Call) error {
+		if h := c.Hash(); wanted[h] {
+			found[h] = c
+		}
+		return nil
+	})
+	return found, err
+}
+
+// lookupRecord is a call found by lookup, as written by the json and yaml
+// formats.
+type lookupRecord struct {
+	Record string `json:"record"`
+	calls.Call
+}
+
+func writeLookup(w io.Writer, format string, hashes []string, found map[string]calls.Call) error {
+	var records = []lookupRecord{}
+	var rows [][]string
+	for _, h := range hashes {
+		c, ok := found[h]
+		if !ok {
+			continue
+		}
+		records = append(records, lookupRecord{h, c})
+		rows = append(rows, []string{h, c.Time().UTC().Format(time.RFC3339), c.Number, c.Type, c.Duration})
+	}
+	if format == "json" || format == "yaml" {
+		return writeStructured(w, format, records)
+	}
+	return writeRows(w, format, []string{"RECORD", "DATE", "NUMBER", "TYPE", "DURATION"}, rows)
+}
+
+func runLookup(s *settings, progname string, args []string) (exitCode int, output *string, err error) {
+	conf, o, err := parseLookupFlags(s, progname, args)
+	if err == flag.ErrHelp {
+		return 4, nil, err
+	} else if err != nil {
+		return 3, &o, err
+	}
+
+	err = validateLookupConfig(conf)
+	if err != nil {
+		return 2, nil, err
+	}
+
+	found, err := lookupCalls(conf.repoPath, conf.hashes)
+	if err != nil {
+		return 1, nil, err
+	}
+	err = writeLookup(os.Stdout, conf.format, conf.hashes, found)
+	if err != nil {
+		return 1, nil, err
+	}
+	if missing := len(conf.hashes) - len(found); missing > 0 {
+		return 1, nil, notFound("Found no call for %d of the hashes", missing)
+	}
+	return 0, nil, nil
+}
@@ -0,0 +1,51 @@
+package mobilecombackup
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/phillipgreen/mobilecombackup/internal/test_support"
+	"github.com/phillipgreen/mobilecombackup/pkg/calls"
+)
+
+func TestLookupCallsEditedByHand(t *testing.T) {
+	tmpdir := t.TempDir()
+	err := test_support.CopyDir("../../testdata", tmpdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repoDir := filepath.Join(tmpdir, "archive")
+	callsFile := calls.RepositoryFile(repoDir)
+	err = calls.BuildIndex(callsFile, calls.IndexFile(repoDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	all, err := calls.ReadCalls(callsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// swapping the first calls moves them without updating the checksum the
+	// index relies on
+	content, err := os.ReadFile(callsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(string(content), "\n")
+	lines[3], lines[4] = lines[4], lines[3]
+	err = os.WriteFile(callsFile, []byte(strings.Join(lines, "\n")), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var hash = all[1].Hash()
+	found, err := lookupCalls(repoDir, []string{hash})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := found[hash]; !ok || got.Key() != all[1].Key() {
+		t.Errorf("found got %+v, want %+v", found, all[1])
+	}
+}
@@ -0,0 +1,140 @@
+package mobilecombackup
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/phillipgreen/mobilecombackup/pkg/validation"
+)
+
+const metricsPrefix = "mobilecombackup_"
+
+type metricSample struct {
+	// labels are written between the braces as is, e.g. `severity="error"`
+	labels string
+	value  float64
+}
+
+type metric struct {
+	name    string
+	help    string
+	samples []metricSample
+}
+
+func gauge(name, help string, value float64) metric {
+	return metric{name, help, []metricSample{{"", value}}}
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// writeMetrics writes metrics as gauges in the Prometheus text exposition
+// format.
+func writeMetrics(w io.Writer, metrics []metric) error {
+	bw := bufio.NewWriter(w)
+	for _, m := range metrics {
+		var name = metricsPrefix + m.name
+		fmt.Fprintf(bw, "# HELP %s %s\n", name, m.help)
+		fmt.Fprintf(bw, "# TYPE %s gauge\n", name)
+		for _, s := range m.samples {
+			var value = strconv.FormatFloat(s.value, 'g', -1, 64)
+			if s.labels != "" {
+				fmt.Fprintf(bw, "%s{%s} %s\n", name, s.labels, value)
+			} else {
+				fmt.Fprintf(bw, "%s %s\n", name, value)
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// writeMetricsFile replaces filePath with metrics. The file is written next
+// to filePath and renamed into place so a collector never reads a partial
+// file.
+func writeMetricsFile(filePath string, metrics []metric) error {
+	tmp, err := os.CreateTemp(filepath.Dir(filePath), ".metrics-*.tmp")
+	if err != nil {
+		return err
+	}
+	err = writeMetrics(tmp, metrics)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filePath)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// importSummary totals the results of the paths of an import run.
+type importSummary struct {
+	DryRun     bool        `json:"dry_run"`
+	Paths      int         `json:"paths"`
+	Failures   int         `json:"failures"`
+	Calls      int         `json:"calls"`
+	NewCalls   int         `json:"new_calls"`
+	Duplicates int         `json:"duplicates"`
+	Filtered   int         `json:"filtered"`
+	Rejected   int         `json:"rejected"`
+	Salvaged   int         `json:"salvaged"`
+	NewByYear  map[int]int `json:"new_by_year"`
+	// NewContacts maps the numbers named by new calls but not by
+	// contacts.yaml or the calls already in the repository to their name.
+	NewContacts map[string]string `json:"new_contacts"`
+}
+
+func (s importSummary) metrics(success bool, started time.Time) []metric {
+	return []metric{
+		gauge("import_success", "Whether the last import succeeded.", boolValue(success)),
+		gauge("import_last_run_timestamp_seconds", "When the last import started.", float64(started.Unix())),
+		gauge("import_duration_seconds", "How long the last import took.", time.Since(started).Seconds()),
+		gauge("import_paths", "Paths processed by the last import.", float64(s.Paths)),
+		gauge("import_path_failures", "Paths which failed to import in the last import.", float64(s.Failures)),
+		gauge("import_calls_new", "Calls added to the repository by the last import.", float64(s.NewCalls)),
+		gauge("import_calls_rejected", "Calls rejected by the last import.", float64(s.Rejected)),
+		gauge("repository_calls", "Calls in the repository after the last import.", float64(s.Calls)),
+	}
+}
+
+func validationMetrics(result validation.Result, success bool, started time.Time) []metric {
+	var counts = map[string]int{}
+	for _, r := range validation.Rules() {
+		counts[fmt.Sprintf("severity=%q,type=%q", r.Severity, r.Type)] = 0
+	}
+	for _, v := range result.Violations {
+		counts[fmt.Sprintf("severity=%q,type=%q", v.Severity, v.Type)]++
+	}
+	var labels = make([]string, 0, len(counts))
+	for l := range counts {
+		labels = append(labels, l)
+	}
+	sort.Strings(labels)
+
+	var violations = metric{"validate_violations", "Violations found by the last validation.", nil}
+	for _, l := range labels {
+		violations.samples = append(violations.samples, metricSample{l, float64(counts[l])})
+	}
+
+	return []metric{
+		gauge("validate_success", "Whether the last validation ran and found no errors.", boolValue(success)),
+		gauge("validate_last_run_timestamp_seconds", "When the last validation started.", float64(started.Unix())),
+		gauge("validate_duration_seconds", "How long the last validation took.", time.Since(started).Seconds()),
+		violations,
+	}
+}
@@ -0,0 +1,78 @@
+package mobilecombackup
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/phillipgreen/mobilecombackup/internal/test_support"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	var buf bytes.Buffer
+	err := writeMetrics(&buf, []metric{
+		gauge("import_calls_new", "Calls added.", 6),
+		{"validate_violations", "Violations found.", []metricSample{
+			{`severity="error",type="malformed-xml"`, 1},
+			{`severity="warning",type="unsorted-records"`, 0},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var want = `# HELP mobilecombackup_import_calls_new Calls added.
+# TYPE mobilecombackup_import_calls_new gauge
+mobilecombackup_import_calls_new 6
+# HELP mobilecombackup_validate_violations Violations found.
+# TYPE mobilecombackup_validate_violations gauge
+mobilecombackup_validate_violations{severity="error",type="malformed-xml"} 1
+mobilecombackup_validate_violations{severity="warning",type="unsorted-records"} 0
+`
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestMetricsFile(t *testing.T) {
+	tmpdir := t.TempDir()
+	err := test_support.CopyDir("../../testdata", tmpdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repoDir := filepath.Join(tmpdir, "archive")
+	metricsFile := filepath.Join(tmpdir, "mobilecombackup.prom")
+
+	var tests = []struct {
+		desc string
+		args []string
+		want []string
+	}{
+		{"import",
+			[]string{"prog", "-quiet", "-repo", repoDir, "-metrics-file", metricsFile, filepath.Join(tmpdir, "to_process")},
+			[]string{"mobilecombackup_import_success 1", "mobilecombackup_import_calls_new 6", "mobilecombackup_repository_calls 22"}},
+		{"validate",
+			[]string{"prog", "validate", "-repo", repoDir, "-metrics-file", metricsFile},
+			[]string{"mobilecombackup_validate_success 1", `mobilecombackup_validate_violations{severity="error",type="duplicate-record"} 0`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			exitCode, _, err := Run(tt.args)
+			if exitCode != 0 || err != nil {
+				t.Fatalf("got exit code %d, err %v", exitCode, err)
+			}
+			content, err := os.ReadFile(metricsFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(content), want+"\n") {
+					t.Errorf("metrics got:\n%s\nwant to contain %q", content, want)
+				}
+			}
+		})
+	}
+}
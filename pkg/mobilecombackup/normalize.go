@@ -0,0 +1,64 @@
+package mobilecombackup
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+
+	"github.com/phillipgreen/mobilecombackup/pkg/calls"
+)
+
+type normalizeConfig struct {
+	repoPath string
+	settings *settings
+}
+
+func parseNormalizeFlags(s *settings, progname string, args []string) (conf *normalizeConfig, output string, err error) {
+	flags := flag.NewFlagSet(progname, flag.ContinueOnError)
+	var buf bytes.Buffer
+	flags.SetOutput(&buf)
+
+	var c = normalizeConfig{settings: s}
+	flags.StringVar(&c.repoPath, "repo", ".", "path which contains repository")
+
+	err = parseArgs(s, flags, args)
+	if err != nil {
+		return nil, buf.String(), err
+	}
+	return &c, buf.String(), nil
+}
+
+// normalizeRepository rewrites the calls file in canonical order, returning
+// the number of calls written.
+func normalizeRepository(repoPath string) (int, error) {
+	var callsFile = calls.RepositoryFile(repoPath)
+	all, err := calls.ReadCalls(callsFile)
+	if err != nil {
+		return 0, err
+	}
+
+	calls.SortCanonical(all)
+	return len(all), calls.WriteCalls(callsFile, all)
+}
+
+func runNormalize(s *settings, progname string, args []string) (exitCode int, output *string, err error) {
+	conf, o, err := parseNormalizeFlags(s, progname, args)
+	if err == flag.ErrHelp {
+		return 4, nil, err
+	} else if err != nil {
+		return 3, &o, err
+	}
+
+	err = checkWritable(conf.settings, conf.repoPath)
+	if err != nil {
+		return 2, nil, err
+	}
+
+	count, err := normalizeRepository(conf.repoPath)
+	if err != nil {
+		return 1, nil, err
+	}
+
+	fmt.Printf("Normalized %d calls\n", count)
+	return 0, nil, nil
+}
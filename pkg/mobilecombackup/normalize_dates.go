@@ -0,0 +1,83 @@
+package mobilecombackup
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"time"
+	// zone names must resolve on hosts without a zoneinfo database
+	_ "time/tzdata"
+
+	"github.com/phillipgreen/mobilecombackup/pkg/calls"
+)
+
+type normalizeDatesConfig struct {
+	repoPath string
+	tz       string
+	settings *settings
+}
+
+func parseNormalizeDatesFlags(s *settings, progname string, args []string) (conf *normalizeDatesConfig, output string, err error) {
+	flags := flag.NewFlagSet(progname, flag.ContinueOnError)
+	var buf bytes.Buffer
+	flags.SetOutput(&buf)
+
+	var c = normalizeDatesConfig{settings: s}
+	flags.StringVar(&c.repoPath, "repo", ".", "path which contains repository")
+	flags.StringVar(&c.tz, "tz", "Local", "IANA time zone to write readable dates in, such as America/New_York")
+
+	err = parseArgs(s, flags, args)
+	if err != nil {
+		return nil, buf.String(), err
+	}
+	return &c, buf.String(), nil
+}
+
+// normalizeDates regenerates the readable date of every call from its date
+// in loc, returning how many changed and how many calls there are.
+func normalizeDates(repoPath string, loc *time.Location) (changed int, total int, err error) {
+	var callsFile = calls.RepositoryFile(repoPath)
+	all, err := calls.ReadCalls(callsFile)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for i := range all {
+		var readable = all[i].FormatReadableDate(loc)
+		if all[i].ReadableDate != readable {
+			all[i].ReadableDate = readable
+			changed++
+		}
+	}
+	if changed == 0 {
+		return 0, len(all), nil
+	}
+	return changed, len(all), calls.WriteCalls(callsFile, all)
+}
+
+func runNormalizeDates(s *settings, progname string, args []string) (exitCode int, output *string, err error) {
+	conf, o, err := parseNormalizeDatesFlags(s, progname, args)
+	if err == flag.ErrHelp {
+		return 4, nil, err
+	} else if err != nil {
+		return 3, &o, err
+	}
+
+	loc, err := time.LoadLocation(conf.tz)
+	if err != nil {
+		return 2, nil, fmt.Errorf("Unknown time zone %q: %w", conf.tz, err)
+	}
+
+	err = checkWritable(conf.settings, conf.repoPath)
+	if err != nil {
+		return 2, nil, err
+	}
+
+	changed, total, err := normalizeDates(conf.repoPath, loc)
+	if err != nil {
+		return 1, nil, err
+	}
+
+	fmt.Printf("Updated %d of %d readable dates\n", changed, total)
+	return 0, nil, nil
+}
@@ -0,0 +1,44 @@
+package mobilecombackup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/phillipgreen/mobilecombackup/pkg/calls"
+)
+
+func TestNormalizeDates(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "calls.xml"), []byte(`<calls count="2">
+  <call number="1" duration="0" date="1410881505425" type="3" readable_date="Sep 16, 2014 11:31:45 AM" />
+  <call number="2" duration="0" date="1420070400000" type="1" readable_date="Jan 1, 2015 12:00:00 AM" />
+</calls>`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changed, total, err := normalizeDates(dir, loc)
+	if err != nil {
+		t.Fatalf("err got %v, want nil", err)
+	}
+	if changed != 1 || total != 2 {
+		t.Errorf("got %d of %d changed, want 1 of 2", changed, total)
+	}
+
+	stored, err := calls.ReadCalls(filepath.Join(dir, "calls.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want = []string{"Sep 16, 2014 11:31:45 AM", "Dec 31, 2014 7:00:00 PM"}
+	for i, c := range stored {
+		if c.ReadableDate != want[i] {
+			t.Errorf("readable date %d got %q, want %q", i, c.ReadableDate, want[i])
+		}
+	}
+}
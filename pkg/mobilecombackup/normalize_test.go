@@ -0,0 +1,50 @@
+package mobilecombackup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/phillipgreen/mobilecombackup/pkg/calls"
+)
+
+func TestNormalizeRepositoryIsCanonical(t *testing.T) {
+	var shuffled = []string{
+		`<calls count="3">
+  <call number="2" duration="5" date="100" type="1" b="2" a="1" />
+  <call number="1" duration="5" date="100" type="1" />
+  <call number="3" duration="0" date="50" type="3" />
+</calls>`,
+		`<calls count="3">
+  <call number="3" duration="0" date="50" type="3" />
+  <call number="1" duration="5" date="100" type="1" />
+  <call number="2" duration="5" date="100" type="1" a="1" b="2" />
+</calls>`,
+	}
+
+	var outputs []string
+	for _, content := range shuffled {
+		dir := t.TempDir()
+		err := os.WriteFile(filepath.Join(dir, "calls.xml"), []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		count, err := normalizeRepository(dir)
+		if err != nil {
+			t.Fatalf("err got %v, want nil", err)
+		}
+		if count != 3 {
+			t.Errorf("count got %d, want 3", count)
+		}
+		normalized, err := os.ReadFile(calls.RepositoryFile(dir))
+		if err != nil {
+			t.Fatal(err)
+		}
+		outputs = append(outputs, string(normalized))
+	}
+
+	if outputs[0] != outputs[1] {
+		t.Errorf("normalized files differ:\n%s\n%s", outputs[0], outputs[1])
+	}
+}
@@ -0,0 +1,88 @@
+package mobilecombackup
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/phillipgreen/mobilecombackup/pkg/coalescer"
+)
+
+type progressTracker struct {
+	start   time.Time
+	records map[string]int
+	current Progress
+	report  func(Progress)
+}
+
+// prescan drains paths until ctx is done, counting the records of each so
+// progress can be reported against a known total, and returns the paths for
+// coalescing.
+func prescan(ctx context.Context, c coalescer.Coalescer, paths <-chan string, report func(Progress)) (<-chan string, *progressTracker) {
+	var tracker = progressTracker{records: map[string]int{}, report: report}
+	var found []string
+	for p := range paths {
+		if ctx.Err() != nil {
+			break
+		}
+		n, err := c.Count(p)
+		if err != nil {
+			log.Printf("Error on Counting [%s]: %v", p, err)
+		}
+		tracker.records[p] = n
+		tracker.current.RecordsTotal += n
+		found = append(found, p)
+	}
+	tracker.current.FilesTotal = len(found)
+
+	scanned := make(chan string, len(found))
+	for _, p := range found {
+		scanned <- p
+	}
+	close(scanned)
+
+	tracker.start = time.Now()
+	report(tracker.current)
+	return scanned, &tracker
+}
+
+func (t *progressTracker) done(path string) {
+	if t == nil {
+		return
+	}
+	t.current.FilesDone++
+	t.current.RecordsDone += t.records[path]
+	t.current.Elapsed = time.Since(t.start)
+	t.report(t.current)
+}
+
+const progressBarWidth = 30
+
+func renderProgress(w io.Writer, p Progress) {
+	var filled int
+	if p.RecordsTotal > 0 {
+		filled = progressBarWidth * p.RecordsDone / p.RecordsTotal
+	}
+	fmt.Fprintf(w, "\r[%s%s] %d/%d records (%d/%d files) %.0f rec/s ETA %v",
+		strings.Repeat("#", filled),
+		strings.Repeat(".", progressBarWidth-filled),
+		p.RecordsDone, p.RecordsTotal,
+		p.FilesDone, p.FilesTotal,
+		p.Rate(),
+		p.ETA().Round(time.Second))
+	if p.FilesDone >= p.FilesTotal {
+		fmt.Fprintln(w)
+	}
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,67 @@
+package mobilecombackup
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/phillipgreen/mobilecombackup/pkg/validation"
+)
+
+func TestProgressETA(t *testing.T) {
+	var tests = []struct {
+		desc     string
+		progress Progress
+		rate     float64
+		eta      time.Duration
+	}{
+		{"nothing elapsed",
+			Progress{RecordsTotal: 100}, 0, 0},
+		{"halfway",
+			Progress{RecordsTotal: 100, RecordsDone: 50, Elapsed: 5 * time.Second}, 10, 5 * time.Second},
+		{"complete",
+			Progress{RecordsTotal: 100, RecordsDone: 100, Elapsed: 4 * time.Second}, 25, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			if got := tt.progress.Rate(); got != tt.rate {
+				t.Errorf("rate got %v, want %v", got, tt.rate)
+			}
+			if got := tt.progress.ETA(); got != tt.eta {
+				t.Errorf("eta got %v, want %v", got, tt.eta)
+			}
+		})
+	}
+}
+
+func TestRenderProgress(t *testing.T) {
+	var buf bytes.Buffer
+	renderProgress(&buf, Progress{FilesTotal: 2, FilesDone: 1, RecordsTotal: 100, RecordsDone: 50, Elapsed: 5 * time.Second})
+
+	want := "\r[###############...............] 50/100 records (1/2 files) 10 rec/s ETA 5s"
+	if buf.String() != want {
+		t.Errorf("output got %q, want %q", buf.String(), want)
+	}
+}
+
+func TestRenderValidationProgress(t *testing.T) {
+	var tests = []struct {
+		p        validation.Progress
+		terminal bool
+		want     string
+	}{
+		{validation.Progress{Phase: "calls", Done: 3000, Total: 7000}, false, "calls 3000/7000\n"},
+		{validation.Progress{Phase: "calls", Done: 3000, Total: 7000}, true, "\rcalls 3000/7000"},
+		{validation.Progress{Phase: "calls", Done: 7000, Total: 7000}, true, "\rcalls 7000/7000\n"},
+		{validation.Progress{Phase: "calls", Done: 12}, false, "calls 12\n"},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		renderValidationProgress(&buf, tt.p, tt.terminal)
+		if buf.String() != tt.want {
+			t.Errorf("%+v terminal %v got %q, want %q", tt.p, tt.terminal, buf.String(), tt.want)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package mobilecombackup
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/phillipgreen/mobilecombackup/pkg/calls"
+)
+
+type provenanceConfig struct {
+	repoPath string
+	hash     string
+	date     int
+	format   string
+}
+
+func parseProvenanceFlags(s *settings, progname string, args []string) (conf *provenanceConfig, output string, err error) {
+	flags := flag.NewFlagSet(progname, flag.ContinueOnError)
+	var buf bytes.Buffer
+	flags.SetOutput(&buf)
+
+	var c provenanceConfig
+	flags.StringVar(&c.repoPath, "repo", ".", "path which contains repository")
+	flags.StringVar(&c.hash, "hash", "", "hash of the record to trace")
+	flags.IntVar(&c.date, "date", 0, "date, in epoch milliseconds, of the calls to trace")
+	flags.StringVar(&c.format, "format", "table", "output format: "+strings.Join(renderFormats, "|"))
+
+	err = parseArgs(s, flags, args)
+	if err != nil {
+		return nil, buf.String(), err
+	}
+	return &c, buf.String(), nil
+}
+
+func validateProvenanceConfig(conf *provenanceConfig) error {
+	if (conf.hash == "") == (conf.date == 0) {
+		return errors.New("Exactly one of -hash or -date must be specified")
+	}
+	return checkFormat(conf.format, renderFormats)
+}
+
+// recordHashes returns the hashes of the records conf asks about.
+func recordHashes(conf *provenanceConfig) (map[string]bool, error) {
+	if conf.hash != "" {
+		return map[string]bool{strings.ToLower(conf.hash): true}, nil
+	}
+	var hashes = map[string]bool{}
+	err := calls.StreamCalls(calls.RepositoryFile(conf.repoPath), func(c calls.Call) error {
+		if c.Date == conf.date {
+			hashes[c.Hash()] = true
+		}
+		return nil
+	})
+	return hashes, err
+}
+
+// findProvenance returns the provenance of the records conf asks about, in the
+// order they were imported.
+func findProvenance(conf *provenanceConfig) ([]calls.Provenance, error) {
+	hashes, err := recordHashes(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	var found []calls.Provenance
+	err = calls.StreamProvenance(calls.ProvenanceFile(conf.repoPath), func(p calls.Provenance) error {
+		if hashes[p.Record] {
+			found = append(found, p)
+		}
+		return nil
+	})
+	return found, err
+}
+
+func writeProvenance(w io.Writer, format string, found []calls.Provenance) error {
+	if format == "json" || format == "yaml" {
+		return writeStructured(w, format, found)
+	}
+	var rows [][]string
+	for _, p := range found {
+		rows = append(rows, []string{p.Record, p.ImportedAt.Format(time.RFC3339), p.Source, p.SourceSHA256, p.Format})
+	}
+	return writeRows(w, format, []string{"RECORD", "IMPORTED", "SOURCE", "SOURCE SHA256", "FORMAT"}, rows)
+}
+
+func runProvenanceShow(s *settings, progname string, args []string) (exitCode int, output *string, err error) {
+	conf, o, err := parseProvenanceFlags(s, progname, args)
+	if err == flag.ErrHelp {
+		return 4, nil, err
+	} else if err != nil {
+		return 3, &o, err
+	}
+
+	err = validateProvenanceConfig(conf)
+	if err != nil {
+		return 2, nil, err
+	}
+
+	found, err := findProvenance(conf)
+	if err != nil {
+		return 1, nil, err
+	}
+	if len(found) == 0 {
+		return 1, nil, notFound("No provenance recorded for the record")
+	}
+
+	err = writeProvenance(os.Stdout, conf.format, found)
+	if err != nil {
+		return 1, nil, err
+	}
+	return 0, nil, nil
+}
+
+func provenanceSubcommands() []command {
+	return []command{
+		{"show", "show which backup files records were imported from", runProvenanceShow},
+	}
+}
+
+func runProvenance(s *settings, progname string, args []string) (exitCode int, output *string, err error) {
+	var names []string
+	for _, c := range provenanceSubcommands() {
+		if len(args) > 0 && args[0] == c.name {
+			return c.run(s, progname+" "+c.name, args[1:])
+		}
+		names = append(names, c.name)
+	}
+	return 2, nil, fmt.Errorf("Usage of %s: expected one of %s", progname, strings.Join(names, ", "))
+}
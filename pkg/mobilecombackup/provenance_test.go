@@ -0,0 +1,71 @@
+package mobilecombackup
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/phillipgreen/mobilecombackup/internal/test_support"
+	"github.com/phillipgreen/mobilecombackup/pkg/calls"
+)
+
+func TestFindProvenance(t *testing.T) {
+	tmpdir := t.TempDir()
+	err := test_support.CopyDir("../../testdata", tmpdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repoDir := filepath.Join(tmpdir, "archive")
+
+	processor, err := InitWithOptions(repoDir, Options{DefaultRegion: "US"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = processor.Process(filepath.Join(tmpdir, "to_process"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	all, err := calls.ReadCalls(calls.RepositoryFile(repoDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var recorded []string
+	err = calls.StreamProvenance(calls.ProvenanceFile(repoDir), func(p calls.Provenance) error {
+		recorded = append(recorded, p.Record)
+		return nil
+	})
+	if err != nil || len(recorded) != 6 {
+		t.Fatalf("provenance entries got %d, err %v, want 6 new calls", len(recorded), err)
+	}
+
+	var imported calls.Call
+	for _, c := range all {
+		if c.Hash() == recorded[0] {
+			imported = c
+		}
+	}
+	var tests = []struct {
+		desc string
+		conf provenanceConfig
+		want int
+	}{
+		{"by hash", provenanceConfig{repoPath: repoDir, hash: imported.Hash()}, 1},
+		{"by date", provenanceConfig{repoPath: repoDir, date: imported.Date}, 1},
+		{"archived call", provenanceConfig{repoPath: repoDir, date: all[0].Date}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			found, err := findProvenance(&tt.conf)
+			if err != nil {
+				t.Fatalf("err got %v, want nil", err)
+			}
+			if len(found) != tt.want {
+				t.Fatalf("found got %v, want %d entries", found, tt.want)
+			}
+			if tt.want > 0 && (found[0].Record != imported.Hash() || found[0].SourceSHA256 == "") {
+				t.Errorf("provenance got %+v", found[0])
+			}
+		})
+	}
+}
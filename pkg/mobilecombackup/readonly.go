@@ -0,0 +1,64 @@
+package mobilecombackup
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// readOnlyFlag is the global flag which protects every repository from
+// modification, given before the command.
+const readOnlyFlag = "-read-only"
+
+// ErrReadOnly is returned by commands which would modify a read-only
+// repository.
+var ErrReadOnly = errors.New("repository is read-only")
+
+// stripReadOnlyFlag removes the readOnlyFlag from before the command in args,
+// reporting whether it was there.
+func stripReadOnlyFlag(args []string) ([]string, bool) {
+	if len(args) > 1 && (args[1] == readOnlyFlag || args[1] == "-"+readOnlyFlag) {
+		return append([]string{args[0]}, args[2:]...), true
+	}
+	return args, false
+}
+
+// checkWritable fails with ErrReadOnly when the repository at repoPath must
+// not be modified: when the readOnlyFlag was given to s, MB_READ_ONLY is true
+// or the repository configuration sets read_only. A nil s checks the process
+// environment and the repository configuration.
+func checkWritable(s *settings, repoPath string) error {
+	if s == nil {
+		s = &settings{env: os.LookupEnv, repository: repositorySettings}
+	}
+
+	// the environment takes precedence over the repository configuration, as
+	// it does for flags
+	var reason string
+	v, inEnv := s.env(envName("read-only"))
+	switch {
+	case s.readOnly:
+		reason = readOnlyFlag + " was given"
+	case inEnv:
+		set, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("Invalid %s: %w", envName("read-only"), err)
+		}
+		if set {
+			reason = envName("read-only") + " is set"
+		}
+	default:
+		repo, err := s.repository(repoPath)
+		if err != nil {
+			return err
+		}
+		if repo != nil && repo.ReadOnly {
+			reason = RepositoryConfigFile + " sets read_only"
+		}
+	}
+	if reason != "" {
+		return fmt.Errorf("Refusing to modify [%s] as %s: %w", repoPath, reason, ErrReadOnly)
+	}
+	return nil
+}
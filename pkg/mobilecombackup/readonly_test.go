@@ -0,0 +1,120 @@
+package mobilecombackup
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/phillipgreen/mobilecombackup/internal/test_support"
+)
+
+func TestCheckWritable(t *testing.T) {
+	var tests = []struct {
+		desc     string
+		flag     bool
+		env      map[string]string
+		config   string
+		readOnly bool
+	}{
+		{"writable", false, nil, "", false},
+		{"flag", true, nil, "", true},
+		{"environment", false, map[string]string{"MB_READ_ONLY": "true"}, "", true},
+		{"configuration", false, nil, "read_only: true\n", true},
+		{"environment overrides configuration", false, map[string]string{"MB_READ_ONLY": "false"}, "read_only: true\n", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			repoDir := t.TempDir()
+			if tt.config != "" {
+				err := os.WriteFile(filepath.Join(repoDir, RepositoryConfigFile), []byte(tt.config), 0644)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			s := &settings{
+				env: func(name string) (string, bool) {
+					v, ok := tt.env[name]
+					return v, ok
+				},
+				repository: repositorySettings,
+				readOnly:   tt.flag,
+			}
+
+			err := checkWritable(s, repoDir)
+			if got := errors.Is(err, ErrReadOnly); got != tt.readOnly || (err != nil && !got) {
+				t.Errorf("err got %v, want read-only %v", err, tt.readOnly)
+			}
+			if tt.readOnly {
+				exitCode, _, err := runNormalize(s, "prog normalize", []string{"-repo", repoDir})
+				if exitCode != 2 || !errors.Is(err, ErrReadOnly) {
+					t.Errorf("normalize got %d, %v, want 2, %v", exitCode, err, ErrReadOnly)
+				}
+			}
+		})
+	}
+}
+
+func TestStripReadOnlyFlag(t *testing.T) {
+	var tests = []struct {
+		args []string
+		want []string
+		set  bool
+	}{
+		{[]string{"prog", "-read-only", "normalize"}, []string{"prog", "normalize"}, true},
+		{[]string{"prog", "--read-only", "-repo", "x"}, []string{"prog", "-repo", "x"}, true},
+		{[]string{"prog", "normalize", "-read-only"}, []string{"prog", "normalize", "-read-only"}, false},
+	}
+	for _, tt := range tests {
+		got, set := stripReadOnlyFlag(tt.args)
+		if !reflect.DeepEqual(got, tt.want) || set != tt.set {
+			t.Errorf("stripReadOnlyFlag(%q) got %q, %v, want %q, %v", tt.args, got, set, tt.want, tt.set)
+		}
+	}
+}
+
+func TestRunReadOnlyFlagIsPerRun(t *testing.T) {
+	tmpdir := t.TempDir()
+	err := test_support.CopyDir("../../testdata", tmpdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repoDir := filepath.Join(tmpdir, "archive")
+
+	exitCode, _, err := Run([]string{"prog", "-read-only", "normalize", "-repo", repoDir})
+	if exitCode != 2 || !errors.Is(err, ErrReadOnly) {
+		t.Errorf("read-only run got %d, %v, want 2, %v", exitCode, err, ErrReadOnly)
+	}
+	exitCode, _, err = Run([]string{"prog", "normalize", "-repo", repoDir})
+	if exitCode != 0 || err != nil {
+		t.Errorf("next run got %d, %v, want 0, nil", exitCode, err)
+	}
+}
+
+func TestRunWatchReadOnly(t *testing.T) {
+	tmpdir := t.TempDir()
+	err := test_support.CopyDir("../../testdata", tmpdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repoDir := filepath.Join(tmpdir, "archive")
+
+	exitCode, _, err := Run([]string{"prog", "-read-only", "watch", "-once", "-repo", repoDir})
+	if exitCode != 2 || !errors.Is(err, ErrReadOnly) {
+		t.Errorf("watch got %d, %v, want 2, %v", exitCode, err, ErrReadOnly)
+	}
+	if _, err := os.Stat(filepath.Join(repoDir, "cache")); !os.IsNotExist(err) {
+		t.Errorf("read-only repository got a cache: %v", err)
+	}
+
+	// a status file elsewhere leaves the repository alone
+	statusFile := filepath.Join(tmpdir, "health.json")
+	exitCode, _, err = Run([]string{"prog", "-read-only", "watch", "-once", "-repo", repoDir, "-status-file", statusFile})
+	if exitCode != 0 || err != nil {
+		t.Errorf("watch with an outside status file got %d, %v, want 0, nil", exitCode, err)
+	}
+	if _, err := os.Stat(statusFile); err != nil {
+		t.Errorf("status file: %v", err)
+	}
+}
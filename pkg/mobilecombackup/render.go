@@ -0,0 +1,110 @@
+package mobilecombackup
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+)
+
+// renderFormats are the formats of commands whose output is a list of
+// records; writeRows writes the first two and writeStructured the others.
+var renderFormats = []string{"table", "csv", "json", "yaml"}
+
+// reportFormats are the formats of commands whose output is a report rather
+// than a list of records, which has no CSV form.
+var reportFormats = []string{"table", "json", "yaml"}
+
+// checkFormat returns an error unless format is one of formats.
+func checkFormat(format string, formats []string) error {
+	for _, f := range formats {
+		if format == f {
+			return nil
+		}
+	}
+	return fmt.Errorf("Unknown format %q, expected one of %s", format, strings.Join(formats, ", "))
+}
+
+// deprecatedFormat returns the value of a deprecated flag which -format
+// replaced, with text read as table, or format when it was not given.
+func deprecatedFormat(format, deprecated string) string {
+	switch deprecated {
+	case "":
+		return format
+	case "text":
+		return "table"
+	default:
+		return deprecated
+	}
+}
+
+// writeStructured writes v as indented JSON, or with format yaml as YAML with
+// the same field names and order.
+func writeStructured(w io.Writer, format string, v interface{}) error {
+	if format != "yaml" {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	}
+
+	// JSON is YAML, so decoding it keeps the json field names and order
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var node yaml.Node
+	err = yaml.Unmarshal(data, &node)
+	if err != nil {
+		return err
+	}
+	blockStyle(&node)
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	err = encoder.Encode(&node)
+	if cerr := encoder.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// blockStyle clears the flow style JSON decodes with, so that the encoder
+// picks the usual YAML style.
+func blockStyle(n *yaml.Node) {
+	n.Style = 0
+	for _, c := range n.Content {
+		blockStyle(c)
+	}
+}
+
+// writeRows writes rows under header as an aligned table, or with format csv
+// as CSV with a lower case header. A nil header is left out.
+func writeRows(w io.Writer, format string, header []string, rows [][]string) error {
+	if format == "csv" {
+		cw := csv.NewWriter(w)
+		if header != nil {
+			var names = make([]string, len(header))
+			for i, h := range header {
+				names[i] = strings.ReplaceAll(strings.ToLower(h), " ", "_")
+			}
+			_ = cw.Write(names)
+		}
+		for _, r := range rows {
+			_ = cw.Write(r)
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	if header != nil {
+		fmt.Fprintln(tw, strings.Join(header, "\t"))
+	}
+	for _, r := range rows {
+		fmt.Fprintln(tw, strings.Join(r, "\t"))
+	}
+	return tw.Flush()
+}
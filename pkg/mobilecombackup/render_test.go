@@ -0,0 +1,63 @@
+package mobilecombackup
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	var value = []struct {
+		Number string `json:"number"`
+		Count  int    `json:"count"`
+	}{{"5555550001", 2}, {"+1 555", 1}}
+	var header = []string{"NUMBER", "CALL COUNT"}
+	var rows = [][]string{{"5555550001", "2"}, {"+1 555", "1"}}
+
+	var tests = []struct {
+		format string
+		want   string
+	}{
+		{"table", "NUMBER      CALL COUNT\n5555550001  2\n+1 555      1\n"},
+		{"csv", "number,call_count\n5555550001,2\n+1 555,1\n"},
+		{"json", "[\n  {\n    \"number\": \"5555550001\",\n    \"count\": 2\n  },\n  {\n    \"number\": \"+1 555\",\n    \"count\": 1\n  }\n]\n"},
+		{"yaml", "- number: \"5555550001\"\n  count: 2\n- number: +1 555\n  count: 1\n"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		var err error
+		if tt.format == "json" || tt.format == "yaml" {
+			err = writeStructured(&buf, tt.format, value)
+		} else {
+			err = writeRows(&buf, tt.format, header, rows)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != tt.want {
+			t.Errorf("%s got %q, want %q", tt.format, buf.String(), tt.want)
+		}
+	}
+
+	var buf bytes.Buffer
+	err := writeRows(&buf, "table", nil, [][]string{{"calls:", "2"}, {"talk time:", "1m0s"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "calls:      2\ntalk time:  1m0s\n"; buf.String() != want {
+		t.Errorf("table without header got %q, want %q", buf.String(), want)
+	}
+
+	if err := checkFormat("xml", renderFormats); err == nil {
+		t.Errorf("unknown format got nil error")
+	}
+	for _, tt := range []struct{ format, deprecated, want string }{
+		{"table", "", "table"},
+		{"table", "text", "table"},
+		{"table", "json", "json"},
+		{"yaml", "", "yaml"},
+	} {
+		if got := deprecatedFormat(tt.format, tt.deprecated); got != tt.want {
+			t.Errorf("deprecatedFormat(%q, %q) got %q, want %q", tt.format, tt.deprecated, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package mobilecombackup
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/phillipgreen/mobilecombackup/pkg/coalescer"
+)
+
+func (s *importSummary) add(r coalescer.Result) {
+	s.Calls = r.Total
+	s.NewCalls += r.New
+	s.Duplicates += r.Duplicates
+	s.Filtered += r.Filtered
+	s.Rejected += r.Rejected
+	s.Salvaged += r.Salvaged
+	for year, n := range r.NewByYear {
+		s.NewByYear[year] += n
+	}
+	for number, name := range r.NewContacts {
+		s.NewContacts[number] = name
+	}
+}
+
+func writeImportReportTable(w io.Writer, s importSummary) error {
+	if s.DryRun {
+		fmt.Fprintln(w, "Dry run, the repository was not changed.")
+	}
+	var summary = [][]string{
+		{"paths:", fmt.Sprintf("%d (%d failed)", s.Paths, s.Failures)},
+		{"new calls:", strconv.Itoa(s.NewCalls)},
+		{"duplicate calls:", strconv.Itoa(s.Duplicates)},
+		{"filtered calls:", strconv.Itoa(s.Filtered)},
+		{"rejected calls:", strconv.Itoa(s.Rejected)},
+	}
+	if s.Salvaged > 0 {
+		summary = append(summary, []string{"salvaged calls:", strconv.Itoa(s.Salvaged)})
+	}
+	summary = append(summary,
+		[]string{"calls after import:", strconv.Itoa(s.Calls)},
+		[]string{"new contacts:", strconv.Itoa(len(s.NewContacts))})
+	err := writeRows(w, "table", nil, summary)
+	if err != nil {
+		return err
+	}
+
+	if len(s.NewByYear) > 0 {
+		var years = make([]int, 0, len(s.NewByYear))
+		for y := range s.NewByYear {
+			years = append(years, y)
+		}
+		sort.Ints(years)
+
+		var rows = make([][]string, 0, len(years))
+		for _, y := range years {
+			rows = append(rows, []string{strconv.Itoa(y), strconv.Itoa(s.NewByYear[y])})
+		}
+		fmt.Fprintln(w)
+		err = writeRows(w, "table", []string{"YEAR", "NEW CALLS"}, rows)
+		if err != nil {
+			return err
+		}
+	}
+	if len(s.NewContacts) == 0 {
+		return nil
+	}
+
+	var numbers = make([]string, 0, len(s.NewContacts))
+	for n := range s.NewContacts {
+		numbers = append(numbers, n)
+	}
+	sort.Strings(numbers)
+
+	var rows = make([][]string, 0, len(numbers))
+	for _, n := range numbers {
+		rows = append(rows, []string{n, s.NewContacts[n]})
+	}
+	fmt.Fprintln(w)
+	return writeRows(w, "table", []string{"NUMBER", "NEW CONTACT"}, rows)
+}
+
+func writeImportReport(w io.Writer, format string, s importSummary) error {
+	switch format {
+	case "json", "yaml":
+		return writeStructured(w, format, s)
+	default:
+		return writeImportReportTable(w, s)
+	}
+}
@@ -0,0 +1,81 @@
+package mobilecombackup
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/phillipgreen/mobilecombackup/internal/test_support"
+	"github.com/phillipgreen/mobilecombackup/pkg/contacts"
+)
+
+func TestImportReportNewContacts(t *testing.T) {
+	tmpdir := t.TempDir()
+	err := test_support.CopyDir("../../testdata", tmpdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repoDir := filepath.Join(tmpdir, "archive")
+	var book = contacts.Book{Contacts: []contacts.Contact{{Name: "Ada Lovelace", Numbers: []string{"5555550098"}}}}
+	err = book.Save(contacts.File(repoDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	backupDir := filepath.Join(tmpdir, "new")
+	err = os.Mkdir(backupDir, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// only Grace Hopper is unknown to contacts.yaml and the repository calls
+	err = os.WriteFile(filepath.Join(backupDir, "calls-new.xml"), []byte(`<?xml version='1.0' encoding='UTF-8' standalone='yes' ?>
+<calls count="5">
+  <call number="5555550099" duration="10" date="1430000000000" type="1" contact_name="Grace Hopper" />
+  <call number="5555550099" duration="11" date="1430000100000" type="2" contact_name="Grace Hopper" />
+  <call number="5555550098" duration="12" date="1430000200000" type="1" contact_name="Ada King" />
+  <call number="5555550097" duration="13" date="1430000300000" type="1" contact_name="(Unknown)" />
+  <call number="5555550001" duration="14" date="1430000400000" type="1" contact_name="John Stuart" />
+</calls>
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	processor, err := InitWithOptions(repoDir, Options{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := processor.Process(backupDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want = map[string]string{"5555550099": "Grace Hopper"}
+	if !reflect.DeepEqual(result.Calls.NewContacts, want) {
+		t.Errorf("new contacts got %v, want %v", result.Calls.NewContacts, want)
+	}
+
+	var summary = importSummary{DryRun: true, Paths: 1, NewByYear: map[int]int{}, NewContacts: map[string]string{}}
+	summary.add(result.Calls)
+	var tests = []struct {
+		format string
+		want   []string
+	}{
+		{"table", []string{"new contacts:        1\n", "NUMBER      NEW CONTACT\n5555550099  Grace Hopper\n"}},
+		{"json", []string{"\"new_contacts\": {\n    \"5555550099\": \"Grace Hopper\"\n  }"}},
+		{"yaml", []string{"new_contacts:\n  \"5555550099\": Grace Hopper\n"}},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		err := writeImportReport(&buf, tt.format, summary)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, w := range tt.want {
+			if !strings.Contains(buf.String(), w) {
+				t.Errorf("%s report got:\n%s\nwant to contain %q", tt.format, buf.String(), w)
+			}
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package mobilecombackup
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/phillipgreen/mobilecombackup/pkg/calls"
+	"github.com/phillipgreen/mobilecombackup/pkg/coalescer"
+	"github.com/phillipgreen/mobilecombackup/pkg/validation"
+)
+
+// Repository gives programs embedding this package access to a repository
+// without wiring the readers, validator and importer together themselves.
+type Repository struct {
+	root string
+}
+
+// OpenRepository opens the repository at root, which must already exist.
+func OpenRepository(root string) (*Repository, error) {
+	if _, err := os.Stat(calls.RepositoryFile(root)); err != nil {
+		return nil, fmt.Errorf("%s is not a repository, run init to create one: %w", root, err)
+	}
+	return &Repository{root}, nil
+}
+
+// Root returns the directory of the repository.
+func (r *Repository) Root() string {
+	return r.root
+}
+
+// Calls returns the reader of the calls of the repository.
+func (r *Repository) Calls() *CallReader {
+	return &CallReader{calls.RepositoryFile(r.root)}
+}
+
+// Validate checks the repository, as the validate command does.
+func (r *Repository) Validate(ctx context.Context) (validation.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return validation.Result{}, err
+	}
+	return validation.ValidateRepository(r.root)
+}
+
+// Import coalesces the backup files found under each of paths into the
+// repository. It stops at the first path which fails, and fails with
+// ErrReadOnly when the repository must not be modified.
+func (r *Repository) Import(ctx context.Context, options Options, paths ...string) (Result, error) {
+	var total = Result{coalescer.Result{NewByYear: map[int]int{}, NewContacts: map[string]string{}}}
+	err := checkWritable(nil, r.root)
+	if err != nil {
+		return total, err
+	}
+	processor, err := InitWithOptions(r.root, options)
+	if err != nil {
+		return total, err
+	}
+	for _, p := range paths {
+		result, err := processor.ProcessContext(ctx, p)
+		if err != nil {
+			return total, fmt.Errorf("importing %s: %w", p, err)
+		}
+		total.Calls.Total = result.Calls.Total
+		total.Calls.New += result.Calls.New
+		total.Calls.Rejected += result.Calls.Rejected
+		total.Calls.Duplicates += result.Calls.Duplicates
+		total.Calls.Filtered += result.Calls.Filtered
+		total.Calls.Salvaged += result.Calls.Salvaged
+		for year, n := range result.Calls.NewByYear {
+			total.Calls.NewByYear[year] += n
+		}
+		for number, name := range result.Calls.NewContacts {
+			total.Calls.NewContacts[number] = name
+		}
+	}
+	return total, nil
+}
+
+// CallReader reads the calls of a repository.
+type CallReader struct {
+	filePath string
+}
+
+// Stream invokes callback for each call in date order.
+func (cr *CallReader) Stream(ctx context.Context, callback func(calls.Call) error) error {
+	return calls.StreamCallsContext(ctx, cr.filePath, callback)
+}
+
+// StreamBetween invokes callback for each call at or after start and before
+// end; a zero start or end leaves that side open.
+func (cr *CallReader) StreamBetween(ctx context.Context, start, end time.Time, callback func(calls.Call) error) error {
+	return calls.StreamCallsBetweenContext(ctx, cr.filePath, start, end, callback)
+}
+
+// All loads every call.
+func (cr *CallReader) All(ctx context.Context) ([]calls.Call, error) {
+	return calls.ReadCallsContext(ctx, cr.filePath)
+}
+
+// Count returns the number of calls.
+func (cr *CallReader) Count(ctx context.Context) (int, error) {
+	return calls.CountCallsContext(ctx, cr.filePath)
+}
+
+// Years returns the UTC years which have calls, in ascending order.
+func (cr *CallReader) Years(ctx context.Context) ([]int, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return calls.GetAllYears(cr.filePath)
+}
@@ -0,0 +1,140 @@
+package mobilecombackup
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/phillipgreen/mobilecombackup/internal/test_support"
+	"github.com/phillipgreen/mobilecombackup/pkg/calls"
+)
+
+func TestRepository(t *testing.T) {
+	tmpdir := t.TempDir()
+	err := test_support.CopyDir("../../testdata", tmpdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	_, err = OpenRepository(filepath.Join(tmpdir, "to_process"))
+	if err == nil {
+		t.Errorf("opening a directory without calls got nil err")
+	}
+
+	repo, err := OpenRepository(filepath.Join(tmpdir, "archive"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := repo.Import(ctx, Options{DefaultRegion: "US"}, filepath.Join(tmpdir, "to_process"))
+	if err != nil {
+		t.Fatalf("import err got %v, want nil", err)
+	}
+	if result.Calls.Total != 22 || result.Calls.New != 6 {
+		t.Errorf("import got %+v, want 22 total and 6 new", result.Calls)
+	}
+
+	count, err := repo.Calls().Count(ctx)
+	if err != nil || count != 22 {
+		t.Errorf("count got %d, err %v, want 22", count, err)
+	}
+	all, err := repo.Calls().All(ctx)
+	if err != nil || len(all) != 22 {
+		t.Errorf("all got %d calls, err %v, want 22", len(all), err)
+	}
+
+	v, err := repo.Validate(ctx)
+	if err != nil || v.Errors() != 0 {
+		t.Errorf("validate got %v, err %v, want no errors", v.Violations, err)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	err = repo.Calls().Stream(cancelled, func(calls.Call) error { return nil })
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("stream err got %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestRepositoryImportHooks(t *testing.T) {
+	tmpdir := t.TempDir()
+	err := test_support.CopyDir("../../testdata", tmpdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := OpenRepository(filepath.Join(tmpdir, "archive"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var added []calls.Call
+	options := Options{
+		DefaultRegion: "US",
+		// skip 2015 and tag the rest
+		TransformCall: func(c *calls.Call) bool {
+			c.ContactName = "enriched"
+			return c.Time().UTC().Year() != 2015
+		},
+		OnCommit: func(a []calls.Call) { added = append(added, a...) },
+	}
+	result, err := repo.Import(context.Background(), options, filepath.Join(tmpdir, "to_process"))
+	if err != nil {
+		t.Fatalf("import err got %v, want nil", err)
+	}
+	if result.Calls.New != 5 || len(added) != 5 {
+		t.Errorf("new got %d, committed %d, want 5 and 5", result.Calls.New, len(added))
+	}
+	for _, c := range added {
+		if c.ContactName != "enriched" {
+			t.Errorf("committed call %+v was not transformed", c)
+		}
+	}
+}
+
+func TestRepositoryImportDamaged(t *testing.T) {
+	tmpdir := t.TempDir()
+	err := test_support.CopyDir("../../testdata", tmpdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repoDir := filepath.Join(tmpdir, "archive")
+	err = os.WriteFile(calls.RepositoryFile(repoDir), []byte("<calls count=\"1\">\n  <call number=\"1\" dura"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := OpenRepository(repoDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = repo.Import(context.Background(), Options{}, filepath.Join(tmpdir, "to_process"))
+	if err == nil {
+		t.Errorf("importing into a damaged repository got nil err")
+	}
+}
+
+func TestRepositoryImportReadOnly(t *testing.T) {
+	tmpdir := t.TempDir()
+	err := test_support.CopyDir("../../testdata", tmpdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repoDir := filepath.Join(tmpdir, "archive")
+	err = os.WriteFile(filepath.Join(repoDir, RepositoryConfigFile), []byte("read_only: true\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := OpenRepository(repoDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = repo.Import(context.Background(), Options{}, filepath.Join(tmpdir, "to_process"))
+	if !errors.Is(err, ErrReadOnly) {
+		t.Errorf("err got %v, want %v", err, ErrReadOnly)
+	}
+	if count, err := repo.Calls().Count(context.Background()); err != nil || count != 16 {
+		t.Errorf("count got %d, %v, want the 16 calls untouched", count, err)
+	}
+}
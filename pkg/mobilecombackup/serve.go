@@ -0,0 +1,170 @@
+package mobilecombackup
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/phillipgreen/mobilecombackup/pkg/calls"
+)
+
+type serveConfig struct {
+	repoPath string
+	address  string
+}
+
+func parseServeFlags(s *settings, progname string, args []string) (conf *serveConfig, output string, err error) {
+	flags := flag.NewFlagSet(progname, flag.ContinueOnError)
+	var buf bytes.Buffer
+	flags.SetOutput(&buf)
+
+	var c serveConfig
+	flags.StringVar(&c.repoPath, "repo", ".", "path which contains repository")
+	flags.StringVar(&c.address, "addr", "localhost:8080", "address to listen on")
+
+	err = parseArgs(s, flags, args)
+	if err != nil {
+		return nil, buf.String(), err
+	}
+	return &c, buf.String(), nil
+}
+
+func callYear(c calls.Call) int {
+	return c.Time().UTC().Year()
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
+
+func serveYears(repoPath string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		snapshot, err := calls.OpenSnapshotContext(r.Context(), repoPath)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer snapshot.Close()
+
+		seen := map[int]bool{}
+		err = snapshot.StreamCalls(r.Context(), func(c calls.Call) error {
+			seen[callYear(c)] = true
+			return nil
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		var years = make([]int, 0, len(seen))
+		for y := range seen {
+			years = append(years, y)
+		}
+		sort.Ints(years)
+		writeJSON(w, years)
+	}
+}
+
+var errStopStreaming = errors.New("client went away")
+
+func serveCalls(repoPath string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var start, end time.Time
+		if y := r.URL.Query().Get("year"); y != "" {
+			year, err := strconv.Atoi(y)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("invalid year %q", y), http.StatusBadRequest)
+				return
+			}
+			start = time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+			end = start.AddDate(1, 0, 0)
+		}
+
+		// an import committing meanwhile does not tear the response
+		snapshot, err := calls.OpenSnapshotContext(r.Context(), repoPath)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer snapshot.Close()
+
+		// calls are streamed so large repositories are never fully loaded
+		w.Header().Set("Content-Type", "application/json")
+		var count int
+		_, err = w.Write([]byte("["))
+		if err == nil {
+			err = snapshot.StreamCallsBetween(r.Context(), start, end, func(c calls.Call) error {
+				out, err := json.Marshal(c)
+				if err != nil {
+					return err
+				}
+				if count > 0 {
+					out = append([]byte(","), out...)
+				}
+				count++
+				if _, err := w.Write(out); err != nil {
+					return errStopStreaming
+				}
+				return nil
+			})
+		}
+		if err == errStopStreaming {
+			return
+		}
+		if err != nil {
+			// headers are already sent; the truncated body signals the failure
+			log.Printf("Error streaming calls: %v", err)
+			return
+		}
+		_, err = w.Write([]byte("]\n"))
+		if err != nil {
+			log.Printf("Error writing response: %v", err)
+		}
+	}
+}
+
+func newServeHandler(repoPath string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/years", serveYears(repoPath))
+	mux.HandleFunc("/calls", serveCalls(repoPath))
+	return readOnly(mux)
+}
+
+func readOnly(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "repository is read-only", http.StatusMethodNotAllowed)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func runServe(s *settings, progname string, args []string) (exitCode int, output *string, err error) {
+	conf, o, err := parseServeFlags(s, progname, args)
+	if err == flag.ErrHelp {
+		return 4, nil, err
+	} else if err != nil {
+		return 3, &o, err
+	}
+
+	log.Printf("Serving repository [%s] on %s", conf.repoPath, conf.address)
+	err = http.ListenAndServe(conf.address, newServeHandler(conf.repoPath))
+	if err != nil {
+		return 1, nil, err
+	}
+
+	return 0, nil, nil
+}
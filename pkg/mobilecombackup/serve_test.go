@@ -0,0 +1,70 @@
+package mobilecombackup
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/phillipgreen/mobilecombackup/pkg/calls"
+)
+
+func TestServeYears(t *testing.T) {
+	handler := newServeHandler("../../testdata/archive")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/years", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status got %d, want %d", rec.Code, http.StatusOK)
+	}
+	var years []int
+	err := json.Unmarshal(rec.Body.Bytes(), &years)
+	if err != nil {
+		t.Fatalf("body is not json: %v", err)
+	}
+	if !reflect.DeepEqual(years, []int{2014, 2015}) {
+		t.Errorf("years got %v, want [2014 2015]", years)
+	}
+}
+
+func TestServeCalls(t *testing.T) {
+	var tests = []struct {
+		url   string
+		count int
+	}{
+		{"/calls", 16},
+		{"/calls?year=2015", 3},
+		{"/calls?year=1999", 0},
+	}
+
+	handler := newServeHandler("../../testdata/archive")
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.url, nil))
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status got %d, want %d", rec.Code, http.StatusOK)
+			}
+			var got []calls.Call
+			err := json.Unmarshal(rec.Body.Bytes(), &got)
+			if err != nil {
+				t.Fatalf("body is not json: %v\n%s", err, rec.Body.String())
+			}
+			if len(got) != tt.count {
+				t.Errorf("calls got %d, want %d", len(got), tt.count)
+			}
+		})
+	}
+}
+
+func TestServeRejectsWrites(t *testing.T) {
+	handler := newServeHandler("../../testdata/archive")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/calls", nil))
+
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("status got %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+}
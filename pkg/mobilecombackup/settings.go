@@ -0,0 +1,223 @@
+package mobilecombackup
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RepositoryConfigFile is the name of the configuration file within a
+// repository.
+const RepositoryConfigFile = "mobilecombackup.yaml"
+
+const envPrefix = "MB_"
+
+// fileSettings is the content of a configuration file. Values are given as
+// they would be on the command line, keyed by flag name without the dash:
+//
+//	defaults:
+//	  region: GB
+//	commands:
+//	  import:
+//	    workers: 4
+//	  stats calls:
+//	    format: json
+//	log_file: /var/log/mobilecombackup.log
+//	read_only: true
+//	budgets:
+//	  total: 2GB
+//	history_keep: 5
+type fileSettings struct {
+	// Defaults apply to every command which has the flag.
+	Defaults map[string]string `yaml:"defaults"`
+	// Commands apply to a single command, named as on the command line.
+	Commands map[string]map[string]string `yaml:"commands"`
+	// LogFile receives the log instead of standard error.
+	LogFile string `yaml:"log_file"`
+	// ReadOnly, in a repository configuration, makes commands which would
+	// modify the repository fail.
+	ReadOnly bool `yaml:"read_only"`
+	// Budgets, in a repository configuration, are the sizes validate warns
+	// about exceeding; see checkBudgets.
+	Budgets map[string]string `yaml:"budgets"`
+	// HistoryKeep, in a repository configuration, is how many versions of
+	// each file saveHistory keeps.
+	HistoryKeep *int `yaml:"history_keep"`
+}
+
+func (s *fileSettings) lookup(command, name string) (string, bool) {
+	if s == nil {
+		return "", false
+	}
+	if v, ok := s.Commands[command][name]; ok {
+		return v, true
+	}
+	v, ok := s.Defaults[name]
+	return v, ok
+}
+
+// settings holds what is configured outside of the command line. Flags take
+// precedence over MB_* environment variables, which take precedence over the
+// repository configuration, which takes precedence over the user
+// configuration.
+type settings struct {
+	env  func(string) (string, bool)
+	user *fileSettings
+	// repository loads the configuration of the repository at a path
+	repository func(repoPath string) (*fileSettings, error)
+	// readOnly is set when the readOnlyFlag is given.
+	readOnly bool
+}
+
+func readSettingsFile(filePath string) (*fileSettings, error) {
+	content, err := os.ReadFile(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var s fileSettings
+	err = yaml.Unmarshal(content, &s)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filePath, err)
+	}
+	return &s, nil
+}
+
+// UserConfigFile returns the path of the user configuration file, usually
+// ~/.config/mobilecombackup/config.yaml.
+func UserConfigFile() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "mobilecombackup", "config.yaml"), nil
+}
+
+// repositorySettings reads the configuration of the repository at repoPath.
+func repositorySettings(repoPath string) (*fileSettings, error) {
+	return readSettingsFile(filepath.Join(repoPath, RepositoryConfigFile))
+}
+
+// loadSettings reads the user configuration and the environment.
+func loadSettings() (*settings, error) {
+	var s = settings{
+		env:        os.LookupEnv,
+		repository: repositorySettings,
+	}
+	userFile, err := UserConfigFile()
+	if err == nil {
+		s.user, err = readSettingsFile(userFile)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// logFile returns where the log should be written, if configured.
+func (s *settings) logFile() string {
+	if v, ok := s.env(envPrefix + "LOG_FILE"); ok {
+		return v
+	}
+	if s.user != nil {
+		return s.user.LogFile
+	}
+	return ""
+}
+
+// envName returns the environment variable for the flag name.
+func envName(name string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// commandName returns the command a flag set belongs to from its name, which
+// is the program followed by the command, as Run names them.
+func commandName(flags *flag.FlagSet) string {
+	var fields = strings.Fields(flags.Name())
+	if len(fields) < 2 {
+		return "import"
+	}
+	return strings.Join(fields[1:], " ")
+}
+
+// parseArgs parses args and then sets the flags which were not given from s.
+// When s is nil, only the flags are used.
+func parseArgs(s *settings, flags *flag.FlagSet, args []string) error {
+	if describeFlags != nil {
+		describeFlags(flags)
+		return flag.ErrHelp
+	}
+	err := flags.Parse(args)
+	if err != nil || s == nil {
+		return err
+	}
+	var command = commandName(flags)
+
+	var explicit = map[string]bool{}
+	flags.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	var set = func(name, value, source string) error {
+		err := flags.Set(name, value)
+		if err != nil {
+			return fmt.Errorf("Invalid %s from %s: %w", name, source, err)
+		}
+		return nil
+	}
+
+	// the repository configuration is found through the repository
+	var repo *fileSettings
+	if f := flags.Lookup("repo"); f != nil {
+		if !explicit["repo"] {
+			if v, ok := s.env(envName("repo")); ok {
+				err = set("repo", v, envName("repo"))
+			} else if v, ok := s.user.lookup(command, "repo"); ok {
+				err = set("repo", v, "user configuration")
+			}
+			if err != nil {
+				return err
+			}
+		}
+		repo, err = s.repository(f.Value.String())
+		if err != nil {
+			return err
+		}
+	}
+
+	flags.VisitAll(func(f *flag.Flag) {
+		if err != nil || explicit[f.Name] || f.Name == "repo" {
+			return
+		}
+		if v, ok := s.env(envName(f.Name)); ok {
+			err = set(f.Name, v, envName(f.Name))
+		} else if v, ok := repo.lookup(command, f.Name); ok {
+			err = set(f.Name, v, RepositoryConfigFile)
+		} else if v, ok := s.user.lookup(command, f.Name); ok {
+			err = set(f.Name, v, "user configuration")
+		}
+	})
+	return err
+}
+
+// useSettings loads the settings for Run and applies the log file.
+func useSettings() (*settings, error) {
+	s, err := loadSettings()
+	if err != nil {
+		return nil, err
+	}
+	if path := s.logFile(); path != "" {
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, err
+		}
+		log.SetOutput(f)
+	}
+	return s, nil
+}
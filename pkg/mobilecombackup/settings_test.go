@@ -0,0 +1,69 @@
+package mobilecombackup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseArgsSettingsPrecedence(t *testing.T) {
+	repoDir := t.TempDir()
+	err := os.WriteFile(filepath.Join(repoDir, RepositoryConfigFile), []byte(`
+defaults:
+  region: GB
+commands:
+  import:
+    workers: 3
+    on-parse-error: fail
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var env = map[string]string{"MB_ON_PARSE_ERROR": "reject-file"}
+	s := &settings{
+		env: func(name string) (string, bool) {
+			v, ok := env[name]
+			return v, ok
+		},
+		user: &fileSettings{
+			Defaults: map[string]string{"repo": repoDir, "region": "US"},
+			Commands: map[string]map[string]string{"import": {"workers": "2", "quiet": "true"}},
+		},
+		repository: func(repoPath string) (*fileSettings, error) {
+			return readSettingsFile(filepath.Join(repoPath, RepositoryConfigFile))
+		},
+	}
+
+	var tests = []struct {
+		desc string
+		args []string
+		want config
+	}{
+		{"configured",
+			[]string{"myPath"},
+			config{repoPath: repoDir, quiet: true, region: "GB", workers: 3, onParseError: "reject-file", format: "table", pathsToProcess: []string{"myPath"}}},
+		{"flags win",
+			[]string{"-workers", "8", "-on-parse-error", "skip", "-region", "", "myPath"},
+			config{repoPath: repoDir, quiet: true, region: "", workers: 8, onParseError: "skip", format: "table", pathsToProcess: []string{"myPath"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			conf, _, err := parseFlags(s, "prog", tt.args)
+			if err != nil {
+				t.Fatalf("err got %v, want nil", err)
+			}
+			if conf.repoPath != tt.want.repoPath || conf.quiet != tt.want.quiet || conf.region != tt.want.region ||
+				conf.workers != tt.want.workers || conf.onParseError != tt.want.onParseError {
+				t.Errorf("conf got %+v, want %+v", *conf, tt.want)
+			}
+		})
+	}
+
+	// stats has no workers flag, so only the shared defaults apply
+	conf, _, err := parseStatsFlags(s, "prog stats calls", nil)
+	if err != nil || conf.repoPath != repoDir {
+		t.Errorf("stats conf got %+v, err %v, want repo %s", conf, err, repoDir)
+	}
+}
@@ -0,0 +1,360 @@
+package mobilecombackup
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/phillipgreen/mobilecombackup/pkg/calls"
+)
+
+type statsConfig struct {
+	repoPath   string
+	format     string
+	minGapDays int
+	groupBy    string
+	noCache    bool
+	settings   *settings
+}
+
+func parseStatsFlags(s *settings, progname string, args []string) (conf *statsConfig, output string, err error) {
+	flags := flag.NewFlagSet(progname, flag.ContinueOnError)
+	var buf bytes.Buffer
+	flags.SetOutput(&buf)
+
+	var c = statsConfig{settings: s}
+	flags.StringVar(&c.repoPath, "repo", ".", "path which contains repository")
+	flags.StringVar(&c.format, "format", "table", "output format: "+strings.Join(renderFormats, "|"))
+	flags.IntVar(&c.minGapDays, "min-gap-days", 45, "shortest stretch without records, in days, reported by coverage")
+	flags.StringVar(&c.groupBy, "group-by", calls.GroupContact, "comma separated fields group counts calls by: "+strings.Join(calls.GroupFields, "|"))
+	flags.BoolVar(&c.noCache, "no-cache", false, "recompute the statistics even when the cache is current")
+
+	err = parseArgs(s, flags, args)
+	if err != nil {
+		return nil, buf.String(), err
+	}
+	return &c, buf.String(), nil
+}
+
+func validateStatsConfig(conf *statsConfig) error {
+	if conf.minGapDays < 1 {
+		return fmt.Errorf("Min gap days must be atleast 1, got %d", conf.minGapDays)
+	}
+	if _, err := parseGroupFields(conf.groupBy); err != nil {
+		return err
+	}
+	return checkFormat(conf.format, renderFormats)
+}
+
+// cachedStats fills v from the cache entry key when it was computed from the
+// current calls file, in loc unless it is nil, and otherwise calls compute
+// with all calls and caches the v it sets, unless the repository is
+// read-only.
+func cachedStats(conf *statsConfig, key string, loc *time.Location, v interface{}, compute func([]calls.Call)) error {
+	var callsFile = calls.RepositoryFile(conf.repoPath)
+	hash, err := calls.FileSHA256(callsFile)
+	if err != nil {
+		return err
+	}
+	if !conf.noCache && readStatsCache(conf.repoPath, hash, key, loc, v) {
+		return nil
+	}
+
+	all, err := calls.ReadCalls(callsFile)
+	if err != nil {
+		return err
+	}
+	compute(all)
+	if checkWritable(conf.settings, conf.repoPath) != nil {
+		return nil
+	}
+	err = writeStatsCache(conf.repoPath, hash, datesOf(all), key, loc, v)
+	if err != nil {
+		log.Printf("Error caching stats: %v", err)
+	}
+	return nil
+}
+
+func seconds(s int) time.Duration {
+	return time.Duration(s) * time.Second
+}
+
+func writeCallStatsTable(w io.Writer, s calls.Stats) error {
+	err := writeRows(w, "table", nil, [][]string{
+		{"calls:", strconv.Itoa(s.Calls)},
+		{"incoming:", strconv.Itoa(s.Incoming)},
+		{"outgoing:", strconv.Itoa(s.Outgoing)},
+		{"missed:", fmt.Sprintf("%d (%.1f%% of inbound)", s.Missed, s.MissedRate*100)},
+		{"voicemail:", strconv.Itoa(s.Voicemail)},
+		{"rejected:", strconv.Itoa(s.Rejected)},
+		{"blocked:", strconv.Itoa(s.Blocked)},
+		{"talk time:", seconds(s.TalkSeconds).String()},
+		{"average duration:", time.Duration(s.AverageSeconds * float64(time.Second)).Round(time.Second).String()},
+		{"busiest hour:", fmt.Sprintf("%02d:00 (%d calls)", s.BusiestHour(), s.ByHour[s.BusiestHour()])},
+		{"busiest weekday:", fmt.Sprintf("%v (%d calls)", s.BusiestWeekday(), s.ByWeekday[s.BusiestWeekday()])},
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w)
+	var rows = make([][]string, 0, len(s.Contacts))
+	for _, c := range s.Contacts {
+		rows = append(rows, []string{c.Contact, strconv.Itoa(c.Calls), strconv.Itoa(c.Incoming), strconv.Itoa(c.Outgoing), strconv.Itoa(c.Missed), seconds(c.TalkSeconds).String()})
+	}
+	return writeRows(w, "table", []string{"CONTACT", "CALLS", "IN", "OUT", "MISSED", "TALK TIME"}, rows)
+}
+
+// writeCallStatsCSV writes one row per value so summary, hourly, weekday and
+// contact figures share a single set of columns.
+func writeCallStatsCSV(w io.Writer, s calls.Stats) error {
+	var rows [][]string
+	row := func(section, key, metric string, value interface{}) {
+		rows = append(rows, []string{section, key, metric, fmt.Sprint(value)})
+	}
+
+	row("summary", "", "calls", s.Calls)
+	row("summary", "", "incoming", s.Incoming)
+	row("summary", "", "outgoing", s.Outgoing)
+	row("summary", "", "missed", s.Missed)
+	row("summary", "", "voicemail", s.Voicemail)
+	row("summary", "", "rejected", s.Rejected)
+	row("summary", "", "blocked", s.Blocked)
+	row("summary", "", "missed_rate", strconv.FormatFloat(s.MissedRate, 'f', 4, 64))
+	row("summary", "", "talk_seconds", s.TalkSeconds)
+	row("summary", "", "average_seconds", strconv.FormatFloat(s.AverageSeconds, 'f', 1, 64))
+	for h, n := range s.ByHour {
+		row("hour", strconv.Itoa(h), "calls", n)
+	}
+	for d, n := range s.ByWeekday {
+		row("weekday", time.Weekday(d).String(), "calls", n)
+	}
+	for _, c := range s.Contacts {
+		row("contact", c.Contact, "calls", c.Calls)
+		row("contact", c.Contact, "incoming", c.Incoming)
+		row("contact", c.Contact, "outgoing", c.Outgoing)
+		row("contact", c.Contact, "missed", c.Missed)
+		row("contact", c.Contact, "talk_seconds", c.TalkSeconds)
+	}
+	return writeRows(w, "csv", []string{"SECTION", "KEY", "METRIC", "VALUE"}, rows)
+}
+
+func writeCallStats(w io.Writer, format string, s calls.Stats) error {
+	switch format {
+	case "json", "yaml":
+		return writeStructured(w, format, s)
+	case "csv":
+		return writeCallStatsCSV(w, s)
+	default:
+		return writeCallStatsTable(w, s)
+	}
+}
+
+func runCallStats(s *settings, progname string, args []string) (exitCode int, output *string, err error) {
+	conf, o, err := parseStatsFlags(s, progname, args)
+	if err == flag.ErrHelp {
+		return 4, nil, err
+	} else if err != nil {
+		return 3, &o, err
+	}
+
+	err = validateStatsConfig(conf)
+	if err != nil {
+		return 2, nil, err
+	}
+
+	var stats calls.Stats
+	err = cachedStats(conf, "calls", time.Local, &stats, func(all []calls.Call) {
+		stats = calls.Summarize(all, time.Local)
+	})
+	if err != nil {
+		return 1, nil, err
+	}
+
+	err = writeCallStats(os.Stdout, conf.format, stats)
+	if err != nil {
+		return 1, nil, err
+	}
+
+	return 0, nil, nil
+}
+
+func writeCoverageTable(w io.Writer, c calls.Coverage) error {
+	var summary = [][]string{{"calls:", strconv.Itoa(c.Calls)}}
+	if c.Calls > 0 {
+		summary = append(summary, []string{"first:", c.First.Format(time.RFC3339)}, []string{"last:", c.Last.Format(time.RFC3339)})
+	}
+	summary = append(summary, []string{"gaps:", strconv.Itoa(len(c.Gaps))})
+	err := writeRows(w, "table", nil, summary)
+	if err != nil || len(c.Gaps) == 0 {
+		return err
+	}
+
+	fmt.Fprintln(w)
+	return writeGaps(w, "table", c.Gaps)
+}
+
+// writeGaps writes one row per gap, with format table or csv.
+func writeGaps(w io.Writer, format string, gaps []calls.Gap) error {
+	var rows = make([][]string, 0, len(gaps))
+	for _, g := range gaps {
+		rows = append(rows, []string{g.Start.Format(time.RFC3339), g.End.Format(time.RFC3339), strconv.FormatFloat(g.Days, 'f', 1, 64)})
+	}
+	return writeRows(w, format, []string{"FROM", "TO", "DAYS"}, rows)
+}
+
+func writeCoverage(w io.Writer, format string, c calls.Coverage) error {
+	switch format {
+	case "json", "yaml":
+		return writeStructured(w, format, c)
+	case "csv":
+		return writeGaps(w, format, c.Gaps)
+	default:
+		return writeCoverageTable(w, c)
+	}
+}
+
+func runCoverageStats(s *settings, progname string, args []string) (exitCode int, output *string, err error) {
+	conf, o, err := parseStatsFlags(s, progname, args)
+	if err == flag.ErrHelp {
+		return 4, nil, err
+	} else if err != nil {
+		return 3, &o, err
+	}
+
+	err = validateStatsConfig(conf)
+	if err != nil {
+		return 2, nil, err
+	}
+
+	var c calls.Coverage
+	err = cachedStats(conf, fmt.Sprintf("coverage:%d", conf.minGapDays), nil, &c, func(all []calls.Call) {
+		c = calls.FindGaps(all, time.Duration(conf.minGapDays)*24*time.Hour)
+	})
+	if err != nil {
+		return 1, nil, err
+	}
+
+	err = writeCoverage(os.Stdout, conf.format, c)
+	if err != nil {
+		return 1, nil, err
+	}
+
+	return 0, nil, nil
+}
+
+// parseGroupFields parses a comma separated list of calls.GroupFields.
+func parseGroupFields(s string) ([]string, error) {
+	var fields = strings.Split(s, ",")
+	for i, f := range fields {
+		fields[i] = strings.TrimSpace(f)
+		var known bool
+		for _, g := range calls.GroupFields {
+			known = known || fields[i] == g
+		}
+		if !known {
+			return nil, fmt.Errorf("Unknown group by field %q, expected one of %s", fields[i], strings.Join(calls.GroupFields, ", "))
+		}
+	}
+	return fields, nil
+}
+
+// writeGroupsRows writes one row per group, with format table or csv. The
+// talk time is a duration in a table and seconds in CSV.
+func writeGroupsRows(w io.Writer, format string, fields []string, groups []calls.Group) error {
+	var header = make([]string, 0, len(fields)+2)
+	for _, f := range fields {
+		header = append(header, strings.ToUpper(f))
+	}
+	var talkTime = func(g calls.Group) string { return seconds(g.TalkSeconds).String() }
+	header = append(header, "CALLS", "TALK TIME")
+	if format == "csv" {
+		talkTime = func(g calls.Group) string { return strconv.Itoa(g.TalkSeconds) }
+		header[len(header)-1] = "TALK SECONDS"
+	}
+
+	var rows = make([][]string, 0, len(groups))
+	for _, g := range groups {
+		rows = append(rows, append(append([]string{}, g.Values...), strconv.Itoa(g.Calls), talkTime(g)))
+	}
+	return writeRows(w, format, header, rows)
+}
+
+// writeGroupsStructured writes one object per group, keyed by the grouped
+// fields.
+func writeGroupsStructured(w io.Writer, format string, fields []string, groups []calls.Group) error {
+	var rows = make([]map[string]interface{}, 0, len(groups))
+	for _, g := range groups {
+		var row = map[string]interface{}{"calls": g.Calls, "talk_seconds": g.TalkSeconds}
+		for i, f := range fields {
+			row[f] = g.Values[i]
+		}
+		rows = append(rows, row)
+	}
+	return writeStructured(w, format, rows)
+}
+
+func writeGroups(w io.Writer, format string, fields []string, groups []calls.Group) error {
+	switch format {
+	case "json", "yaml":
+		return writeGroupsStructured(w, format, fields, groups)
+	default:
+		return writeGroupsRows(w, format, fields, groups)
+	}
+}
+
+func runGroupStats(s *settings, progname string, args []string) (exitCode int, output *string, err error) {
+	conf, o, err := parseStatsFlags(s, progname, args)
+	if err == flag.ErrHelp {
+		return 4, nil, err
+	} else if err != nil {
+		return 3, &o, err
+	}
+
+	err = validateStatsConfig(conf)
+	if err != nil {
+		return 2, nil, err
+	}
+	// validated by validateStatsConfig
+	fields, _ := parseGroupFields(conf.groupBy)
+
+	var groups []calls.Group
+	err = cachedStats(conf, "group:"+strings.Join(fields, ","), time.Local, &groups, func(all []calls.Call) {
+		groups = calls.GroupBy(all, fields, time.Local)
+	})
+	if err != nil {
+		return 1, nil, err
+	}
+
+	err = writeGroups(os.Stdout, conf.format, fields, groups)
+	if err != nil {
+		return 1, nil, err
+	}
+
+	return 0, nil, nil
+}
+
+func statsSubcommands() []command {
+	return []command{
+		{"calls", "call counts, talk time, missed-call rate and per-contact aggregates", runCallStats},
+		{"coverage", "gaps in the call timeline which suggest missing backups", runCoverageStats},
+		{"group", "call counts and talk time grouped by contact, month or type", runGroupStats},
+	}
+}
+
+func runStats(s *settings, progname string, args []string) (exitCode int, output *string, err error) {
+	var names []string
+	for _, c := range statsSubcommands() {
+		if len(args) > 0 && args[0] == c.name {
+			return c.run(s, progname+" "+c.name, args[1:])
+		}
+		names = append(names, c.name)
+	}
+	return 2, nil, fmt.Errorf("Usage of %s: expected one of %s", progname, strings.Join(names, ", "))
+}
@@ -0,0 +1,150 @@
+package mobilecombackup
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/phillipgreen/mobilecombackup/pkg/calls"
+)
+
+// statsCache holds computed statistics for the calls file with the hash
+// CallsSHA256, so that they are only recomputed once the calls change.
+type statsCache struct {
+	CallsSHA256 string `json:"calls_sha256"`
+	// Dates spans the calls, so that entries computed in a time zone are
+	// keyed on its offsets over them without reading the calls.
+	Dates   callDates                  `json:"dates"`
+	Entries map[string]json.RawMessage `json:"entries"`
+}
+
+// callDates are the dates of the earliest and latest calls.
+type callDates struct {
+	First int `json:"first"`
+	Last  int `json:"last"`
+}
+
+func datesOf(all []calls.Call) callDates {
+	var d callDates
+	for i, c := range all {
+		if i == 0 || c.Date < d.First {
+			d.First = c.Date
+		}
+		if i == 0 || c.Date > d.Last {
+			d.Last = c.Date
+		}
+	}
+	return d
+}
+
+// zoneKey tells loc apart from other time zones, and from itself before its
+// rules changed, by the names and offsets it gives between the dates d.
+func zoneKey(loc *time.Location, d callDates) string {
+	var first, last = calls.Call{Date: d.First}, calls.Call{Date: d.Last}
+	var b strings.Builder
+	var name, offset = first.Time().In(loc).Zone()
+	fmt.Fprintf(&b, "%s%+d", name, offset)
+	// zones change their offset at most a few times a year, so a daily
+	// sample finds every change
+	for t := first.Time(); ; t = t.Add(24 * time.Hour) {
+		if t.After(last.Time()) {
+			t = last.Time()
+		}
+		n, o := t.In(loc).Zone()
+		if n != name || o != offset {
+			name, offset = n, o
+			fmt.Fprintf(&b, ",%d:%s%+d", t.Unix(), name, offset)
+		}
+		if t.Equal(last.Time()) {
+			break
+		}
+	}
+	var sum = sha256.Sum256([]byte(b.String()))
+	return hex.EncodeToString(sum[:8])
+}
+
+// entryKey is the key of the entry key of a cache spanning dates, computed in
+// loc unless it is nil.
+func entryKey(key string, loc *time.Location, dates callDates) string {
+	if loc == nil {
+		return key
+	}
+	return key + ":" + zoneKey(loc, dates)
+}
+
+// StatsCacheFile returns the path of the statistics cache of the repository
+// at repoPath.
+func StatsCacheFile(repoPath string) string {
+	return filepath.Join(repoPath, "cache", "stats.json")
+}
+
+// readStatsCache decodes the entry key, computed in loc unless it is nil, into
+// v when the cache of repoPath was written for callsHash, and reports whether
+// it did.
+func readStatsCache(repoPath, callsHash, key string, loc *time.Location, v interface{}) bool {
+	data, err := os.ReadFile(StatsCacheFile(repoPath))
+	if err != nil {
+		return false
+	}
+	var cache statsCache
+	if json.Unmarshal(data, &cache) != nil || cache.CallsSHA256 != callsHash {
+		return false
+	}
+	entry, ok := cache.Entries[entryKey(key, loc, cache.Dates)]
+	if !ok {
+		return false
+	}
+	return json.Unmarshal(entry, v) == nil
+}
+
+// writeStatsCache stores v, computed in loc unless it is nil, as the entry key
+// of the cache of repoPath for calls spanning dates. Entries for other calls
+// hashes are dropped.
+func writeStatsCache(repoPath, callsHash string, dates callDates, key string, loc *time.Location, v interface{}) error {
+	entry, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	var cache statsCache
+	var filePath = StatsCacheFile(repoPath)
+	if data, err := os.ReadFile(filePath); err == nil {
+		_ = json.Unmarshal(data, &cache)
+	}
+	if cache.CallsSHA256 != callsHash || cache.Dates != dates || cache.Entries == nil {
+		cache = statsCache{CallsSHA256: callsHash, Dates: dates, Entries: map[string]json.RawMessage{}}
+	}
+	cache.Entries[entryKey(key, loc, cache.Dates)] = entry
+
+	data, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(filePath), 0755)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(filePath), ".stats-*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filePath)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
@@ -0,0 +1,103 @@
+package mobilecombackup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/phillipgreen/mobilecombackup/internal/test_support"
+	"github.com/phillipgreen/mobilecombackup/pkg/calls"
+)
+
+func TestStatsCache(t *testing.T) {
+	repoDir := t.TempDir()
+	var dates = callDates{First: 1388534400000, Last: 1420070400000}
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the same offsets as New York in winter, without daylight saving time
+	fixed := time.FixedZone("EST", -5*60*60)
+
+	var got int
+	if readStatsCache(repoDir, "abc", "calls", newYork, &got) {
+		t.Errorf("read a missing cache")
+	}
+	err = writeStatsCache(repoDir, "abc", dates, "calls", newYork, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !readStatsCache(repoDir, "abc", "calls", newYork, &got) || got != 7 {
+		t.Errorf("cached got %d, want 7", got)
+	}
+	if readStatsCache(repoDir, "abc", "coverage", nil, &got) {
+		t.Errorf("read a missing entry")
+	}
+	if readStatsCache(repoDir, "def", "calls", newYork, &got) {
+		t.Errorf("read an entry for other calls")
+	}
+	if readStatsCache(repoDir, "abc", "calls", fixed, &got) {
+		t.Errorf("read an entry computed in another time zone")
+	}
+}
+
+func TestCachedStatsReadOnly(t *testing.T) {
+	tmpdir := t.TempDir()
+	err := test_support.CopyDir("../../testdata", tmpdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repoDir := filepath.Join(tmpdir, "archive")
+	err = os.WriteFile(filepath.Join(repoDir, RepositoryConfigFile), []byte("read_only: true\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var n int
+	err = cachedStats(&statsConfig{repoPath: repoDir}, "count", nil, &n, func(all []calls.Call) {
+		n = len(all)
+	})
+	if err != nil || n != 16 {
+		t.Errorf("got %d, %v, want 16", n, err)
+	}
+	if _, err := os.Stat(StatsCacheFile(repoDir)); !os.IsNotExist(err) {
+		t.Errorf("read-only repository got a cache: %v", err)
+	}
+}
+
+func TestCachedStats(t *testing.T) {
+	tmpdir := t.TempDir()
+	err := test_support.CopyDir("../../testdata", tmpdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := &statsConfig{repoPath: filepath.Join(tmpdir, "archive")}
+
+	var computed int
+	run := func() int {
+		var n int
+		err := cachedStats(conf, "count", time.Local, &n, func(all []calls.Call) {
+			computed++
+			n = len(all)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	for i, want := range []int{1, 1} {
+		if n := run(); n != 16 {
+			t.Errorf("run %d got %d calls, want 16", i, n)
+		}
+		if computed != want {
+			t.Errorf("run %d computed %d times, want %d", i, computed, want)
+		}
+	}
+
+	conf.noCache = true
+	if n := run(); n != 16 || computed != 2 {
+		t.Errorf("no-cache got %d calls computed %d times, want 16 and 2", n, computed)
+	}
+}
@@ -0,0 +1,210 @@
+package mobilecombackup
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/phillipgreen/mobilecombackup/pkg/validation"
+)
+
+// validateFormats are the renderFormats and SARIF for code scanning tools.
+var validateFormats = append(append([]string{}, renderFormats...), "sarif")
+
+// failOnLevels lists the values of -fail-on, from strictest to most lenient.
+var failOnLevels = []string{"warning", "error", "never"}
+
+type validationConfig struct {
+	repoPath      string
+	format        string
+	metricsFile   string
+	verbose       bool
+	baseline      string
+	writeBaseline string
+	failOn        string
+	ignore        string
+}
+
+func parseValidateFlags(s *settings, progname string, args []string) (conf *validationConfig, output string, err error) {
+	flags := flag.NewFlagSet(progname, flag.ContinueOnError)
+	var buf bytes.Buffer
+	flags.SetOutput(&buf)
+
+	var c validationConfig
+	flags.StringVar(&c.repoPath, "repo", ".", "path which contains repository")
+	flags.StringVar(&c.format, "format", "table", "output format: "+strings.Join(validateFormats, "|"))
+	var outputFormat string
+	flags.StringVar(&outputFormat, "output-format", "", "deprecated, use -format")
+	flags.StringVar(&c.metricsFile, "metrics-file", "", "write validation metrics to this file in Prometheus textfile format")
+	flags.StringVar(&c.baseline, "baseline", "", "only report violations which are not in this baseline file")
+	flags.StringVar(&c.writeBaseline, "write-baseline", "", "record the current violations in this baseline file and succeed")
+	flags.StringVar(&c.failOn, "fail-on", "error", "lowest severity which fails validation: "+strings.Join(failOnLevels, "|"))
+	flags.StringVar(&c.ignore, "ignore", "", "comma separated violation types which are not reported")
+	flags.BoolVar(&c.verbose, "verbose", false, "report progress of each phase, even when not writing to a terminal")
+
+	err = parseArgs(s, flags, args)
+	if err != nil {
+		return nil, buf.String(), err
+	}
+	c.format = deprecatedFormat(c.format, outputFormat)
+	return &c, buf.String(), nil
+}
+
+// ignoredTypes returns the violation types of the -ignore flag value.
+func ignoredTypes(ignore string) ([]validation.ViolationType, error) {
+	var known = map[validation.ViolationType]bool{}
+	for _, r := range validation.Rules() {
+		known[r.Type] = true
+	}
+	var types []validation.ViolationType
+	for _, t := range strings.Split(ignore, ",") {
+		t = strings.TrimSpace(t)
+		if t == "" {
+			continue
+		}
+		if !known[validation.ViolationType(t)] {
+			return nil, fmt.Errorf("Unknown violation type %q", t)
+		}
+		types = append(types, validation.ViolationType(t))
+	}
+	return types, nil
+}
+
+// failures returns the number of violations which fail validation at the
+// failOn level.
+func failures(result validation.Result, failOn string) int {
+	switch failOn {
+	case "never":
+		return 0
+	case "warning":
+		return len(result.Violations)
+	default:
+		return result.Errors()
+	}
+}
+
+func validateValidationConfig(conf *validationConfig) error {
+	if conf.baseline != "" && conf.writeBaseline != "" {
+		return errors.New("Only one of -baseline or -write-baseline may be specified")
+	}
+	if _, err := ignoredTypes(conf.ignore); err != nil {
+		return err
+	}
+	var knownLevel bool
+	for _, l := range failOnLevels {
+		knownLevel = knownLevel || conf.failOn == l
+	}
+	if !knownLevel {
+		return fmt.Errorf("Unknown fail-on level %q, expected one of %s", conf.failOn, strings.Join(failOnLevels, ", "))
+	}
+	return checkFormat(conf.format, validateFormats)
+}
+
+func writeValidationResult(w io.Writer, conf *validationConfig, result validation.Result) error {
+	switch conf.format {
+	case "json", "yaml":
+		return writeStructured(w, conf.format, result)
+	case "sarif":
+		return writeStructured(w, "json", validation.ToSARIF(result, conf.repoPath))
+	default:
+		var rows = make([][]string, 0, len(result.Violations))
+		for _, v := range result.Violations {
+			var location = v.File
+			if v.Line > 0 {
+				location = fmt.Sprintf("%s:%d", v.File, v.Line)
+			}
+			rows = append(rows, []string{location, string(v.Severity), string(v.Type), v.Message})
+		}
+		return writeRows(w, conf.format, []string{"LOCATION", "SEVERITY", "TYPE", "MESSAGE"}, rows)
+	}
+}
+
+// renderValidationProgress writes p, such as "calls 3000/7000", rewriting
+// the line in place on a terminal.
+func renderValidationProgress(w io.Writer, p validation.Progress, terminal bool) {
+	var line = fmt.Sprintf("%s %d", p.Phase, p.Done)
+	if p.Total > 0 {
+		line = fmt.Sprintf("%s %d/%d", p.Phase, p.Done, p.Total)
+	}
+	if !terminal {
+		fmt.Fprintln(w, line)
+		return
+	}
+	fmt.Fprintf(w, "\r%s", line)
+	if p.Done >= p.Total {
+		fmt.Fprintln(w)
+	}
+}
+
+func runValidate(s *settings, progname string, args []string) (exitCode int, output *string, err error) {
+	conf, o, err := parseValidateFlags(s, progname, args)
+	if err == flag.ErrHelp {
+		return 4, nil, err
+	} else if err != nil {
+		return 3, &o, err
+	}
+
+	err = validateValidationConfig(conf)
+	if err != nil {
+		return 2, nil, err
+	}
+
+	var progress func(validation.Progress)
+	if terminal := isTerminal(os.Stderr); terminal || conf.verbose {
+		progress = func(p validation.Progress) {
+			renderValidationProgress(os.Stderr, p, terminal)
+		}
+	}
+
+	var started = time.Now()
+	result, err := validation.ValidateRepositoryWithProgress(conf.repoPath, progress)
+	if err == nil && conf.ignore != "" {
+		// the types were checked by validateValidationConfig
+		ignored, _ := ignoredTypes(conf.ignore)
+		result = result.Without(ignored...)
+	}
+	if err == nil && conf.baseline != "" {
+		var baseline validation.Baseline
+		baseline, err = validation.ReadBaseline(conf.baseline)
+		if err == nil {
+			var suppressed int
+			result, suppressed = baseline.Filter(result)
+			fmt.Fprintf(os.Stderr, "Suppressed %d violations in the baseline\n", suppressed)
+		}
+	}
+	if conf.metricsFile != "" {
+		merr := writeMetricsFile(conf.metricsFile, validationMetrics(result, err == nil && result.Errors() == 0, started))
+		if merr != nil && err == nil {
+			err = fmt.Errorf("Failed to write metrics: %w", merr)
+		}
+	}
+	if err != nil {
+		return 1, nil, err
+	}
+
+	err = writeValidationResult(os.Stdout, conf, result)
+	if err != nil {
+		return 1, nil, err
+	}
+
+	if conf.writeBaseline != "" {
+		err = validation.WriteBaseline(conf.writeBaseline, validation.NewBaseline(result))
+		if err != nil {
+			return 1, nil, err
+		}
+		fmt.Fprintf(os.Stderr, "Wrote %d violations to %s\n", len(result.Violations), conf.writeBaseline)
+		return 0, nil, nil
+	}
+
+	if n := failures(result, conf.failOn); n > 0 && conf.failOn == "warning" {
+		return 1, nil, fmt.Errorf("Found %d violations", n)
+	} else if n > 0 {
+		return 1, nil, fmt.Errorf("Found %d errors", n)
+	}
+	return 0, nil, nil
+}
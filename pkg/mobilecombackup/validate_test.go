@@ -0,0 +1,39 @@
+package mobilecombackup
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/phillipgreen/mobilecombackup/pkg/validation"
+)
+
+func TestFailures(t *testing.T) {
+	result := validation.Result{Violations: []validation.Violation{
+		{Type: validation.CountMismatch, Severity: validation.Error},
+		{Type: "orphaned-attachment", Severity: validation.Warning},
+	}}
+	var tests = []struct {
+		failOn string
+		want   int
+	}{
+		{"error", 1},
+		{"warning", 2},
+		{"never", 0},
+	}
+	for _, tt := range tests {
+		if got := failures(result, tt.failOn); got != tt.want {
+			t.Errorf("failures at %s got %d, want %d", tt.failOn, got, tt.want)
+		}
+	}
+}
+
+func TestIgnoredTypes(t *testing.T) {
+	got, err := ignoredTypes("count-mismatch, duplicate-record,")
+	want := []validation.ViolationType{validation.CountMismatch, validation.DuplicateRecord}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, err %v, want %v", got, err, want)
+	}
+	if _, err := ignoredTypes("count-mismatch,no-such-type"); err == nil {
+		t.Errorf("unknown type got nil err")
+	}
+}
@@ -0,0 +1,89 @@
+package mobilecombackup
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/phillipgreen/mobilecombackup/pkg/calls"
+	"github.com/phillipgreen/mobilecombackup/pkg/validation"
+)
+
+type verifyConfig struct {
+	repoPath string
+	quick    bool
+}
+
+func parseVerifyFlags(s *settings, progname string, args []string) (conf *verifyConfig, output string, err error) {
+	flags := flag.NewFlagSet(progname, flag.ContinueOnError)
+	var buf bytes.Buffer
+	flags.SetOutput(&buf)
+
+	var c verifyConfig
+	flags.StringVar(&c.repoPath, "repo", ".", "path which contains repository")
+	flags.BoolVar(&c.quick, "quick", false, "only compare the repository files with their recorded checksums")
+
+	err = parseArgs(s, flags, args)
+	if err != nil {
+		return nil, buf.String(), err
+	}
+	return &c, buf.String(), nil
+}
+
+// verifyRepository checks the repository files against their checksums and,
+// unless quick, validates the repository. It writes what it finds to w and
+// returns how many problems it found.
+func verifyRepository(w io.Writer, conf *verifyConfig) (int, error) {
+	var problems int
+	var callsFile = calls.RepositoryFile(conf.repoPath)
+	err := calls.VerifyChecksum(callsFile)
+	switch {
+	case err == nil:
+		fmt.Fprintf(w, "%s: OK\n", callsFile)
+	case errors.Is(err, os.ErrNotExist):
+		// repositories written before checksums were recorded have none
+		fmt.Fprintf(w, "%s: no checksum recorded\n", callsFile)
+	case errors.Is(err, calls.ErrChecksumMismatch):
+		fmt.Fprintf(w, "%s: FAILED: %v\n", callsFile, err)
+		problems++
+	default:
+		return problems, err
+	}
+	if conf.quick {
+		return problems, nil
+	}
+
+	result, err := validation.ValidateRepository(conf.repoPath)
+	if err != nil {
+		return problems, err
+	}
+	for _, v := range result.Violations {
+		if v.Severity == validation.Error {
+			fmt.Fprintf(w, "%s: [%s] %s: %s\n", v.File, v.Severity, v.Type, v.Message)
+			problems++
+		}
+	}
+	return problems, nil
+}
+
+func runVerify(s *settings, progname string, args []string) (exitCode int, output *string, err error) {
+	conf, o, err := parseVerifyFlags(s, progname, args)
+	if err == flag.ErrHelp {
+		return 4, nil, err
+	} else if err != nil {
+		return 3, &o, err
+	}
+
+	problems, err := verifyRepository(os.Stdout, conf)
+	if err != nil {
+		return 1, nil, err
+	}
+	if problems > 0 {
+		return 1, nil, fmt.Errorf("Found %d problems", problems)
+	}
+
+	return 0, nil, nil
+}
@@ -0,0 +1,124 @@
+package mobilecombackup
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/phillipgreen/mobilecombackup/pkg/calls"
+	"github.com/phillipgreen/mobilecombackup/pkg/phone"
+)
+
+type verifySourceConfig struct {
+	repoPath    string
+	region      string
+	sourcePaths []string
+}
+
+func parseVerifySourceFlags(s *settings, progname string, args []string) (conf *verifySourceConfig, output string, err error) {
+	flags := flag.NewFlagSet(progname, flag.ContinueOnError)
+	var buf bytes.Buffer
+	flags.SetOutput(&buf)
+
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage of %s [options] backup1.xml [... backupN.xml]:\n", progname)
+
+		flags.PrintDefaults()
+	}
+
+	var c verifySourceConfig
+	flags.StringVar(&c.repoPath, "repo", ".", "path which contains repository")
+	flags.StringVar(&c.region, "region", "US", "region used to normalize national phone numbers, as used for import")
+
+	err = parseArgs(s, flags, args)
+	if err != nil {
+		return nil, buf.String(), err
+	}
+	c.sourcePaths = flags.Args()
+	return &c, buf.String(), nil
+}
+
+func validateVerifySourceConfig(conf *verifySourceConfig) error {
+	if len(conf.sourcePaths) <= 0 {
+		return errors.New("Atleast one backup file must be specified")
+	}
+	if conf.region != "" && !phone.IsRegion(conf.region) {
+		return fmt.Errorf("Unknown region %q, expected one of %s", conf.region, strings.Join(phone.Regions(), ", "))
+	}
+	return nil
+}
+
+type sourceVerification struct {
+	Source  string
+	Checked int
+	Missing []calls.Call
+}
+
+// verifySources checks that every call of each source backup is stored in the
+// repository, comparing them the way import deduplicates them.
+func verifySources(repoPath, region string, sourcePaths []string) ([]sourceVerification, error) {
+	var stored = map[calls.Key]bool{}
+	err := calls.StreamCalls(calls.RepositoryFile(repoPath), func(c calls.Call) error {
+		stored[c.NormalizedKey(region)] = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var verifications []sourceVerification
+	for _, source := range sourcePaths {
+		var v = sourceVerification{Source: source}
+		err := calls.StreamCalls(source, func(c calls.Call) error {
+			v.Checked++
+			if !stored[c.NormalizedKey(region)] {
+				v.Missing = append(v.Missing, c)
+			}
+			return nil
+		})
+		if err != nil {
+			return verifications, fmt.Errorf("reading %s: %w", source, err)
+		}
+		verifications = append(verifications, v)
+	}
+	return verifications, nil
+}
+
+func writeSourceVerifications(w io.Writer, verifications []sourceVerification) (missing int) {
+	for _, v := range verifications {
+		fmt.Fprintf(w, "%s: %d of %d calls present\n", v.Source, v.Checked-len(v.Missing), v.Checked)
+		for _, c := range v.Missing {
+			fmt.Fprintf(w, "  missing: number=%s date=%d duration=%s type=%s\n", c.Number, c.Date, c.Duration, c.Type)
+		}
+		missing += len(v.Missing)
+	}
+	return missing
+}
+
+func runVerifySource(s *settings, progname string, args []string) (exitCode int, output *string, err error) {
+	conf, o, err := parseVerifySourceFlags(s, progname, args)
+	if err == flag.ErrHelp {
+		return 4, nil, err
+	} else if err != nil {
+		return 3, &o, err
+	}
+
+	err = validateVerifySourceConfig(conf)
+	if err != nil {
+		return 2, nil, err
+	}
+
+	verifications, err := verifySources(conf.repoPath, conf.region, conf.sourcePaths)
+	if err != nil {
+		return 1, nil, err
+	}
+
+	if missing := writeSourceVerifications(os.Stdout, verifications); missing > 0 {
+		return 1, nil, fmt.Errorf("%d calls are missing from the repository", missing)
+	}
+	return 0, nil, nil
+}
@@ -0,0 +1,43 @@
+package mobilecombackup
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/phillipgreen/mobilecombackup/internal/test_support"
+)
+
+func TestVerifySources(t *testing.T) {
+	tmpdir := t.TempDir()
+	err := test_support.CopyDir("../../testdata", tmpdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repoDir := filepath.Join(tmpdir, "archive")
+	source := filepath.Join(tmpdir, "to_process", "00", "calls-test.xml")
+
+	before, err := verifySources(repoDir, "US", []string{source})
+	if err != nil {
+		t.Fatalf("err got %v, want nil", err)
+	}
+	if before[0].Checked != 12 || len(before[0].Missing) == 0 {
+		t.Errorf("before import got %d checked, %d missing", before[0].Checked, len(before[0].Missing))
+	}
+
+	processor, err := Init(repoDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = processor.Process(filepath.Dir(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := verifySources(repoDir, "US", []string{source})
+	if err != nil {
+		t.Fatalf("err got %v, want nil", err)
+	}
+	if after[0].Checked != 12 || len(after[0].Missing) != 0 {
+		t.Errorf("after import got %d checked, missing %v", after[0].Checked, after[0].Missing)
+	}
+}
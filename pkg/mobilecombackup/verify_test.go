@@ -0,0 +1,62 @@
+package mobilecombackup
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/phillipgreen/mobilecombackup/internal/test_support"
+	"github.com/phillipgreen/mobilecombackup/pkg/calls"
+)
+
+func TestVerifyRepository(t *testing.T) {
+	tmpdir := t.TempDir()
+	err := test_support.CopyDir("../../testdata", tmpdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repoDir := filepath.Join(tmpdir, "archive")
+	callsFile := calls.RepositoryFile(repoDir)
+
+	var tests = []struct {
+		name     string
+		prepare  func() error
+		problems int
+		output   string
+	}{
+		{"without checksum", func() error { return nil }, 0, "no checksum recorded"},
+		{"after import", func() error {
+			repo, err := OpenRepository(repoDir)
+			if err != nil {
+				return err
+			}
+			_, err = repo.Import(context.Background(), Options{}, filepath.Join(tmpdir, "to_process"))
+			return err
+		}, 0, "OK"},
+		{"changed", func() error {
+			f, err := os.OpenFile(callsFile, os.O_APPEND|os.O_WRONLY, 0644)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			_, err = f.WriteString("\n")
+			return err
+		}, 1, "FAILED"},
+	}
+	for _, tt := range tests {
+		err := tt.prepare()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, quick := range []bool{true, false} {
+			var buf bytes.Buffer
+			problems, err := verifyRepository(&buf, &verifyConfig{repoPath: repoDir, quick: quick})
+			if err != nil || problems != tt.problems || !strings.Contains(buf.String(), tt.output) {
+				t.Errorf("%s quick=%v got %d problems, err %v, output %q, want %d and %q", tt.name, quick, problems, err, buf.String(), tt.problems, tt.output)
+			}
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package mobilecombackup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/phillipgreen/mobilecombackup/pkg/calls"
+	"github.com/phillipgreen/mobilecombackup/pkg/validation"
+)
+
+type watchConfig struct {
+	repoPath   string
+	interval   time.Duration
+	statusFile string
+	notify     string
+	once       bool
+	settings   *settings
+}
+
+func parseWatchFlags(s *settings, progname string, args []string) (conf *watchConfig, output string, err error) {
+	flags := flag.NewFlagSet(progname, flag.ContinueOnError)
+	var buf bytes.Buffer
+	flags.SetOutput(&buf)
+
+	var c = watchConfig{settings: s}
+	flags.StringVar(&c.repoPath, "repo", ".", "path which contains repository")
+	flags.DurationVar(&c.interval, "interval", 24*time.Hour, "time between checks")
+	flags.StringVar(&c.statusFile, "status-file", "", "file the result of the last check is written to (default cache/health.json in the repository)")
+	flags.StringVar(&c.notify, "notify", "", "shell command run with the new violations on its standard input when a check finds any")
+	flags.BoolVar(&c.once, "once", false, "check once and exit, such as when run from cron")
+
+	err = parseArgs(s, flags, args)
+	if err != nil {
+		return nil, buf.String(), err
+	}
+	if c.statusFile == "" {
+		c.statusFile = filepath.Join(c.repoPath, "cache", "health.json")
+	}
+	return &c, buf.String(), nil
+}
+
+func validateWatchConfig(conf *watchConfig) error {
+	if conf.interval < time.Minute {
+		return fmt.Errorf("Interval must be atleast 1m, got %v", conf.interval)
+	}
+	return nil
+}
+
+// inRepository reports whether filePath is within the repository at
+// repoPath.
+func inRepository(repoPath, filePath string) bool {
+	root, err := filepath.Abs(repoPath)
+	if err != nil {
+		return true
+	}
+	abs, err := filepath.Abs(filePath)
+	if err != nil {
+		return true
+	}
+	rel, err := filepath.Rel(root, abs)
+	return err != nil || rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// healthStatus is the result of a check, as written to the status file.
+type healthStatus struct {
+	CheckedAt     time.Time              `json:"checked_at"`
+	ChecksumOK    bool                   `json:"checksum_ok"`
+	Errors        int                    `json:"errors"`
+	Violations    []validation.Violation `json:"violations"`
+	NewViolations []validation.Violation `json:"new_violations"`
+}
+
+// checkHealth validates the repository and verifies its checksum, reporting
+// the violations which previous did not have. It runs the full validation
+// rather than a quick subset: that is a single streaming pass over the calls
+// file, as the checksum is, which is cheap at the intervals watch runs at even
+// on a NAS, and a subset would miss the duplicate and ordering violations
+// watch is meant to report.
+func checkHealth(repoPath string, previous healthStatus) (healthStatus, error) {
+	var status = healthStatus{CheckedAt: time.Now().UTC(), ChecksumOK: true}
+	err := calls.VerifyChecksum(calls.RepositoryFile(repoPath))
+	if errors.Is(err, calls.ErrChecksumMismatch) {
+		status.ChecksumOK = false
+	} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return previous, err
+	}
+
+	result, err := validation.ValidateRepository(repoPath)
+	if err != nil {
+		return previous, err
+	}
+	status.Violations = result.Violations
+	status.Errors = result.Errors()
+	fresh, _ := validation.NewBaseline(validation.Result{Violations: previous.Violations}).Filter(result)
+	status.NewViolations = fresh.Violations
+	return status, nil
+}
+
+func writeHealthStatus(filePath string, status healthStatus) error {
+	data, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(filePath), 0755)
+	if err != nil {
+		return err
+	}
+	var tmp = filePath + ".tmp"
+	err = os.WriteFile(tmp, append(data, '\n'), 0644)
+	if err == nil {
+		err = os.Rename(tmp, filePath)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+// noHealthStatus is the status compared with when no check was recorded, so
+// that every violation and a checksum mismatch are new.
+var noHealthStatus = healthStatus{ChecksumOK: true}
+
+// readHealthStatus returns the check recorded in the status file at
+// filePath, noHealthStatus when there is no record of one.
+func readHealthStatus(filePath string) (healthStatus, error) {
+	var status = noHealthStatus
+	data, err := os.ReadFile(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return noHealthStatus, nil
+	}
+	if err == nil {
+		err = json.Unmarshal(data, &status)
+	}
+	if err != nil {
+		return noHealthStatus, err
+	}
+	return status, nil
+}
+
+// notifyViolations runs command with the violations on its standard input.
+func notifyViolations(command string, violations []validation.Violation) error {
+	var input bytes.Buffer
+	err := writeValidationResult(&input, &validationConfig{format: "table"}, validation.Result{Violations: violations})
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdin = &input
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// watchOnce runs a check, notifies about new violations and the checksum
+// ceasing to match, and records the check. It returns the status the next
+// check compares with, which stays previous unless the check was recorded, so
+// that a notification which failed is sent again.
+func watchOnce(conf *watchConfig, previous healthStatus) (healthStatus, error) {
+	status, err := checkHealth(conf.repoPath, previous)
+	if err != nil {
+		return previous, err
+	}
+	log.Printf("Checked [%s]: %d violations, %d new, checksum ok: %v", conf.repoPath, len(status.Violations), len(status.NewViolations), status.ChecksumOK)
+
+	var mismatch = previous.ChecksumOK && !status.ChecksumOK
+	if conf.notify != "" && (len(status.NewViolations) > 0 || mismatch) {
+		var violations = status.NewViolations
+		if mismatch {
+			violations = append(violations, validation.Violation{
+				Severity: validation.Error,
+				File:     filepath.Base(calls.RepositoryFile(conf.repoPath)),
+				Message:  "checksum mismatch",
+			})
+		}
+		err = notifyViolations(conf.notify, violations)
+		if err != nil {
+			return previous, fmt.Errorf("Failed to notify: %w", err)
+		}
+	}
+
+	err = writeHealthStatus(conf.statusFile, status)
+	if err != nil {
+		return previous, err
+	}
+	return status, nil
+}
+
+func runWatch(s *settings, progname string, args []string) (exitCode int, output *string, err error) {
+	conf, o, err := parseWatchFlags(s, progname, args)
+	if err == flag.ErrHelp {
+		return 4, nil, err
+	} else if err != nil {
+		return 3, &o, err
+	}
+
+	err = validateWatchConfig(conf)
+	if err != nil {
+		return 2, nil, err
+	}
+	if inRepository(conf.repoPath, conf.statusFile) {
+		err = checkWritable(conf.settings, conf.repoPath)
+		if err != nil {
+			return 2, nil, fmt.Errorf("%w; give a -status-file outside of the repository", err)
+		}
+	}
+
+	// the first check compares with the last one recorded, so that runs
+	// from cron and restarts only report what is new
+	previous, err := readHealthStatus(conf.statusFile)
+	if err != nil {
+		log.Printf("Ignoring the status of the last check: %v", err)
+	}
+	previous, err = watchOnce(conf, previous)
+	if conf.once {
+		if err != nil {
+			return 1, nil, err
+		}
+		return 0, nil, nil
+	}
+	if err != nil {
+		log.Printf("Error checking [%s]: %v", conf.repoPath, err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ticker := time.NewTicker(conf.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return 0, nil, nil
+		case <-ticker.C:
+			previous, err = watchOnce(conf, previous)
+			if err != nil {
+				log.Printf("Error checking [%s]: %v", conf.repoPath, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package mobilecombackup
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/phillipgreen/mobilecombackup/internal/test_support"
+	"github.com/phillipgreen/mobilecombackup/pkg/calls"
+)
+
+func TestWatchOnce(t *testing.T) {
+	repoDir := t.TempDir()
+	err := os.WriteFile(filepath.Join(repoDir, "calls.xml"), []byte(`<calls count="2">
+  <call number="5555550013" duration="33" date="1388534400000" type="2" />
+</calls>`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	notified := filepath.Join(t.TempDir(), "notified")
+	conf := &watchConfig{
+		repoPath:   repoDir,
+		statusFile: filepath.Join(repoDir, "cache", "health.json"),
+		notify:     "cat > " + notified,
+	}
+
+	var previous = noHealthStatus
+	for i, wantNew := range []int{1, 0} {
+		os.Remove(notified)
+		previous, err = watchOnce(conf, previous)
+		if err != nil {
+			t.Fatalf("check %d err got %v, want nil", i, err)
+		}
+
+		var status healthStatus
+		data, err := os.ReadFile(conf.statusFile)
+		if err == nil {
+			err = json.Unmarshal(data, &status)
+		}
+		if err != nil || len(status.Violations) != 1 || len(status.NewViolations) != wantNew {
+			t.Errorf("check %d status got %+v, err %v, want 1 violation and %d new", i, status, err, wantNew)
+		}
+
+		message, err := os.ReadFile(notified)
+		if wantNew > 0 && (err != nil || !strings.Contains(string(message), "count-mismatch")) {
+			t.Errorf("check %d notification got %q, err %v, want the violation", i, message, err)
+		}
+		if wantNew == 0 && err == nil {
+			t.Errorf("check %d notified %q, want no notification", i, message)
+		}
+	}
+}
+
+func TestRunWatchOnceRemembersViolations(t *testing.T) {
+	repoDir := t.TempDir()
+	err := os.WriteFile(filepath.Join(repoDir, "calls.xml"), []byte(`<calls count="2">
+  <call number="5555550013" duration="33" date="1388534400000" type="2" />
+</calls>`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	notified := filepath.Join(t.TempDir(), "notified")
+
+	for i := 0; i < 2; i++ {
+		exitCode, _, err := Run([]string{"mobilecombackup", "watch", "-once", "-repo", repoDir, "-notify", "echo notified >> " + notified})
+		if exitCode != 0 || err != nil {
+			t.Fatalf("run %d got exit code %d, err %v", i, exitCode, err)
+		}
+	}
+	data, err := os.ReadFile(notified)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(data), "notified"); n != 1 {
+		t.Errorf("notifications got %d, want 1", n)
+	}
+}
+
+func TestWatchOnceChecksumMismatch(t *testing.T) {
+	tmpdir := t.TempDir()
+	err := test_support.CopyDir("../../testdata", tmpdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repoDir := filepath.Join(tmpdir, "archive")
+	err = os.WriteFile(calls.ChecksumFile(calls.RepositoryFile(repoDir)), []byte("0000  calls.xml\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	notified := filepath.Join(tmpdir, "notified")
+	conf := &watchConfig{
+		repoPath:   repoDir,
+		statusFile: filepath.Join(tmpdir, "health.json"),
+		notify:     "cat >> " + notified,
+	}
+
+	// the mismatch is reported when it appears, not on every check
+	var previous = noHealthStatus
+	for i := 0; i < 2; i++ {
+		previous, err = watchOnce(conf, previous)
+		if err != nil {
+			t.Fatalf("check %d err got %v, want nil", i, err)
+		}
+	}
+	data, err := os.ReadFile(notified)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(data), "checksum mismatch"); n != 1 {
+		t.Errorf("mismatch notifications got %d, want 1:\n%s", n, data)
+	}
+}
+
+func TestWatchOnceUnrecordedCheck(t *testing.T) {
+	repoDir := t.TempDir()
+	err := os.WriteFile(filepath.Join(repoDir, "calls.xml"), []byte(`<calls count="2">
+  <call number="5555550013" duration="33" date="1388534400000" type="2" />
+</calls>`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocked := filepath.Join(t.TempDir(), "blocked")
+	err = os.WriteFile(blocked, nil, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := &watchConfig{repoPath: repoDir, statusFile: filepath.Join(blocked, "health.json")}
+
+	// the violation is still new to the next check, as it was not recorded
+	previous, err := watchOnce(conf, noHealthStatus)
+	if err == nil {
+		t.Fatalf("err got nil, want the status file error")
+	}
+	if len(previous.Violations) != 0 {
+		t.Errorf("previous got %+v, want the status before the check", previous)
+	}
+}
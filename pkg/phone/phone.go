@@ -0,0 +1,119 @@
+package phone
+
+import (
+	"sort"
+	"strings"
+)
+
+type region struct {
+	callingCode string
+	// trunkPrefix is dialed before national numbers within the region
+	trunkPrefix string
+	// internationalPrefix is dialed before a calling code to leave the region
+	internationalPrefix string
+	// nationalLengths lists the accepted national number lengths, without
+	// trunk prefix; nil accepts any length of at least minNationalLength
+	nationalLengths []int
+}
+
+const minNationalLength = 7
+
+var regions = map[string]region{
+	"US": {"1", "1", "011", []int{10}},
+	"CA": {"1", "1", "011", []int{10}},
+	"GB": {"44", "0", "00", []int{9, 10}},
+	"IE": {"353", "0", "00", nil},
+	"DE": {"49", "0", "00", nil},
+	"FR": {"33", "0", "00", []int{9}},
+	"ES": {"34", "", "00", []int{9}},
+	"IT": {"39", "", "00", nil},
+	"NL": {"31", "0", "00", []int{9}},
+	"IN": {"91", "0", "00", []int{10}},
+	"AU": {"61", "0", "0011", []int{9}},
+	"NZ": {"64", "0", "00", nil},
+	"MX": {"52", "", "00", []int{10}},
+	"BR": {"55", "0", "00", []int{10, 11}},
+	"JP": {"81", "0", "010", []int{9, 10}},
+	"CN": {"86", "0", "00", nil},
+}
+
+// Regions returns the supported region codes.
+func Regions() []string {
+	var codes = make([]string, 0, len(regions))
+	for code := range regions {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// IsRegion reports whether code is a supported region.
+func IsRegion(code string) bool {
+	_, ok := regions[strings.ToUpper(code)]
+	return ok
+}
+
+func digitsOnly(s string) (string, bool) {
+	var sb strings.Builder
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			sb.WriteRune(r)
+		case r == ' ' || r == '-' || r == '.' || r == '(' || r == ')' || r == '/':
+			// formatting
+		default:
+			return "", false
+		}
+	}
+	return sb.String(), true
+}
+
+func (r region) acceptsNational(national string) bool {
+	if r.nationalLengths == nil {
+		return len(national) >= minNationalLength
+	}
+	for _, l := range r.nationalLengths {
+		if len(national) == l {
+			return true
+		}
+	}
+	return false
+}
+
+// Normalize returns number in E.164 form. Numbers without a calling code are
+// interpreted as national numbers of defaultRegion (an ISO 3166 code such as
+// "US" or "GB"). Numbers which cannot be normalized, such as short codes,
+// alphanumeric senders or national numbers with an unknown region, are
+// returned unchanged.
+func Normalize(number, defaultRegion string) string {
+	var trimmed = strings.TrimSpace(number)
+	var international = strings.HasPrefix(trimmed, "+")
+	digits, ok := digitsOnly(strings.TrimPrefix(trimmed, "+"))
+	if !ok || digits == "" {
+		return number
+	}
+	if international {
+		return "+" + digits
+	}
+
+	r, ok := regions[strings.ToUpper(defaultRegion)]
+	if !ok {
+		return number
+	}
+	if r.internationalPrefix != "" && strings.HasPrefix(digits, r.internationalPrefix) {
+		var rest = strings.TrimPrefix(digits, r.internationalPrefix)
+		if len(rest) >= minNationalLength {
+			return "+" + rest
+		}
+		return number
+	}
+
+	var national = digits
+	if r.trunkPrefix != "" && strings.HasPrefix(national, r.trunkPrefix) && !r.acceptsNational(national) {
+		national = strings.TrimPrefix(national, r.trunkPrefix)
+	}
+	if !r.acceptsNational(national) {
+		return number
+	}
+	return "+" + r.callingCode + national
+}
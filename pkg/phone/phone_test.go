@@ -0,0 +1,39 @@
+package phone
+
+import (
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	var tests = []struct {
+		number string
+		region string
+		want   string
+	}{
+		{"5555550013", "US", "+15555550013"},
+		{"15555550013", "US", "+15555550013"},
+		{"+15555550013", "US", "+15555550013"},
+		{"(555) 555-0013", "US", "+15555550013"},
+		{"011 44 20 7946 0958", "US", "+442079460958"},
+		{"+44 20 7946 0958", "US", "+442079460958"},
+		{"020 7946 0958", "GB", "+442079460958"},
+		{"0044 20 7946 0958", "GB", "+442079460958"},
+		{"09876543210", "IN", "+919876543210"},
+		{"9876543210", "in", "+919876543210"},
+		// short codes and alphanumeric senders are left alone
+		{"7535", "US", "7535"},
+		{"AT&T", "US", "AT&T"},
+		{"", "US", ""},
+		// national numbers need a known region
+		{"5555550013", "", "5555550013"},
+		{"5555550013", "ZZ", "5555550013"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.region+" "+tt.number, func(t *testing.T) {
+			if got := Normalize(tt.number, tt.region); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
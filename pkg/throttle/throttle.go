@@ -0,0 +1,84 @@
+// Package throttle limits the rate at which data is read, so that long
+// imports leave the disk to other programs.
+package throttle
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// maxChunk bounds a single read, so that waits stay short and readers
+// sharing a Limiter take turns.
+const maxChunk = 64 << 10
+
+// Limiter paces the bytes read through it to a rate shared by all of its
+// readers. A nil Limiter does not limit.
+type Limiter struct {
+	mu       sync.Mutex
+	rate     int64
+	next     time.Time
+	now      func() time.Time
+	sleep    func(time.Duration)
+	maxChunk int
+}
+
+// New returns a Limiter of bytesPerSecond, or nil when it is not positive.
+func New(bytesPerSecond int64) *Limiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	var chunk = maxChunk
+	if bytesPerSecond < int64(chunk) {
+		chunk = int(bytesPerSecond)
+	}
+	return &Limiter{
+		rate:     bytesPerSecond,
+		now:      time.Now,
+		sleep:    time.Sleep,
+		maxChunk: chunk,
+	}
+}
+
+// Wait accounts for n bytes read, blocking until the bytes read before them
+// are within the rate. Time not spent reading is not saved up, so a Limiter
+// never allows a burst.
+func (l *Limiter) Wait(n int) {
+	if l == nil || n <= 0 {
+		return
+	}
+	l.mu.Lock()
+	var now = l.now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	var delay = l.next.Sub(now)
+	l.next = l.next.Add(time.Duration(int64(n) * int64(time.Second) / l.rate))
+	l.mu.Unlock()
+
+	if delay > 0 {
+		l.sleep(delay)
+	}
+}
+
+type reader struct {
+	r io.Reader
+	l *Limiter
+}
+
+func (t *reader) Read(p []byte) (int, error) {
+	if len(p) > t.l.maxChunk {
+		p = p[:t.l.maxChunk]
+	}
+	n, err := t.r.Read(p)
+	t.l.Wait(n)
+	return n, err
+}
+
+// Reader returns r read at the rate of l, or r itself when l is nil.
+func Reader(r io.Reader, l *Limiter) io.Reader {
+	if l == nil {
+		return r
+	}
+	return &reader{r, l}
+}
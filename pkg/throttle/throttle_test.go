@@ -0,0 +1,54 @@
+package throttle
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestReader(t *testing.T) {
+	tests := []struct {
+		name      string
+		rate      int64
+		size      int
+		wantSlept time.Duration
+	}{
+		{"two seconds", 1000, 2000, 2 * time.Second},
+		{"chunked", 100 << 10, 300 << 10, 3 * time.Second},
+		{"small", 1000, 500, 500 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var now = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			var slept time.Duration
+			var l = New(tt.rate)
+			l.now = func() time.Time { return now }
+			l.sleep = func(d time.Duration) {
+				slept += d
+				now = now.Add(d)
+			}
+
+			n, err := io.Copy(io.Discard, Reader(bytes.NewReader(make([]byte, tt.size)), l))
+			if err != nil || n != int64(tt.size) {
+				t.Fatalf("copied %d, %v, want %d", n, err, tt.size)
+			}
+			// the last chunk is read without waiting for it
+			if slept > tt.wantSlept || slept < tt.wantSlept-time.Second {
+				t.Errorf("slept %v, want about %v", slept, tt.wantSlept)
+			}
+		})
+	}
+}
+
+func TestNilLimiter(t *testing.T) {
+	var l = New(0)
+	if l != nil {
+		t.Fatalf("New(0) got %v, want nil", l)
+	}
+	l.Wait(100)
+	var r = bytes.NewReader(nil)
+	if Reader(r, l) != io.Reader(r) {
+		t.Errorf("nil limiter wrapped the reader")
+	}
+}
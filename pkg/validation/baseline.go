@@ -0,0 +1,96 @@
+package validation
+
+import (
+	"os"
+	"regexp"
+
+	"gopkg.in/yaml.v3"
+)
+
+// BaselineEntry identifies a known violation. Violations about a record are
+// identified by its hash, and others by their message without line numbers,
+// so that entries still match after records are added above them.
+type BaselineEntry struct {
+	Type    ViolationType `yaml:"type"`
+	File    string        `yaml:"file"`
+	Record  string        `yaml:"record,omitempty"`
+	Message string        `yaml:"message,omitempty"`
+}
+
+// Baseline lists violations which are known and should not fail validation.
+type Baseline struct {
+	Violations []BaselineEntry `yaml:"violations"`
+}
+
+// lineNumbers matches the line numbers in violation messages, such as those
+// of XML syntax errors.
+var lineNumbers = regexp.MustCompile(`\bline \d+`)
+
+func baselineEntry(v Violation) BaselineEntry {
+	if v.Record != "" {
+		return BaselineEntry{Type: v.Type, File: v.File, Record: v.Record}
+	}
+	return messageEntry(v)
+}
+
+// messageEntry identifies v by its message, as baselines written before
+// violations had records did.
+func messageEntry(v Violation) BaselineEntry {
+	return BaselineEntry{Type: v.Type, File: v.File, Message: lineNumbers.ReplaceAllString(v.Message, "line")}
+}
+
+// NewBaseline returns the baseline accepting every violation of result.
+func NewBaseline(result Result) Baseline {
+	var b = Baseline{Violations: []BaselineEntry{}}
+	for _, v := range result.Violations {
+		b.Violations = append(b.Violations, baselineEntry(v))
+	}
+	return b
+}
+
+// Filter returns result without the violations in the baseline, and how many
+// were removed. Each entry accepts one violation, so a violation which
+// occurs more often than in the baseline is still reported.
+func (b Baseline) Filter(result Result) (Result, int) {
+	var known = map[BaselineEntry]int{}
+	for _, e := range b.Violations {
+		e.Message = lineNumbers.ReplaceAllString(e.Message, "line")
+		known[e]++
+	}
+
+	var filtered = Result{Violations: []Violation{}, Summary: result.Summary}
+	var suppressed int
+	for _, v := range result.Violations {
+		var e = baselineEntry(v)
+		if known[e] == 0 && v.Record != "" {
+			e = messageEntry(v)
+		}
+		if known[e] > 0 {
+			known[e]--
+			suppressed++
+			continue
+		}
+		filtered.Violations = append(filtered.Violations, v)
+	}
+	return filtered, suppressed
+}
+
+// ReadBaseline loads the baseline file at filePath.
+func ReadBaseline(filePath string) (Baseline, error) {
+	var b Baseline
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return b, err
+	}
+	err = yaml.Unmarshal(content, &b)
+	return b, err
+}
+
+// WriteBaseline writes b to filePath.
+func WriteBaseline(filePath string, b Baseline) error {
+	out, err := yaml.Marshal(b)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, out, 0644)
+}
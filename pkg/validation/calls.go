@@ -0,0 +1,167 @@
+package validation
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/phillipgreen/mobilecombackup/pkg/calls"
+)
+
+// lineReader records the offset of every newline it reads so that decoder
+// offsets can be turned into line numbers.
+type lineReader struct {
+	r        io.Reader
+	offset   int64
+	newlines []int64
+}
+
+func (lr *lineReader) Read(p []byte) (int, error) {
+	n, err := lr.r.Read(p)
+	for i := 0; i < n; i++ {
+		if p[i] == '\n' {
+			lr.newlines = append(lr.newlines, lr.offset+int64(i))
+		}
+	}
+	lr.offset += int64(n)
+	return n, err
+}
+
+func (lr *lineReader) line(offset int64) int {
+	return sort.Search(len(lr.newlines), func(i int) bool { return lr.newlines[i] >= offset }) + 1
+}
+
+// callViolation is NewViolation for a violation about call.
+func callViolation(t ViolationType, file string, line int, call *calls.Call, message string) Violation {
+	var v = NewViolation(t, file, line, message)
+	v.Record = call.Hash()
+	return v
+}
+
+// progressInterval is how many calls are checked between progress reports.
+const progressInterval = 1000
+
+// validateCalls checks the calls file, calling progress, when set, as calls
+// are checked. The calls which could be read are counted in summary.
+func validateCalls(rootDir string, progress func(Progress), summary *Summary) ([]Violation, error) {
+	var path = calls.RepositoryFile(rootDir)
+	file, err := filepath.Rel(rootDir, path)
+	if err != nil {
+		return nil, err
+	}
+
+	xmlFile, err := calls.Open(path)
+	if os.IsNotExist(err) {
+		return []Violation{NewViolation(MissingFile, file, 0, "calls file does not exist")}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer xmlFile.Close()
+
+	var total int
+	if progress != nil {
+		// the total only scales progress; a file which cannot be counted is
+		// reported by the checks below
+		total, _ = calls.CountCalls(path)
+	}
+	var report = func(done int) {
+		if progress != nil {
+			progress(Progress{CallsPhase, done, total})
+		}
+	}
+
+	var violations []Violation
+	var lr = lineReader{r: xmlFile}
+	var decoder = xml.NewDecoder(&lr)
+	var declared = -1
+	var count int
+	var previousDate int
+	var seen = map[calls.Key]int{}
+	for {
+		var start = decoder.InputOffset()
+		t, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			violations = append(violations, NewViolation(MalformedXML, file, lr.line(decoder.InputOffset()), err.Error()))
+			return violations, nil
+		}
+
+		se, ok := t.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		var line = lr.line(start)
+		switch se.Name.Local {
+		case "calls":
+			for _, a := range se.Attr {
+				if a.Name.Local == "count" {
+					declared, err = strconv.Atoi(a.Value)
+					if err != nil {
+						violations = append(violations, NewViolation(CountMismatch, file, line,
+							fmt.Sprintf("count attribute %q is not a number", a.Value)))
+					}
+				}
+			}
+		case "call":
+			var call calls.Call
+			err = decoder.DecodeElement(&call, &se)
+			if _, ok := err.(*xml.SyntaxError); ok {
+				// the decoder cannot continue past a syntax error
+				violations = append(violations, NewViolation(MalformedXML, file, lr.line(decoder.InputOffset()), err.Error()))
+				return violations, nil
+			}
+			if err != nil {
+				violations = append(violations, NewViolation(MalformedXML, file, line, err.Error()))
+				continue
+			}
+			count++
+			summary.addCall(&call)
+			if count%progressInterval == 0 {
+				report(count)
+			}
+
+			if call.Date <= 0 {
+				violations = append(violations, callViolation(InvalidDate, file, line, &call,
+					fmt.Sprintf("call with %s has date %d", call.Number, call.Date)))
+			} else if call.Date < previousDate {
+				violations = append(violations, callViolation(UnsortedRecords, file, line, &call,
+					fmt.Sprintf("call at %d is before the preceding call at %d", call.Date, previousDate)))
+			}
+			if call.Date > previousDate {
+				previousDate = call.Date
+			}
+
+			if d, err := strconv.Atoi(call.Duration); err != nil || d < 0 {
+				violations = append(violations, callViolation(InvalidDuration, file, line, &call,
+					fmt.Sprintf("call with %s has duration %q", call.Number, call.Duration)))
+			}
+
+			if !calls.KnownType(call.Type) {
+				violations = append(violations, callViolation(UnknownCallType, file, line, &call,
+					fmt.Sprintf("call with %s has type %q", call.Number, call.Type)))
+			}
+
+			if firstLine, ok := seen[call.Key()]; ok {
+				violations = append(violations, callViolation(DuplicateRecord, file, line, &call,
+					fmt.Sprintf("call duplicates the call on line %d", firstLine)))
+			} else {
+				seen[call.Key()] = line
+			}
+		}
+	}
+
+	report(count)
+
+	if declared >= 0 && declared != count {
+		violations = append(violations, NewViolation(CountMismatch, file, 0,
+			fmt.Sprintf("count attribute is %d but file contains %d calls", declared, count)))
+	}
+	return violations, nil
+}
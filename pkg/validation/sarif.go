@@ -0,0 +1,128 @@
+package validation
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// The subset of SARIF 2.1.0 needed to report violations.
+
+type SARIFLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []SARIFRun `json:"runs"`
+}
+
+type SARIFRun struct {
+	Tool               SARIFTool                   `json:"tool"`
+	OriginalURIBaseIDs map[string]SARIFArtifactLoc `json:"originalUriBaseIds,omitempty"`
+	Results            []SARIFResult               `json:"results"`
+}
+
+type SARIFTool struct {
+	Driver SARIFDriver `json:"driver"`
+}
+
+type SARIFDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []SARIFRule `json:"rules"`
+}
+
+type SARIFMessage struct {
+	Text string `json:"text"`
+}
+
+type SARIFRule struct {
+	ID                   string             `json:"id"`
+	ShortDescription     SARIFMessage       `json:"shortDescription"`
+	DefaultConfiguration SARIFConfiguration `json:"defaultConfiguration"`
+}
+
+type SARIFConfiguration struct {
+	Level string `json:"level"`
+}
+
+type SARIFResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   SARIFMessage    `json:"message"`
+	Locations []SARIFLocation `json:"locations"`
+}
+
+type SARIFLocation struct {
+	PhysicalLocation SARIFPhysicalLocation `json:"physicalLocation"`
+}
+
+type SARIFPhysicalLocation struct {
+	ArtifactLocation SARIFArtifactLoc `json:"artifactLocation"`
+	Region           *SARIFRegion     `json:"region,omitempty"`
+}
+
+type SARIFArtifactLoc struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+type SARIFRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+const repoRootBaseID = "REPOROOT"
+
+func sarifLevel(s Severity) string {
+	if s == Warning {
+		return "warning"
+	}
+	return "error"
+}
+
+// ToSARIF converts result into a SARIF log whose result locations are
+// relative to the repository at rootDir.
+func ToSARIF(result Result, rootDir string) SARIFLog {
+	var driver = SARIFDriver{
+		Name:           "mobilecombackup",
+		InformationURI: "https://github.com/phillipgreenii/mobilecombackup",
+		Rules:          []SARIFRule{},
+	}
+	for _, r := range Rules() {
+		driver.Rules = append(driver.Rules, SARIFRule{
+			ID:                   string(r.Type),
+			ShortDescription:     SARIFMessage{r.Description},
+			DefaultConfiguration: SARIFConfiguration{sarifLevel(r.Severity)},
+		})
+	}
+
+	var run = SARIFRun{Tool: SARIFTool{driver}, Results: []SARIFResult{}}
+	if abs, err := filepath.Abs(rootDir); err == nil {
+		var uri = "file://" + filepath.ToSlash(abs)
+		if !strings.HasPrefix(uri, "file:///") {
+			// windows drive letters need the extra slash
+			uri = "file:///" + strings.TrimPrefix(uri, "file://")
+		}
+		run.OriginalURIBaseIDs = map[string]SARIFArtifactLoc{
+			repoRootBaseID: {URI: strings.TrimSuffix(uri, "/") + "/"},
+		}
+	}
+
+	for _, v := range result.Violations {
+		var location = SARIFPhysicalLocation{
+			ArtifactLocation: SARIFArtifactLoc{URI: filepath.ToSlash(v.File), URIBaseID: repoRootBaseID},
+		}
+		if v.Line > 0 {
+			location.Region = &SARIFRegion{StartLine: v.Line}
+		}
+		run.Results = append(run.Results, SARIFResult{
+			RuleID:    string(v.Type),
+			Level:     sarifLevel(v.Severity),
+			Message:   SARIFMessage{v.Message},
+			Locations: []SARIFLocation{{location}},
+		})
+	}
+
+	return SARIFLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []SARIFRun{run},
+	}
+}
@@ -0,0 +1,70 @@
+package validation
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/phillipgreen/mobilecombackup/pkg/calls"
+)
+
+// Summary is the state of a repository, so that a validation result is a
+// complete description of it.
+type Summary struct {
+	Calls int `json:"calls"`
+	// Years lists the years with calls, in UTC, and CallsByYear how many
+	// calls each has.
+	Years       []int       `json:"years"`
+	CallsByYear map[int]int `json:"calls_by_year"`
+	// ProvenanceEntries counts the calls with a recorded provenance.
+	ProvenanceEntries int `json:"provenance_entries"`
+	// Files and Bytes measure everything stored in the repository.
+	Files int   `json:"files"`
+	Bytes int64 `json:"bytes"`
+}
+
+func newSummary() *Summary {
+	return &Summary{Years: []int{}, CallsByYear: map[int]int{}}
+}
+
+func (s *Summary) addCall(call *calls.Call) {
+	s.Calls++
+	if call.Date <= 0 {
+		return
+	}
+	var year = call.Time().UTC().Year()
+	if s.CallsByYear[year] == 0 {
+		s.Years = append(s.Years, year)
+		sort.Ints(s.Years)
+	}
+	s.CallsByYear[year]++
+}
+
+// addFiles measures the files of the repository at rootDir and counts its
+// provenance entries. A provenance store which cannot be read is left
+// uncounted.
+func (s *Summary) addFiles(rootDir string) error {
+	err := filepath.Walk(rootDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			s.Files++
+			s.Bytes += info.Size()
+		}
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	var entries int
+	err = calls.StreamProvenance(calls.ProvenanceFile(rootDir), func(calls.Provenance) error {
+		entries++
+		return nil
+	})
+	if err == nil {
+		s.ProvenanceEntries = entries
+	}
+	return nil
+}
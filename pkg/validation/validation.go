@@ -0,0 +1,204 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+type ViolationType string
+
+const (
+	MissingFile     ViolationType = "missing-file"
+	MalformedXML    ViolationType = "malformed-xml"
+	CountMismatch   ViolationType = "count-mismatch"
+	UnsortedRecords ViolationType = "unsorted-records"
+	DuplicateRecord ViolationType = "duplicate-record"
+	InvalidDate     ViolationType = "invalid-date"
+	InvalidDuration ViolationType = "invalid-duration"
+	UnknownCallType ViolationType = "unknown-call-type"
+)
+
+type Severity string
+
+const (
+	Error   Severity = "error"
+	Warning Severity = "warning"
+)
+
+// Rule describes a ViolationType.
+type Rule struct {
+	Type        ViolationType
+	Severity    Severity
+	Description string
+}
+
+var rules = map[ViolationType]Rule{
+	MissingFile:     {MissingFile, Error, "A file required by the repository does not exist."},
+	MalformedXML:    {MalformedXML, Error, "A repository file is not well-formed XML."},
+	CountMismatch:   {CountMismatch, Error, "The count attribute does not match the number of records."},
+	UnsortedRecords: {UnsortedRecords, Warning, "Records are not ordered by date."},
+	DuplicateRecord: {DuplicateRecord, Error, "The same record is stored more than once."},
+	InvalidDate:     {InvalidDate, Error, "A record has a missing or non-positive date."},
+	InvalidDuration: {InvalidDuration, Warning, "A call duration is not a non-negative number of seconds."},
+	UnknownCallType: {UnknownCallType, Warning, "A call has a type which is not recognized."},
+}
+
+// Validator checks the repository at rootDir and returns the violations it
+// finds. The error is only for problems which stop the check from running.
+type Validator func(rootDir string) ([]Violation, error)
+
+var (
+	// registry guards rules and validators once RegisterValidator may run
+	registry   sync.RWMutex
+	validators []Validator
+)
+
+// ErrInvalidRule is returned by RegisterValidator for rules which cannot be
+// registered.
+var ErrInvalidRule = errors.New("invalid rule")
+
+// RegisterValidator adds v to the checks run by ValidateRepository, after the
+// built in calls checks. The rules describe the ViolationTypes v reports; their types
+// must not already be registered.
+func RegisterValidator(v Validator, newRules ...Rule) error {
+	registry.Lock()
+	defer registry.Unlock()
+
+	for _, r := range newRules {
+		if r.Type == "" {
+			return fmt.Errorf("%w: rule %q has no type", ErrInvalidRule, r.Description)
+		}
+		if _, ok := rules[r.Type]; ok {
+			return fmt.Errorf("%w: rule %q is already registered", ErrInvalidRule, r.Type)
+		}
+		if r.Severity != Error && r.Severity != Warning {
+			return fmt.Errorf("%w: rule %q has unknown severity %q", ErrInvalidRule, r.Type, r.Severity)
+		}
+	}
+	for _, r := range newRules {
+		rules[r.Type] = r
+	}
+	validators = append(validators, v)
+	return nil
+}
+
+// Rules returns every rule, ordered by ViolationType.
+func Rules() []Rule {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	var all = make([]Rule, 0, len(rules))
+	for _, r := range rules {
+		all = append(all, r)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Type < all[j].Type })
+	return all
+}
+
+// Violation is a single problem found in the repository. File is relative to
+// the repository root; Line is 0 when the problem concerns the whole file.
+type Violation struct {
+	Type     ViolationType `json:"type"`
+	Severity Severity      `json:"severity"`
+	File     string        `json:"file"`
+	Line     int           `json:"line,omitempty"`
+	Message  string        `json:"message"`
+	// Record is the Hash of the call the violation is about, when it could
+	// be read.
+	Record string `json:"record,omitempty"`
+}
+
+// NewViolation returns a Violation of type t with the severity of its rule.
+func NewViolation(t ViolationType, file string, line int, message string) Violation {
+	registry.RLock()
+	defer registry.RUnlock()
+	return Violation{Type: t, Severity: rules[t].Severity, File: file, Line: line, Message: message}
+}
+
+type Result struct {
+	Violations []Violation `json:"violations"`
+	// Summary describes the repository validated, as far as it could be
+	// read.
+	Summary *Summary `json:"summary,omitempty"`
+}
+
+// Errors returns the number of violations with Error severity.
+func (r Result) Errors() int {
+	var n int
+	for _, v := range r.Violations {
+		if v.Severity == Error {
+			n++
+		}
+	}
+	return n
+}
+
+// Without returns r without the violations of the given types.
+func (r Result) Without(types ...ViolationType) Result {
+	var ignored = map[ViolationType]bool{}
+	for _, t := range types {
+		ignored[t] = true
+	}
+	var kept = Result{Violations: []Violation{}, Summary: r.Summary}
+	for _, v := range r.Violations {
+		if !ignored[v.Type] {
+			kept.Violations = append(kept.Violations, v)
+		}
+	}
+	return kept
+}
+
+// Phases of validation, as reported in Progress.
+const (
+	// CallsPhase counts the calls checked.
+	CallsPhase = "calls"
+	// ValidatorsPhase counts the registered validators run.
+	ValidatorsPhase = "validators"
+)
+
+// Progress reports how far a phase of validation has advanced. Total is 0
+// when it is not known.
+type Progress struct {
+	Phase string
+	Done  int
+	Total int
+}
+
+// ValidateRepository checks the repository at rootDir. The returned error is
+// only set when validation itself could not run; problems with the repository
+// are reported as violations.
+func ValidateRepository(rootDir string) (Result, error) {
+	return ValidateRepositoryWithProgress(rootDir, nil)
+}
+
+// ValidateRepositoryWithProgress is ValidateRepository which calls progress,
+// when set, as each phase advances and once when it completes.
+func ValidateRepositoryWithProgress(rootDir string, progress func(Progress)) (Result, error) {
+	var result = Result{Violations: []Violation{}, Summary: newSummary()}
+
+	violations, err := validateCalls(rootDir, progress, result.Summary)
+	if err != nil {
+		return result, err
+	}
+	result.Violations = append(result.Violations, violations...)
+
+	registry.RLock()
+	var extra = append([]Validator(nil), validators...)
+	registry.RUnlock()
+
+	for i, v := range extra {
+		violations, err := v(rootDir)
+		if err != nil {
+			return result, err
+		}
+		result.Violations = append(result.Violations, violations...)
+		if progress != nil {
+			progress(Progress{ValidatorsPhase, i + 1, len(extra)})
+		}
+	}
+
+	err = result.Summary.addFiles(rootDir)
+	return result, err
+}
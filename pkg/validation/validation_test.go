@@ -0,0 +1,294 @@
+package validation
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeCalls(t *testing.T, content string) string {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "calls.xml"), []byte(content), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func violationTypes(r Result) []ViolationType {
+	var types = []ViolationType{}
+	for _, v := range r.Violations {
+		types = append(types, v.Type)
+	}
+	return types
+}
+
+func TestValidateRepositoryArchive(t *testing.T) {
+	result, err := ValidateRepository("../../testdata/archive")
+	if err != nil {
+		t.Fatalf("err got %v, want nil", err)
+	}
+	if len(result.Violations) != 0 {
+		t.Errorf("violations got %v, want none", result.Violations)
+	}
+}
+
+func TestValidateRepositorySummary(t *testing.T) {
+	// 2014-09-16 and two calls on 2015-01-01
+	dir := writeCalls(t, `<calls count="3">
+  <call number="1" duration="0" date="1410881505425" type="3" />
+  <call number="2" duration="0" date="1420070400000" type="1" />
+  <call number="3" duration="0" date="1420070400001" type="1" />
+</calls>
+`)
+	result, err := ValidateRepository(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want = Summary{
+		Calls:       3,
+		Years:       []int{2014, 2015},
+		CallsByYear: map[int]int{2014: 1, 2015: 2},
+		Files:       1,
+		Bytes:       result.Summary.Bytes,
+	}
+	if result.Summary == nil || !reflect.DeepEqual(*result.Summary, want) {
+		t.Errorf("summary got %+v, want %+v", result.Summary, want)
+	}
+	if result.Summary.Bytes <= 0 {
+		t.Errorf("bytes got %d, want the size of calls.xml", result.Summary.Bytes)
+	}
+	if kept := result.Without(UnsortedRecords); kept.Summary != result.Summary {
+		t.Errorf("Without dropped the summary")
+	}
+}
+
+func TestValidateRepositoryViolations(t *testing.T) {
+	var tests = []struct {
+		desc  string
+		calls string
+		want  []ViolationType
+		lines []int
+	}{
+		{"count mismatch",
+			`<calls count="2">
+  <call number="1" duration="0" date="10" type="1" />
+</calls>`,
+			[]ViolationType{CountMismatch}, []int{0}},
+		{"unsorted, duplicate and invalid values",
+			`<calls count="4">
+  <call number="1" duration="0" date="20" type="1" />
+  <call number="1" duration="0" date="10" type="1" />
+  <call number="1" duration="0" date="20" type="1" />
+  <call number="2" duration="x" date="0" type="1" />
+</calls>`,
+			[]ViolationType{UnsortedRecords, DuplicateRecord, InvalidDate, InvalidDuration}, []int{3, 4, 5, 5}},
+		{"malformed xml",
+			`<calls count="1">
+  <call number="1" duration="0" date="10" type="1">
+</calls>`,
+			[]ViolationType{MalformedXML}, []int{3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			result, err := ValidateRepository(writeCalls(t, tt.calls))
+			if err != nil {
+				t.Fatalf("err got %v, want nil", err)
+			}
+			if got := violationTypes(result); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("violations got %v, want %v", result.Violations, tt.want)
+			}
+			for i, v := range result.Violations {
+				if v.Line != tt.lines[i] {
+					t.Errorf("line of %s got %d, want %d", v.Type, v.Line, tt.lines[i])
+				}
+			}
+		})
+	}
+}
+
+func TestValidateRepositoryMissingFile(t *testing.T) {
+	result, err := ValidateRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("err got %v, want nil", err)
+	}
+	if got := violationTypes(result); !reflect.DeepEqual(got, []ViolationType{MissingFile}) {
+		t.Errorf("violations got %v, want missing-file", result.Violations)
+	}
+	if result.Errors() != 1 {
+		t.Errorf("errors got %d, want 1", result.Errors())
+	}
+}
+
+func TestToSARIF(t *testing.T) {
+	result := Result{Violations: []Violation{
+		NewViolation(DuplicateRecord, "calls.xml", 7, "dup"),
+		NewViolation(CountMismatch, "calls.xml", 0, "count"),
+	}}
+
+	log := ToSARIF(result, "/repo")
+
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("log got %+v", log)
+	}
+	run := log.Runs[0]
+	if len(run.Tool.Driver.Rules) != len(Rules()) {
+		t.Errorf("rules got %d, want %d", len(run.Tool.Driver.Rules), len(Rules()))
+	}
+	if run.OriginalURIBaseIDs[repoRootBaseID].URI != "file:///repo/" {
+		t.Errorf("base uri got %q", run.OriginalURIBaseIDs[repoRootBaseID].URI)
+	}
+	if len(run.Results) != 2 {
+		t.Fatalf("results got %d, want 2", len(run.Results))
+	}
+	first := run.Results[0]
+	if first.RuleID != "duplicate-record" || first.Level != "error" {
+		t.Errorf("result got %+v", first)
+	}
+	if first.Locations[0].PhysicalLocation.Region.StartLine != 7 {
+		t.Errorf("region got %+v", first.Locations[0].PhysicalLocation.Region)
+	}
+	if run.Results[1].Locations[0].PhysicalLocation.Region != nil {
+		t.Errorf("region got %+v, want nil", run.Results[1].Locations[0].PhysicalLocation.Region)
+	}
+}
+
+func TestRegisterValidator(t *testing.T) {
+	const policy ViolationType = "test-contact-policy"
+	var builtin = validators
+	t.Cleanup(func() {
+		registry.Lock()
+		defer registry.Unlock()
+		validators = builtin
+		delete(rules, policy)
+	})
+
+	err := RegisterValidator(func(rootDir string) ([]Violation, error) {
+		return []Violation{NewViolation(policy, "calls.xml", 0, "policy broken")}, nil
+	}, Rule{policy, Warning, "A test policy."})
+	if err != nil {
+		t.Fatalf("err got %v, want nil", err)
+	}
+
+	err = RegisterValidator(func(string) ([]Violation, error) { return nil, nil }, Rule{policy, Error, "Again."})
+	if err == nil {
+		t.Errorf("registering %q twice got nil err", policy)
+	}
+
+	result, err := ValidateRepository("../../testdata/archive")
+	if err != nil {
+		t.Fatalf("err got %v, want nil", err)
+	}
+	var want = []Violation{{policy, Warning, "calls.xml", 0, "policy broken", ""}}
+	if !reflect.DeepEqual(result.Violations, want) {
+		t.Errorf("violations got %v, want %v", result.Violations, want)
+	}
+
+	var listed bool
+	for _, r := range Rules() {
+		listed = listed || r.Type == policy
+	}
+	if !listed {
+		t.Errorf("rules do not list %q", policy)
+	}
+}
+
+func TestValidateRepositoryWithProgress(t *testing.T) {
+	var reports []Progress
+	_, err := ValidateRepositoryWithProgress("../../testdata/archive", func(p Progress) {
+		reports = append(reports, p)
+	})
+	if err != nil {
+		t.Fatalf("err got %v, want nil", err)
+	}
+	var want = []Progress{{CallsPhase, 16, 16}}
+	if !reflect.DeepEqual(reports, want) {
+		t.Errorf("progress got %v, want %v", reports, want)
+	}
+}
+
+func TestBaseline(t *testing.T) {
+	dir := writeCalls(t, `<calls count="3">
+  <call number="1" duration="0" date="1410881505425" type="9" />
+  <call number="2" duration="0" date="1410881505426" type="9" />
+</calls>`)
+	result, err := ValidateRepository(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var baselineFile = filepath.Join(dir, "baseline.yaml")
+	err = WriteBaseline(baselineFile, NewBaseline(result))
+	if err != nil {
+		t.Fatal(err)
+	}
+	baseline, err := ReadBaseline(baselineFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filtered, suppressed := baseline.Filter(result)
+	if len(filtered.Violations) != 0 || suppressed != len(result.Violations) {
+		t.Errorf("filtered got %v, suppressed %d, want none left", filtered.Violations, suppressed)
+	}
+
+	// a new violation, even of a known type, is still reported
+	dir = writeCalls(t, `<calls count="4">
+  <call number="1" duration="0" date="1410881505425" type="9" />
+  <call number="2" duration="0" date="1410881505426" type="9" />
+  <call number="3" duration="0" date="1410881505427" type="9" />
+</calls>`)
+	result, err = ValidateRepository(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filtered, _ = baseline.Filter(result)
+	if got := violationTypes(filtered); !reflect.DeepEqual(got, []ViolationType{UnknownCallType, CountMismatch}) {
+		t.Errorf("filtered got %v, want the new unknown type and count mismatch", filtered.Violations)
+	}
+}
+
+func TestBaselineAfterInsertion(t *testing.T) {
+	var duplicate = `  <call number="2" duration="0" date="1410881505426" type="1" />
+  <call number="2" duration="0" date="1410881505426" type="1" />
+`
+	dir := writeCalls(t, "<calls count=\"2\">\n"+duplicate+"</calls>")
+	result, err := ValidateRepository(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var baseline = NewBaseline(result)
+	// baselines written before violations had records key on the message
+	var legacy = Baseline{Violations: []BaselineEntry{{Type: DuplicateRecord, File: "calls.xml", Message: "call duplicates the call on line 2"}}}
+
+	// a record added above the duplicate moves it down a line
+	dir = writeCalls(t, `<calls count="3">
+  <call number="1" duration="0" date="1410881505425" type="1" />
+`+duplicate+"</calls>")
+	result, err = ValidateRepository(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := violationTypes(result); !reflect.DeepEqual(got, []ViolationType{DuplicateRecord}) {
+		t.Fatalf("violations got %v, want the duplicate", result.Violations)
+	}
+	for name, b := range map[string]Baseline{"records": baseline, "legacy": legacy} {
+		if filtered, suppressed := b.Filter(result); len(filtered.Violations) != 0 || suppressed != 1 {
+			t.Errorf("%s baseline left %v", name, filtered.Violations)
+		}
+	}
+}
+
+func TestResultWithout(t *testing.T) {
+	result := Result{Violations: []Violation{
+		NewViolation(CountMismatch, "calls.xml", 0, "count"),
+		NewViolation(DuplicateRecord, "calls.xml", 3, "duplicate"),
+		NewViolation(CountMismatch, "calls.xml", 0, "count again"),
+	}}
+	got := result.Without(CountMismatch)
+	if len(got.Violations) != 1 || got.Violations[0].Type != DuplicateRecord {
+		t.Errorf("got %v, want only the duplicate", got.Violations)
+	}
+}